@@ -33,7 +33,7 @@ func main() {
 		slog.Error("Failed to connect to database", "error", err)
 		os.Exit(1)
 	}
-	defer db.Conn.Close()
+	defer db.Close()
 
 	// Run Migrations (ensure tables exist)
 	if err := db.Migrate(); err != nil {
@@ -14,6 +14,7 @@ import (
 	"github.com/alextreichler/personal-website/internal/config"
 	"github.com/alextreichler/personal-website/internal/handlers"
 	"github.com/alextreichler/personal-website/internal/repository"
+	"github.com/alextreichler/personal-website/internal/storage"
 )
 
 func main() {
@@ -32,6 +33,7 @@ func main() {
 
 	// Handle "migrate" subcommand for InitContainers
 	migrateOnly := flag.Bool("migrate", false, "Run database migrations and exit")
+	rerender := flag.Bool("rerender", false, "Rebuild the HTML of every post and page in the background after startup")
 	flag.Parse()
 
 	if *migrateOnly {
@@ -55,7 +57,7 @@ func main() {
 		logger.Error("Failed to connect to database", "error", err)
 		os.Exit(1)
 	}
-	defer db.Conn.Close()
+	defer db.Close()
 
 	// Run Migrations (optional on startup, but kept for standalone safety)
 	if err := db.Migrate(); err != nil {
@@ -64,8 +66,31 @@ func main() {
 	}
 	logger.Info("Database initialized")
 
+	// Initialize Media Storage (local disk or S3-compatible bucket)
+	store, err := storage.New(cfg)
+	if err != nil {
+		logger.Error("Failed to initialize storage", "backend", cfg.StorageBackend, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Storage initialized", "backend", cfg.StorageBackend)
+
 	// Initialize Application Handlers
-	app := handlers.NewApp(db, cfg)
+	app, err := handlers.NewApp(db, cfg, store)
+	if err != nil {
+		logger.Error("Failed to initialize application", "error", err)
+		os.Exit(1)
+	}
+
+	// Precompute related posts, so upgraded databases have them straight away
+	app.RefreshRelated()
+
+	// Stale HTML is otherwise rebuilt lazily as posts are viewed
+	if *rerender {
+		app.RerenderAll()
+	}
+
+	// Check external links in posts on a schedule (LINK_CHECK_INTERVAL)
+	app.StartLinkChecker()
 
 	// Initialize Server
 	srv := &http.Server{
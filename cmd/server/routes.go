@@ -19,8 +19,21 @@ import (
 		mux.HandleFunc("POST /admin", limiter.Limit(http.HandlerFunc(app.LoginPost)).ServeHTTP)
 		mux.HandleFunc("GET /logout", app.Logout)
 		mux.HandleFunc("GET /post/", app.ViewPost)
+		mux.HandleFunc("GET /tag/{name}", app.TagPage)
+		mux.HandleFunc("GET /search", app.Search)
+		mux.HandleFunc("GET /archive", app.ArchiveIndex)
+		mux.HandleFunc("GET /series/{slug}", app.SeriesPage)
+		mux.HandleFunc("GET /series/{slug}/rss.xml", app.SeriesFeed)
+		mux.HandleFunc("GET /preview/{token}", app.SharedPreview)
+				mux.HandleFunc("GET /og/{slug}", app.OGImage)
 				mux.HandleFunc("GET /rss.xml", app.RSSFeed)
 				mux.HandleFunc("GET /sitemap.xml", app.Sitemap)
+				mux.HandleFunc("GET /sitemap/{page}", app.SitemapPage)
+				mux.HandleFunc("GET /robots.txt", app.RobotsTXT)
+
+				// Static pages (About, Now, ...). Exact routes above take precedence,
+				// and the page editor refuses slugs that would be shadowed.
+				mux.HandleFunc("GET /{slug}", app.ViewPage)
 				mux.Handle("GET /metrics", middleware.MetricsHandler())
 			
 				// Health Check for Kubernetes
@@ -38,28 +51,61 @@ import (
 				mux.HandleFunc("GET /admin/posts/edit", middleware.AuthMiddleware(isProd, app.AdminEditPost))
 				mux.HandleFunc("POST /admin/posts/edit", middleware.AuthMiddleware(isProd, app.AdminUpdatePost))
 				mux.HandleFunc("POST /admin/posts/delete", middleware.AuthMiddleware(isProd, app.AdminDeletePost))
+				mux.HandleFunc("POST /admin/posts/bulk", middleware.AuthMiddleware(isProd, app.AdminBulkPosts))
+				mux.HandleFunc("POST /admin/posts/related", middleware.AuthMiddleware(isProd, app.AdminRelatedOverride))
+				mux.HandleFunc("POST /admin/api/preview", middleware.AuthMiddleware(isProd, app.AdminPreview))
+				mux.HandleFunc("POST /admin/api/autosave", middleware.AuthMiddleware(isProd, app.AdminAutosave))
+				mux.HandleFunc("POST /admin/api/autosave/discard", middleware.AuthMiddleware(isProd, app.AdminDiscardAutosave))
 			
+				mux.HandleFunc("GET /admin/series", middleware.AuthMiddleware(isProd, app.AdminListSeries))
+				mux.HandleFunc("POST /admin/series", middleware.AuthMiddleware(isProd, app.AdminCreateSeries))
+				mux.HandleFunc("GET /admin/series/edit", middleware.AuthMiddleware(isProd, app.AdminEditSeries))
+				mux.HandleFunc("POST /admin/series/edit", middleware.AuthMiddleware(isProd, app.AdminUpdateSeries))
+				mux.HandleFunc("POST /admin/series/delete", middleware.AuthMiddleware(isProd, app.AdminDeleteSeries))
+
+				mux.HandleFunc("GET /admin/pages", middleware.AuthMiddleware(isProd, app.AdminListPages))
+				mux.HandleFunc("GET /admin/pages/new", middleware.AuthMiddleware(isProd, app.AdminNewPage))
+				mux.HandleFunc("POST /admin/pages/new", middleware.AuthMiddleware(isProd, app.AdminCreatePage))
+				mux.HandleFunc("GET /admin/pages/edit", middleware.AuthMiddleware(isProd, app.AdminEditPage))
+				mux.HandleFunc("POST /admin/pages/edit", middleware.AuthMiddleware(isProd, app.AdminUpdatePage))
+				mux.HandleFunc("POST /admin/pages/delete", middleware.AuthMiddleware(isProd, app.AdminDeletePage))
+
+				mux.HandleFunc("GET /admin/tags", middleware.AuthMiddleware(isProd, app.AdminListTags))
+				mux.HandleFunc("GET /admin/tags/edit", middleware.AuthMiddleware(isProd, app.AdminEditTag))
+				mux.HandleFunc("POST /admin/tags/edit", middleware.AuthMiddleware(isProd, app.AdminUpdateTag))
+				mux.HandleFunc("POST /admin/tags/merge", middleware.AuthMiddleware(isProd, app.AdminMergeTags))
+				mux.HandleFunc("POST /admin/tags/delete", middleware.AuthMiddleware(isProd, app.AdminDeleteTag))
+
+				mux.HandleFunc("GET /admin/links", middleware.AuthMiddleware(isProd, app.AdminLinks))
+				mux.HandleFunc("POST /admin/links/check", middleware.AuthMiddleware(isProd, app.AdminCheckLinks))
+
+				mux.HandleFunc("POST /admin/rerender", middleware.AuthMiddleware(isProd, app.AdminRerender))
+				mux.HandleFunc("GET /admin/rerender", middleware.AuthMiddleware(isProd, app.AdminRerenderStatus))
+
 				mux.HandleFunc("GET /admin/about", middleware.AuthMiddleware(isProd, app.AdminEditAbout))
 				mux.HandleFunc("POST /admin/about", middleware.AuthMiddleware(isProd, app.AdminUpdateAbout))
 			
 				mux.HandleFunc("GET /admin/media", middleware.AuthMiddleware(isProd, app.AdminMediaManager))
 				mux.HandleFunc("POST /admin/media/upload", middleware.AuthMiddleware(isProd, app.AdminUploadImage))
 			
-				// Static File Server with Cache Headers
-				fileServer := http.StripPrefix("/static/", http.FileServer(http.Dir(app.Config.StaticPath)))
-				mux.Handle("GET /static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					// Cache static files for 1 year (immutable)
-					w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-					fileServer.ServeHTTP(w, r)
-				}))
+				// Uploaded media, served from the configured storage backend
+				mux.HandleFunc("GET /media/", app.ServeMedia)
+				mux.HandleFunc("GET /static/uploads/{key...}", app.LegacyUpload)
+				mux.HandleFunc("GET /attachments/{id}", app.AttachmentPage)
+			
+				// Static files: fingerprinted names are cached for a year
+				// (immutable), plain names only briefly
+				mux.Handle("GET /static/", http.StripPrefix("/static/", http.HandlerFunc(app.ServeStatic)))
 			
 				// Apply Middleware Chain
-				// Flow: Request -> Metrics -> Gzip -> Security -> CSRF -> ETag -> Mux
+				// Flow: Request -> Metrics -> Canonical Host -> Gzip -> Security -> CSRF -> Page Cache -> ETag -> Mux
 				return middleware.MetricsMiddleware(
-					middleware.GzipMiddleware(
-						middleware.SecurityHeadersMiddleware(
-							middleware.CSRFMiddleware(isProd)(
-								middleware.ETagMiddleware(mux),
+					middleware.CanonicalHostMiddleware(app.URLs)(
+						middleware.GzipMiddleware(
+							middleware.SecurityHeadersMiddleware(
+								middleware.CSRFMiddleware(isProd)(
+									app.Cache.Middleware(middleware.ETagMiddleware(mux)),
+								),
 							),
 						),
 					),
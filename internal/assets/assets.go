@@ -0,0 +1,159 @@
+// Package assets fingerprints the static files at startup, so stylesheets
+// and scripts can be cached by browsers for a year and still reach
+// returning visitors as soon as they change.
+//
+// Each file is served both under its own name, with short caching, and
+// under a name carrying a hash of its content, such as style.3f9a1c2b.css,
+// which never changes meaning and is cached as immutable. Templates link to
+// the hashed names through Manifest.Path.
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// CacheImmutable is the Cache-Control of hashed names.
+	CacheImmutable = "public, max-age=31536000, immutable"
+	// CacheShort is the Cache-Control of plain names, which may change.
+	CacheShort = "public, max-age=300"
+)
+
+// hashLen is the number of hex digits of the content hash put in names.
+const hashLen = 8
+
+type file struct {
+	name    string // Slash separated path below /static/
+	hashed  string
+	hash    string
+	content []byte
+	modTime time.Time
+}
+
+// Manifest maps static files to their hashed names and serves them.
+type Manifest struct {
+	files    map[string]*file // By name
+	byHashed map[string]*file
+}
+
+// Load reads and hashes every file in fsys. With minify set, CSS and
+// JavaScript are minified first.
+func Load(fsys fs.FS, minify bool) (*Manifest, error) {
+	m := &Manifest{
+		files:    make(map[string]*file),
+		byHashed: make(map[string]*file),
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if minify {
+			switch path.Ext(name) {
+			case ".css":
+				content = MinifyCSS(content)
+			case ".js":
+				content = MinifyJS(content)
+			}
+		}
+
+		sum := sha256.Sum256(content)
+		f := &file{
+			name:    name,
+			hash:    hex.EncodeToString(sum[:])[:hashLen],
+			content: content,
+			modTime: info.ModTime(),
+		}
+		f.hashed = hashedName(name, f.hash)
+		m.files[name] = f
+		m.byHashed[f.hashed] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// hashedName puts hash before the extension of name.
+func hashedName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// unhashedName strips what looks like a content hash from name, for
+// requests of hashes from before the last deploy.
+func unhashedName(name string) (string, bool) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	dot := strings.LastIndexByte(base, '.')
+	if dot < 0 || len(base)-dot-1 != hashLen {
+		return "", false
+	}
+	if _, err := hex.DecodeString(base[dot+1:]); err != nil {
+		return "", false
+	}
+	return base[:dot] + ext, true
+}
+
+// Path returns the URL of a static file by its hashed name, or by its own
+// name if there is no such file.
+func (m *Manifest) Path(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if f, ok := m.files[name]; ok {
+		return "/static/" + f.hashed
+	}
+	return "/static/" + name
+}
+
+// ServeHTTP serves a file by hashed or plain name; mount it with the
+// /static/ prefix stripped.
+func (m *Manifest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+
+	if f, ok := m.byHashed[name]; ok {
+		w.Header().Set("Cache-Control", CacheImmutable)
+		serveFile(w, r, f)
+		return
+	}
+	if f, ok := m.files[name]; ok {
+		w.Header().Set("Cache-Control", CacheShort)
+		serveFile(w, r, f)
+		return
+	}
+	// Pages cached before a deploy still link to the old hash; give them
+	// the current file, but not for keeps
+	if plain, ok := unhashedName(name); ok {
+		if f, ok := m.files[plain]; ok {
+			w.Header().Set("Cache-Control", CacheShort)
+			serveFile(w, r, f)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, f *file) {
+	// The content hash is a strong validator, and setting it here keeps the
+	// ETag middleware from buffering the file to compute its own
+	w.Header().Set("ETag", `"`+f.hash+`"`)
+	http.ServeContent(w, r, f.name, f.modTime, bytes.NewReader(f.content))
+}
@@ -0,0 +1,96 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"testing/fstest"
+)
+
+func fetch(h http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestManifest(t *testing.T) {
+	m, err := Load(fstest.MapFS{
+		"style.css": {Data: []byte("body { color: red; }")},
+		"js/app.js": {Data: []byte("var a = 1;")},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	css := m.Path("style.css")
+	if !regexp.MustCompile(`^/static/style\.[0-9a-f]{8}\.css$`).MatchString(css) {
+		t.Fatalf("Path(style.css) = %q", css)
+	}
+	if got := m.Path("/js/app.js"); !regexp.MustCompile(`^/static/js/app\.[0-9a-f]{8}\.js$`).MatchString(got) {
+		t.Errorf("Path(/js/app.js) = %q", got)
+	}
+	if got := m.Path("missing.png"); got != "/static/missing.png" {
+		t.Errorf("Path of a missing file = %q, want it unchanged", got)
+	}
+
+	tests := []struct {
+		path, cache, body string
+	}{
+		{css[len("/static"):], CacheImmutable, "body { color: red; }"},
+		{"/style.css", CacheShort, "body { color: red; }"},
+		{"/style.0123abcd.css", CacheShort, "body { color: red; }"}, // Hash from an older deploy
+	}
+	for _, tt := range tests {
+		w := fetch(m, tt.path)
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("GET %s = %d %q", tt.path, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Cache-Control"); got != tt.cache {
+			t.Errorf("GET %s Cache-Control = %q, want %q", tt.path, got, tt.cache)
+		}
+	}
+	if w := fetch(m, "/missing.css"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /missing.css = %d, want 404", w.Code)
+	}
+}
+
+func TestHashChangesWithContent(t *testing.T) {
+	fsys := fstest.MapFS{"style.css": {Data: []byte("a { color: red }")}}
+	before, err := Load(fsys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys["style.css"] = &fstest.MapFile{Data: []byte("a { color: blue }")}
+	after, err := Load(fsys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Path("style.css") == after.Path("style.css") {
+		t.Error("hashed name did not change with the content")
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	src := `/* Theme */
+body  {
+    font-family: "Open  Sans", sans-serif;
+    width: calc(100% - 2rem);
+}
+
+nav a :hover,
+.x > .y { margin: 0 auto; }
+`
+	want := `body{font-family:"Open  Sans",sans-serif;width:calc(100% - 2rem)}nav a :hover,.x > .y{margin:0 auto}`
+	if got := string(MinifyCSS([]byte(src))); got != want {
+		t.Errorf("MinifyCSS =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMinifyJS(t *testing.T) {
+	src := "// Comment\nfunction f() {\n    var url = 'http://x';\n\n    return url\n}\n"
+	want := "function f() {\nvar url = 'http://x';\nreturn url\n}\n"
+	if got := string(MinifyJS([]byte(src))); got != want {
+		t.Errorf("MinifyJS = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package assets
+
+import (
+	"bytes"
+	"strings"
+)
+
+// MinifyCSS removes comments and the whitespace CSS doesn't need, leaving
+// strings untouched. Whitespace between tokens is only collapsed, never
+// removed, since it can be significant, e.g. in selectors and calc().
+func MinifyCSS(src []byte) []byte {
+	var out bytes.Buffer
+	space := false // Whitespace pending before the next token
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return out.Bytes()
+			}
+			i += end + 3
+			space = true
+			continue
+		case c == '"' || c == '\'':
+			if space && !trailingPunct(out.Bytes()) {
+				out.WriteByte(' ')
+			}
+			space = false
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				j = len(src) - 1
+			}
+			out.Write(src[i : j+1])
+			i = j
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+			continue
+		}
+
+		if strings.IndexByte("{};,", c) >= 0 {
+			// A semicolon before a closing brace is optional
+			if c == '}' && bytes.HasSuffix(out.Bytes(), []byte(";")) {
+				out.Truncate(out.Len() - 1)
+			}
+			out.WriteByte(c)
+			space = false
+			continue
+		}
+		if space && out.Len() > 0 && !trailingPunct(out.Bytes()) {
+			out.WriteByte(' ')
+		}
+		space = false
+		out.WriteByte(c)
+	}
+	return out.Bytes()
+}
+
+// trailingPunct reports whether b ends with punctuation after which
+// whitespace can be dropped.
+func trailingPunct(b []byte) bool {
+	return len(b) > 0 && strings.IndexByte("{};,:", b[len(b)-1]) >= 0
+}
+
+// MinifyJS trims indentation, blank lines and whole-line // comments.
+// Lines are kept apart so automatic semicolon insertion is unaffected.
+func MinifyJS(src []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || bytes.HasPrefix(line, []byte("//")) {
+			continue
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
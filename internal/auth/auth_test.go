@@ -2,6 +2,7 @@ package auth
 
 import (
 	"testing"
+	"time"
 )
 
 func TestPasswordHashing(t *testing.T) {
@@ -32,3 +33,28 @@ func TestPasswordHashing(t *testing.T) {
 		t.Errorf("CheckPasswordHash succeeded for wrong password")
 	}
 }
+
+func TestSignFor(t *testing.T) {
+	SecretKey = []byte("test-secret")
+
+	token := SignFor("preview", "42", time.Now().Add(time.Hour))
+	if data, err := VerifyFor("preview", token); err != nil || data != "42" {
+		t.Fatalf("VerifyFor = %q, %v; want 42", data, err)
+	}
+
+	// Tokens are bound to their purpose and can't pass as sessions
+	if _, err := VerifyFor("other", token); err == nil {
+		t.Error("VerifyFor accepted a token signed for another purpose")
+	}
+	if _, err := Verify(token); err == nil {
+		t.Error("Verify accepted a purpose token as a session")
+	}
+	if _, err := VerifyFor("preview", Sign("42|9999999999")); err == nil {
+		t.Error("VerifyFor accepted a session token")
+	}
+
+	expired := SignFor("preview", "42", time.Now().Add(-time.Minute))
+	if _, err := VerifyFor("preview", expired); err == nil {
+		t.Error("VerifyFor accepted an expired token")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // SecretKey should be set by the application at startup
@@ -43,3 +45,56 @@ func Verify(token string) (string, error) {
 
 	return data, nil
 }
+
+// SignFor creates a token for data that is only accepted by VerifyFor with
+// the same purpose, and only until expires. The key is derived from SecretKey
+// and the purpose, so these tokens are never valid as session cookies.
+func SignFor(purpose, data string, expires time.Time) string {
+	payload := data + "|" + strconv.FormatInt(expires.Unix(), 10)
+	h := hmac.New(sha256.New, purposeKey(purpose))
+	h.Write([]byte(payload))
+	signature := base64.URLEncoding.EncodeToString(h.Sum(nil))
+	return base64.URLEncoding.EncodeToString([]byte(payload)) + "." + signature
+}
+
+// VerifyFor checks a token created by SignFor and returns the original data.
+func VerifyFor(purpose, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", errors.New("invalid token format")
+	}
+
+	payloadBytes, err := base64.URLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", err
+	}
+	payload := string(payloadBytes)
+
+	h := hmac.New(sha256.New, purposeKey(purpose))
+	h.Write([]byte(payload))
+	expectedSignature := base64.URLEncoding.EncodeToString(h.Sum(nil))
+
+	if !hmac.Equal([]byte(parts[1]), []byte(expectedSignature)) {
+		return "", errors.New("invalid signature")
+	}
+
+	sep := strings.LastIndexByte(payload, '|')
+	if sep < 0 {
+		return "", errors.New("invalid token format")
+	}
+	expires, err := strconv.ParseInt(payload[sep+1:], 10, 64)
+	if err != nil {
+		return "", errors.New("invalid token format")
+	}
+	if time.Now().Unix() > expires {
+		return "", errors.New("token expired")
+	}
+
+	return payload[:sep], nil
+}
+
+func purposeKey(purpose string) []byte {
+	h := hmac.New(sha256.New, SecretKey)
+	h.Write([]byte("purpose:" + purpose))
+	return h.Sum(nil)
+}
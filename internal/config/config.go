@@ -4,16 +4,68 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/siteurl"
 )
 
 type Config struct {
 	Port          string
 	DBPath        string
 	UploadPath    string
-	StaticPath    string
 	SessionSecret string
 	SessionCookie string
 	Env           string
+
+	// Public origin of the site, e.g. https://example.com. When set, absolute
+	// URLs always use it and other hosts are redirected to it.
+	BaseURL string
+	// Comma-separated IPs or CIDR ranges allowed to set X-Forwarded-* headers
+	TrustedProxies string
+
+	// Media storage backend: "local" (UploadPath on disk) or "s3"
+	StorageBackend string
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+
+	// Upload limits
+	MaxUploadBytes     int64 // Largest image accepted
+	MaxAttachmentBytes int64 // Largest PDF, audio or video file accepted
+	MaxImageDimension  int   // Largest width or height in pixels
+	MaxImagePixels     int   // Largest width*height, guards against decompression bombs
+	UploadQuotaBytes   int64 // Total storage allowed per user
+
+	// Directory for generated Open Graph images
+	OGCachePath string
+
+	// Directory of code files the snippet shortcode can include
+	SnippetsPath string
+
+	// How often external links in posts are checked; 0 disables checking
+	LinkCheckInterval time.Duration
+
+	// How long shared draft preview links stay valid
+	PreviewLinkTTL time.Duration
+
+	// Memory for rendered public pages kept by the page cache; 0 disables it
+	PageCacheBytes int64
+
+	// Minify static CSS and JavaScript at startup
+	MinifyAssets bool
+
+	// Directory whose template/ and static/ files replace the built-in ones
+	// of the same name, for theming without rebuilding
+	ThemePath string
+	// Development mode: read templates and static files from WebPath
+	// instead of the copies built into the binary, and reload them when
+	// they change
+	DevReload bool
+	WebPath   string
 }
 
 func Load() *Config {
@@ -21,10 +73,38 @@ func Load() *Config {
 		Port:          getEnv("PORT", ":6060"),
 		DBPath:        getEnv("DB_PATH", "./data/site.db"),
 		UploadPath:    getEnv("UPLOAD_PATH", "web/static/uploads"),
-		StaticPath:    getEnv("STATIC_PATH", "./web/static"),
 		SessionSecret: getEnv("SESSION_SECRET", "default-insecure-secret-change-me"), // Provide default for dev, warn in prod
 		SessionCookie: getEnv("SESSION_COOKIE_NAME", "admin_session"),
 		Env:           getEnv("APP_ENV", "development"),
+
+		BaseURL:        getEnv("BASE_URL", ""),
+		TrustedProxies: getEnv("TRUSTED_PROXIES", ""),
+
+		StorageBackend: getEnv("STORAGE_BACKEND", "local"),
+		S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+		S3Region:       getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:       getEnv("S3_BUCKET", ""),
+		S3AccessKey:    getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretKey:    getEnv("S3_SECRET_ACCESS_KEY", ""),
+
+		MaxUploadBytes:     getEnvInt64("MAX_UPLOAD_BYTES", 10<<20),
+		MaxAttachmentBytes: getEnvInt64("MAX_ATTACHMENT_BYTES", 200<<20),
+		MaxImageDimension:  int(getEnvInt64("MAX_IMAGE_DIMENSION", 8000)),
+		MaxImagePixels:     int(getEnvInt64("MAX_IMAGE_PIXELS", 40_000_000)),
+		UploadQuotaBytes:   getEnvInt64("UPLOAD_QUOTA_BYTES", 1<<30),
+
+		OGCachePath:  getEnv("OG_CACHE_PATH", "./data/og"),
+		SnippetsPath: getEnv("SNIPPETS_PATH", "./data/snippets"),
+
+		LinkCheckInterval: getEnvDuration("LINK_CHECK_INTERVAL", 24*time.Hour),
+		PreviewLinkTTL:    getEnvDuration("PREVIEW_LINK_TTL", 7*24*time.Hour),
+
+		PageCacheBytes: getEnvInt64("PAGE_CACHE_BYTES", 32<<20),
+		MinifyAssets:   getEnvBool("MINIFY_ASSETS", false),
+
+		ThemePath: getEnv("THEME_PATH", ""),
+		DevReload: getEnvBool("DEV_RELOAD", false),
+		WebPath:   getEnv("WEB_PATH", "./web"),
 	}
 }
 
@@ -35,18 +115,84 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt64(key string, fallback int64) int64 {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		slog.Warn("Ignoring invalid integer environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Ignoring invalid boolean environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return b
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Ignoring invalid duration environment variable", "key", key, "value", value)
+		return fallback
+	}
+	return d
+}
+
 // Validate checks for critical configuration issues
 func (c *Config) Validate() {
 	if c.SessionSecret == "default-insecure-secret-change-me" {
 		slog.Warn("Using default insecure SESSION_SECRET. Please set this environment variable in production.")
 	}
 
-	// Ensure upload directory exists
-	if err := os.MkdirAll(c.UploadPath, 0755); err != nil {
-		slog.Error("Failed to create upload directory", "path", c.UploadPath, "error", err)
+	if _, err := siteurl.New(c.BaseURL, c.TrustedProxies); err != nil {
+		slog.Error("Invalid BASE_URL or TRUSTED_PROXIES", "error", err)
 		os.Exit(1)
 	}
-	
+	if c.BaseURL == "" && c.Env == "production" {
+		slog.Warn("BASE_URL is not set; absolute URLs will be derived from request headers.")
+	}
+	if strings.HasPrefix(c.BaseURL, "https:") && c.TrustedProxies == "" {
+		slog.Warn("BASE_URL is https but TRUSTED_PROXIES is not set; plain HTTP requests can't be told apart and won't be redirected to https.")
+	}
+	if c.DevReload && c.Env == "production" {
+		slog.Warn("DEV_RELOAD is set; templates are read from WEB_PATH and checked for changes on every request, and the page cache is off.")
+	}
+
+	// Ensure upload directory exists when media is kept on local disk
+	if c.StorageBackend == "local" {
+		if err := os.MkdirAll(c.UploadPath, 0755); err != nil {
+			slog.Error("Failed to create upload directory", "path", c.UploadPath, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if c.StorageBackend == "s3" && (c.S3Endpoint == "" || c.S3Bucket == "") {
+		slog.Error("STORAGE_BACKEND=s3 requires S3_ENDPOINT and S3_BUCKET")
+		os.Exit(1)
+	}
+
+	// Ensure OG image cache directory exists
+	if err := os.MkdirAll(c.OGCachePath, 0755); err != nil {
+		slog.Error("Failed to create OG image cache directory", "path", c.OGCachePath, "error", err)
+		os.Exit(1)
+	}
+
 	// Ensure db directory exists
 	dbDir := filepath.Dir(c.DBPath)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
@@ -0,0 +1,166 @@
+// Package diagram renders a subset of the Mermaid diagram language to static
+// SVG, so diagrams need no client-side JavaScript. Flowcharts and sequence
+// diagrams are supported.
+package diagram
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Render converts Mermaid source to an SVG element.
+func Render(src string) (string, error) {
+	lines := sourceLines(src)
+	if len(lines) == 0 {
+		return "", errors.New("empty diagram")
+	}
+	switch kind := strings.Fields(lines[0].text)[0]; kind {
+	case "graph", "flowchart":
+		return renderFlowchart(lines)
+	case "sequenceDiagram":
+		return renderSequence(lines)
+	default:
+		return "", fmt.Errorf("unsupported diagram type %q", kind)
+	}
+}
+
+type sourceLine struct {
+	number int
+	text   string
+}
+
+// sourceLines returns the non-blank lines of src without %% comments.
+func sourceLines(src string) []sourceLine {
+	var lines []sourceLine
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+		lines = append(lines, sourceLine{i + 1, line})
+	}
+	return lines
+}
+
+// Text metrics, approximated for a 14px sans-serif font.
+const (
+	fontSize   = 14
+	charWidth  = 8
+	lineHeight = 18
+)
+
+var breakRe = regexp.MustCompile(`(?i)<br\s*/?>`)
+
+// labelLines splits a label at <br> tags and strips surrounding quotes.
+func labelLines(label string) []string {
+	label = strings.TrimSpace(label)
+	if len(label) >= 2 && label[0] == '"' && label[len(label)-1] == '"' {
+		label = label[1 : len(label)-1]
+	}
+	lines := breakRe.Split(label, -1)
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines
+}
+
+func textWidth(lines []string) float64 {
+	w := 0
+	for _, line := range lines {
+		w = max(w, utf8.RuneCountInString(line))
+	}
+	return float64(w * charWidth)
+}
+
+// svg accumulates SVG markup.
+type svg struct {
+	b strings.Builder
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
+
+// elem writes a self-closing element with attribute name/value pairs.
+func (s *svg) elem(name string, attrs ...string) {
+	s.b.WriteString("<" + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.b.WriteString(" " + attrs[i] + `="` + html.EscapeString(attrs[i+1]) + `"`)
+	}
+	s.b.WriteString("/>")
+}
+
+// text writes centered lines of text around (x, y).
+func (s *svg) text(x, y float64, lines []string, class string) {
+	top := y - float64(len(lines)-1)*lineHeight/2
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		s.b.WriteString(`<text x="` + num(x) + `" y="` + num(top+float64(i)*lineHeight) +
+			`" text-anchor="middle" dominant-baseline="central" fill="currentColor" class="` + class + `">` +
+			html.EscapeString(line) + "</text>")
+	}
+}
+
+// line writes a straight line in the given style.
+func (s *svg) line(x1, y1, x2, y2 float64, style lineStyle) {
+	attrs := []string{"x1", num(x1), "y1", num(y1), "x2", num(x2), "y2", num(y2), "stroke", "currentColor", "class", "diagram-edge"}
+	s.elem("line", append(attrs, style.attrs()...)...)
+}
+
+// arrowhead writes a filled triangle pointing at (x, y), coming from (fx, fy).
+func (s *svg) arrowhead(fx, fy, x, y float64) {
+	dx, dy := x-fx, y-fy
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		return
+	}
+	ux, uy := dx/d, dy/d
+	const length, half = 10, 5
+	bx, by := x-ux*length, y-uy*length
+	points := num(x) + "," + num(y) + " " +
+		num(bx-uy*half) + "," + num(by+ux*half) + " " +
+		num(bx+uy*half) + "," + num(by-ux*half)
+	s.elem("polygon", "points", points, "fill", "currentColor", "class", "diagram-arrow")
+}
+
+// labelBox writes text on a background so it stays readable over lines.
+func (s *svg) labelBox(x, y float64, lines []string) {
+	w := textWidth(lines) + 8
+	h := float64(len(lines)*lineHeight) + 4
+	s.elem("rect", "x", num(x-w/2), "y", num(y-h/2), "width", num(w), "height", num(h), "fill", "#ffffff", "class", "diagram-label-bg")
+	s.text(x, y, lines, "diagram-label")
+}
+
+// wrap returns the finished SVG element of the given size.
+func (s *svg) wrap(width, height float64, label string) string {
+	return `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 ` + num(width) + " " + num(height) +
+		`" width="` + num(width) + `" height="` + num(height) +
+		`" role="img" aria-label="` + label + `" class="diagram" font-family="sans-serif" font-size="` + strconv.Itoa(fontSize) + `">` +
+		s.b.String() + "</svg>"
+}
+
+type lineStyle int
+
+const (
+	solid lineStyle = iota
+	dotted
+	thick
+)
+
+func (l lineStyle) attrs() []string {
+	switch l {
+	case dotted:
+		return []string{"stroke-width", "1.5", "stroke-dasharray", "4 4"}
+	case thick:
+		return []string{"stroke-width", "3"}
+	}
+	return []string{"stroke-width", "1.5"}
+}
@@ -0,0 +1,130 @@
+package diagram
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFlowchart(t *testing.T) {
+	fc, err := parseFlowchart(sourceLines(`graph LR
+  %% comment
+  A[Start] -->|go| B{Ok?}
+  B -- no --> A
+  B ==> C((Done))
+  C --> D
+  style A fill:#f00
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc.dir != "LR" {
+		t.Errorf("dir = %q, want LR", fc.dir)
+	}
+	var ids []string
+	for _, n := range fc.order {
+		ids = append(ids, n.id+":"+n.shape)
+	}
+	if got, want := strings.Join(ids, " "), "A:rect B:diamond C:circle D:rect"; got != want {
+		t.Errorf("nodes = %q, want %q", got, want)
+	}
+	if len(fc.edges) != 4 {
+		t.Fatalf("got %d edges, want 4", len(fc.edges))
+	}
+	if got := strings.Join(fc.edges[0].label, ""); got != "go" {
+		t.Errorf("first edge label = %q, want go", got)
+	}
+	if got := strings.Join(fc.edges[1].label, ""); got != "no" {
+		t.Errorf("second edge label = %q, want no", got)
+	}
+	if fc.edges[2].style != thick {
+		t.Errorf("==> should be thick")
+	}
+}
+
+func TestFlowchartRanks(t *testing.T) {
+	fc, err := parseFlowchart(sourceLines("graph TD\nA --> B\nB --> C\nA --> C\nC --> A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.layout()
+	for id, want := range map[string]int{"A": 0, "B": 1, "C": 2} {
+		if got := fc.nodes[id].rank; got != want {
+			t.Errorf("rank(%s) = %d, want %d", id, got, want)
+		}
+	}
+}
+
+func TestParseSequence(t *testing.T) {
+	seq, err := parseSequence(sourceLines(`sequenceDiagram
+  autonumber
+  participant A as Alice
+  actor B
+  A->>B: Hello
+  B--xA: Bye
+  Note over A,B: done
+  loop Every minute
+  end
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seq.participants) != 2 || seq.participants[0].label[0] != "Alice" {
+		t.Fatalf("unexpected participants %+v", seq.participants)
+	}
+	msgs := seq.messages()
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2", len(msgs))
+	}
+	if got := msgs[0].label[0]; got != "1. Hello" {
+		t.Errorf("autonumbered label = %q", got)
+	}
+	if msgs[1].head != "cross" || msgs[1].style != dotted {
+		t.Errorf("--x should be a dotted line with a cross, got %+v", msgs[1])
+	}
+	if n := len(seq.events) - len(msgs); n != 1 {
+		t.Errorf("got %d notes, want 1", n)
+	}
+}
+
+func TestRender(t *testing.T) {
+	for _, src := range []string{
+		"graph TD\nA --> A",
+		"flowchart RL\nA[\"Quoted<br>label\"] --- B",
+		"sequenceDiagram\nA->>A: self\nNote left of A: first",
+	} {
+		out, err := Render(src)
+		if err != nil {
+			t.Errorf("Render(%q): %v", src, err)
+			continue
+		}
+		if !strings.HasPrefix(out, "<svg ") || !strings.HasSuffix(out, "</svg>") {
+			t.Errorf("Render(%q) = %q, want an svg element", src, out)
+		}
+	}
+}
+
+func TestRenderEscapesLabels(t *testing.T) {
+	out, err := Render(`graph TD
+A["<script>alert(1)</script>"] --> B`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "<script") {
+		t.Errorf("label was not escaped: %s", out)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"pie\n\"a\" : 1",
+		"graph TD\nA -->",
+		"graph XX\nA --> B",
+		"sequenceDiagram",
+		"sequenceDiagram\nthis is not a message",
+	} {
+		if _, err := Render(src); err == nil {
+			t.Errorf("Render(%q): expected an error", src)
+		}
+	}
+}
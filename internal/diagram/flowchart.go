@@ -0,0 +1,462 @@
+package diagram
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type flowNode struct {
+	id    string
+	label []string
+	shape string
+	order int // Position of first appearance
+
+	rank, index int     // Layer and position within it
+	x, y, w, h  float64 // Center and size
+}
+
+type flowEdge struct {
+	from, to   *flowNode
+	label      []string
+	style      lineStyle
+	arrowStart bool
+	arrowEnd   bool
+}
+
+type flowchart struct {
+	dir   string // TD, BT, LR or RL
+	nodes map[string]*flowNode
+	order []*flowNode
+	edges []*flowEdge
+}
+
+// Statements that style or group nodes, which the renderer ignores.
+var ignoredFlowStatements = map[string]bool{
+	"subgraph": true, "end": true, "style": true, "classDef": true, "class": true,
+	"click": true, "linkStyle": true, "direction": true,
+}
+
+var (
+	nodeIDRe = regexp.MustCompile(`^[\p{L}\p{N}_]+`)
+	classRe  = regexp.MustCompile(`^:::[\w-]+`)
+
+	// A --> B, A -.-> B, A ==> B, A --- B, A <--> B, with an optional |label|
+	edgeRe = regexp.MustCompile(`^(<)?(-{2,}>|-{3,}|={2,}>|={3,}|-\.+->|-\.+-|--x|--o)(?:\|([^|]*)\|)?`)
+	// A -- label --> B and the dotted and thick equivalents
+	edgeTextRe = regexp.MustCompile(`^(<)?(--|==|-\.)\s*([^-=.|>\s][^|]*?)\s*(-->|---|\.->|\.-|==>|===)`)
+)
+
+// nodeShapes lists bracket pairs, longest openers first.
+var nodeShapes = []struct{ open, close, shape string }{
+	{"(((", ")))", "circle"},
+	{"((", "))", "circle"},
+	{"([", "])", "stadium"},
+	{"[[", "]]", "subroutine"},
+	{"[(", ")]", "rect"},
+	{"{{", "}}", "hexagon"},
+	{"[/", "/]", "rect"},
+	{`[\`, `\]`, "rect"},
+	{"[", "]", "rect"},
+	{"(", ")", "round"},
+	{"{", "}", "diamond"},
+	{">", "]", "rect"},
+}
+
+func parseFlowchart(lines []sourceLine) (*flowchart, error) {
+	fc := &flowchart{dir: "TD", nodes: map[string]*flowNode{}}
+	if header := strings.Fields(lines[0].text); len(header) > 1 {
+		fc.dir = strings.TrimSuffix(header[1], ";")
+	}
+	switch fc.dir {
+	case "TB":
+		fc.dir = "TD"
+	case "TD", "BT", "LR", "RL":
+	default:
+		return nil, fmt.Errorf("line %d: unknown direction %q", lines[0].number, fc.dir)
+	}
+
+	for _, line := range lines[1:] {
+		for _, stmt := range strings.Split(line.text, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if stmt == "" || ignoredFlowStatements[strings.Fields(stmt)[0]] {
+				continue
+			}
+			if err := fc.parseStatement(stmt); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.number, err)
+			}
+		}
+	}
+	if len(fc.order) == 0 {
+		return nil, fmt.Errorf("flowchart has no nodes")
+	}
+	return fc, nil
+}
+
+// parseStatement reads a node followed by any number of edges and nodes.
+func (fc *flowchart) parseStatement(stmt string) error {
+	rest := stmt
+	prev, err := fc.parseNode(&rest)
+	if err != nil {
+		return err
+	}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		edge := &flowEdge{from: prev}
+		var op string
+		if m := edgeRe.FindStringSubmatch(rest); m != nil {
+			op = m[2]
+			edge.arrowStart = m[1] != ""
+			edge.label = labelLines(m[3])
+			rest = rest[len(m[0]):]
+		} else if m := edgeTextRe.FindStringSubmatch(rest); m != nil {
+			op = m[2] + m[4]
+			edge.arrowStart = m[1] != ""
+			edge.label = labelLines(m[3])
+			rest = rest[len(m[0]):]
+		} else {
+			return fmt.Errorf("expected an arrow at %q", rest)
+		}
+		edge.arrowEnd = strings.HasSuffix(op, ">")
+		switch {
+		case strings.Contains(op, "."):
+			edge.style = dotted
+		case strings.Contains(op, "="):
+			edge.style = thick
+		}
+
+		next, err := fc.parseNode(&rest)
+		if err != nil {
+			return err
+		}
+		edge.to = next
+		fc.edges = append(fc.edges, edge)
+		prev = next
+	}
+	return nil
+}
+
+// parseNode reads a node reference with an optional shape and label.
+func (fc *flowchart) parseNode(rest *string) (*flowNode, error) {
+	s := strings.TrimSpace(*rest)
+	id := nodeIDRe.FindString(s)
+	if id == "" {
+		return nil, fmt.Errorf("expected a node at %q", s)
+	}
+	s = s[len(id):]
+
+	node, ok := fc.nodes[id]
+	if !ok {
+		node = &flowNode{id: id, label: []string{id}, shape: "rect", order: len(fc.order)}
+		fc.nodes[id] = node
+		fc.order = append(fc.order, node)
+	}
+
+	for _, shape := range nodeShapes {
+		if !strings.HasPrefix(s, shape.open) {
+			continue
+		}
+		end := strings.Index(s[len(shape.open):], shape.close)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed %q in node %s", shape.open, id)
+		}
+		node.label = labelLines(s[len(shape.open) : len(shape.open)+end])
+		node.shape = shape.shape
+		s = s[len(shape.open)+end+len(shape.close):]
+		break
+	}
+	s = classRe.ReplaceAllString(s, "")
+	*rest = s
+	return node, nil
+}
+
+// Layout spacing.
+const (
+	padding  = 20
+	nodeGap  = 30
+	rankGap  = 50
+	nodeMinW = 60
+)
+
+// layout assigns nodes to layers along the flow and positions them.
+func (fc *flowchart) layout() (width, height float64) {
+	for _, n := range fc.order {
+		n.w = max(textWidth(n.label)+32, nodeMinW)
+		n.h = float64(len(n.label)*lineHeight) + 22
+		switch n.shape {
+		case "diamond":
+			n.w, n.h = n.w*1.4+10, n.h*1.6
+		case "circle":
+			n.w = max(n.w, n.h)
+			n.h = n.w
+		case "hexagon", "stadium":
+			n.w += 20
+		}
+	}
+
+	ranks := fc.rank()
+
+	// Order nodes within layers by the average position of their neighbours
+	for pass := 0; pass < 2; pass++ {
+		for r := 1; r < len(ranks); r++ {
+			fc.sortByNeighbours(ranks[r], func(e *flowEdge) (*flowNode, *flowNode) { return e.to, e.from })
+		}
+		for r := len(ranks) - 2; r >= 0; r-- {
+			fc.sortByNeighbours(ranks[r], func(e *flowEdge) (*flowNode, *flowNode) { return e.from, e.to })
+		}
+	}
+
+	// Place layers along the main axis and center nodes across it
+	vertical := fc.dir == "TD" || fc.dir == "BT"
+	size := func(n *flowNode) (main, cross float64) {
+		if vertical {
+			return n.h, n.w
+		}
+		return n.w, n.h
+	}
+	var crossMax float64
+	crossSizes := make([]float64, len(ranks))
+	for r, layer := range ranks {
+		for i, n := range layer {
+			_, cross := size(n)
+			crossSizes[r] += cross
+			if i > 0 {
+				crossSizes[r] += nodeGap
+			}
+		}
+		crossMax = max(crossMax, crossSizes[r])
+	}
+	main := float64(padding)
+	for r, layer := range ranks {
+		var depth float64
+		for _, n := range layer {
+			m, _ := size(n)
+			depth = max(depth, m)
+		}
+		cross := padding + (crossMax-crossSizes[r])/2
+		for _, n := range layer {
+			_, c := size(n)
+			if vertical {
+				n.x, n.y = cross+c/2, main+depth/2
+			} else {
+				n.x, n.y = main+depth/2, cross+c/2
+			}
+			cross += c + nodeGap
+		}
+		main += depth + rankGap
+	}
+	main += padding - rankGap
+
+	if vertical {
+		width, height = crossMax+2*padding, main
+	} else {
+		width, height = main, crossMax+2*padding
+	}
+	for _, n := range fc.order {
+		switch fc.dir {
+		case "BT":
+			n.y = height - n.y
+		case "RL":
+			n.x = width - n.x
+		}
+	}
+
+	// Leave room for self-loops and edge labels that stick out past the nodes
+	for _, e := range fc.edges {
+		if e.from == e.to {
+			width = max(width, e.from.x+e.from.w/2+30+padding)
+		} else if len(e.label) > 0 && e.label[0] != "" {
+			width = max(width, (e.from.x+e.to.x)/2+textWidth(e.label)/2+padding)
+		}
+	}
+	return width, height
+}
+
+func (fc *flowchart) hasEdge(from, to *flowNode) bool {
+	for _, e := range fc.edges {
+		if e.from == from && e.to == to {
+			return true
+		}
+	}
+	return false
+}
+
+// rank assigns each node the length of the longest path reaching it,
+// ignoring edges that close a cycle, and returns the layers in order.
+func (fc *flowchart) rank() [][]*flowNode {
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := map[*flowNode]int{}
+	out := map[*flowNode][]*flowNode{}
+	var postorder []*flowNode
+	var visit func(n *flowNode)
+	visit = func(n *flowNode) {
+		state[n] = active
+		for _, e := range fc.edges {
+			if e.from != n || e.to == n {
+				continue
+			}
+			switch state[e.to] {
+			case unvisited:
+				out[n] = append(out[n], e.to)
+				visit(e.to)
+			case done:
+				out[n] = append(out[n], e.to)
+			}
+		}
+		state[n] = done
+		postorder = append(postorder, n)
+	}
+	for _, n := range fc.order {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+
+	maxRank := 0
+	for i := len(postorder) - 1; i >= 0; i-- {
+		n := postorder[i]
+		for _, next := range out[n] {
+			next.rank = max(next.rank, n.rank+1)
+			maxRank = max(maxRank, next.rank)
+		}
+	}
+	ranks := make([][]*flowNode, maxRank+1)
+	for _, n := range fc.order {
+		n.index = len(ranks[n.rank])
+		ranks[n.rank] = append(ranks[n.rank], n)
+	}
+	return ranks
+}
+
+// sortByNeighbours orders a layer by the mean index of each node's
+// neighbours, as returned by ends, in the adjacent layer.
+func (fc *flowchart) sortByNeighbours(layer []*flowNode, ends func(*flowEdge) (self, other *flowNode)) {
+	key := map[*flowNode]float64{}
+	for _, n := range layer {
+		sum, count := 0.0, 0
+		for _, e := range fc.edges {
+			self, other := ends(e)
+			if self == n && other != n && math.Abs(float64(other.rank-n.rank)) == 1 {
+				sum += float64(other.index)
+				count++
+			}
+		}
+		key[n] = float64(n.index)
+		if count > 0 {
+			key[n] = sum / float64(count)
+		}
+	}
+	sort.SliceStable(layer, func(i, j int) bool { return key[layer[i]] < key[layer[j]] })
+	for i, n := range layer {
+		n.index = i
+	}
+}
+
+// boundary returns where the line from n's center towards (x, y) leaves its
+// shape.
+func (n *flowNode) boundary(x, y float64) (float64, float64) {
+	dx, dy := x-n.x, y-n.y
+	if dx == 0 && dy == 0 {
+		return n.x, n.y
+	}
+	var scale float64
+	switch n.shape {
+	case "circle":
+		scale = n.w / 2 / math.Hypot(dx, dy)
+	case "diamond":
+		scale = 1 / (math.Abs(dx)/(n.w/2) + math.Abs(dy)/(n.h/2))
+	default:
+		scale = math.Inf(1)
+		if dx != 0 {
+			scale = n.w / 2 / math.Abs(dx)
+		}
+		if dy != 0 {
+			scale = min(scale, n.h/2/math.Abs(dy))
+		}
+	}
+	return n.x + dx*scale, n.y + dy*scale
+}
+
+func renderFlowchart(lines []sourceLine) (string, error) {
+	fc, err := parseFlowchart(lines)
+	if err != nil {
+		return "", err
+	}
+	width, height := fc.layout()
+
+	var s svg
+	for _, e := range fc.edges {
+		if e.from == e.to {
+			// Self-loop on the right-hand side
+			n := e.from
+			x, y := n.x+n.w/2, n.y
+			s.elem("path", "d", "M"+num(x)+","+num(y-8)+" C"+num(x+30)+","+num(y-25)+" "+num(x+30)+","+num(y+25)+" "+num(x)+","+num(y+8),
+				"fill", "none", "stroke", "currentColor", "class", "diagram-edge")
+			if e.arrowEnd {
+				s.arrowhead(x+20, y+16, x, y+8)
+			}
+			continue
+		}
+		x1, y1 := e.from.boundary(e.to.x, e.to.y)
+		x2, y2 := e.to.boundary(e.from.x, e.from.y)
+		if fc.hasEdge(e.to, e.from) {
+			// Shift edges in both directions apart so neither hides the other
+			const offset = 5
+			d := math.Hypot(x2-x1, y2-y1)
+			nx, ny := -(y2-y1)/d*offset, (x2-x1)/d*offset
+			x1, y1, x2, y2 = x1+nx, y1+ny, x2+nx, y2+ny
+		}
+		s.line(x1, y1, x2, y2, e.style)
+		if e.arrowEnd {
+			s.arrowhead(x1, y1, x2, y2)
+		}
+		if e.arrowStart {
+			s.arrowhead(x2, y2, x1, y1)
+		}
+	}
+	for _, e := range fc.edges {
+		if len(e.label) > 0 && e.label[0] != "" && e.from != e.to {
+			s.labelBox((e.from.x+e.to.x)/2, (e.from.y+e.to.y)/2, e.label)
+		}
+	}
+
+	for _, n := range fc.order {
+		shape := []string{"fill", "#ffffff", "stroke", "currentColor", "stroke-width", "1.5", "class", "diagram-node"}
+		left, top := n.x-n.w/2, n.y-n.h/2
+		switch n.shape {
+		case "circle":
+			s.elem("circle", append([]string{"cx", num(n.x), "cy", num(n.y), "r", num(n.w / 2)}, shape...)...)
+		case "diamond":
+			points := num(n.x) + "," + num(top) + " " + num(left+n.w) + "," + num(n.y) + " " +
+				num(n.x) + "," + num(top+n.h) + " " + num(left) + "," + num(n.y)
+			s.elem("polygon", append([]string{"points", points}, shape...)...)
+		case "hexagon":
+			const inset = 15
+			points := num(left+inset) + "," + num(top) + " " + num(left+n.w-inset) + "," + num(top) + " " +
+				num(left+n.w) + "," + num(n.y) + " " + num(left+n.w-inset) + "," + num(top+n.h) + " " +
+				num(left+inset) + "," + num(top+n.h) + " " + num(left) + "," + num(n.y)
+			s.elem("polygon", append([]string{"points", points}, shape...)...)
+		default:
+			rx := 0.0
+			switch n.shape {
+			case "round":
+				rx = 8
+			case "stadium":
+				rx = n.h / 2
+			}
+			s.elem("rect", append([]string{"x", num(left), "y", num(top), "width", num(n.w), "height", num(n.h), "rx", num(rx)}, shape...)...)
+			if n.shape == "subroutine" {
+				s.line(left+8, top, left+8, top+n.h, solid)
+				s.line(left+n.w-8, top, left+n.w-8, top+n.h, solid)
+			}
+		}
+		s.text(n.x, n.y, n.label, "diagram-text")
+	}
+
+	return s.wrap(width, height, "Flowchart"), nil
+}
@@ -0,0 +1,287 @@
+package diagram
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type participant struct {
+	id    string
+	label []string
+	x, w  float64
+}
+
+type seqEvent struct {
+	from, to *participant
+	label    []string
+	note     string // "", "left", "right" or "over"
+	style    lineStyle
+	head     string // "filled", "open", "cross" or "" for messages
+}
+
+type sequence struct {
+	participants []*participant
+	byID         map[string]*participant
+	events       []*seqEvent
+}
+
+const participantID = `([\p{L}\p{N}_.]+)`
+
+var (
+	participantRe = regexp.MustCompile(`^(?:participant|actor)\s+` + participantID + `(?:\s+as\s+(.+))?$`)
+	messageRe     = regexp.MustCompile(`^` + participantID + `\s*(-->>|->>|-->|->|--x|-x|--\)|-\))\s*[+-]?\s*` + participantID + `\s*(?::(.*))?$`)
+	noteRe        = regexp.MustCompile(`(?i)^note\s+(left of|right of|over)\s+` + participantID + `(?:\s*,\s*` + participantID + `)?\s*:(.*)$`)
+)
+
+// Statements for activation bars and block frames, which the renderer
+// ignores.
+var ignoredSequenceStatements = map[string]bool{
+	"activate": true, "deactivate": true, "loop": true, "alt": true, "else": true,
+	"opt": true, "par": true, "and": true, "end": true, "rect": true,
+	"critical": true, "break": true, "box": true, "title": true, "option": true,
+}
+
+func parseSequence(lines []sourceLine) (*sequence, error) {
+	seq := &sequence{byID: map[string]*participant{}}
+	autonumber := false
+	for _, line := range lines[1:] {
+		text := strings.TrimSuffix(line.text, ";")
+		if text == "autonumber" {
+			autonumber = true
+			continue
+		}
+		if m := participantRe.FindStringSubmatch(text); m != nil {
+			p := seq.participant(m[1])
+			if m[2] != "" {
+				p.label = labelLines(m[2])
+			}
+			continue
+		}
+		if m := messageRe.FindStringSubmatch(text); m != nil {
+			ev := &seqEvent{from: seq.participant(m[1]), to: seq.participant(m[3]), label: labelLines(m[4])}
+			if strings.HasPrefix(m[2], "--") {
+				ev.style = dotted
+			}
+			switch strings.TrimLeft(m[2], "-") {
+			case ">>":
+				ev.head = "filled"
+			case ")":
+				ev.head = "open"
+			case "x":
+				ev.head = "cross"
+			}
+			if autonumber {
+				ev.label[0] = strconv.Itoa(len(seq.messages())+1) + ". " + ev.label[0]
+			}
+			seq.events = append(seq.events, ev)
+			continue
+		}
+		if m := noteRe.FindStringSubmatch(text); m != nil {
+			ev := &seqEvent{from: seq.participant(m[2]), label: labelLines(m[4])}
+			ev.to = ev.from
+			if m[3] != "" {
+				ev.to = seq.participant(m[3])
+			}
+			ev.note = strings.Fields(strings.ToLower(m[1]))[0]
+			seq.events = append(seq.events, ev)
+			continue
+		}
+		if ignoredSequenceStatements[strings.Fields(text)[0]] {
+			continue
+		}
+		return nil, fmt.Errorf("line %d: cannot parse %q", line.number, text)
+	}
+	if len(seq.participants) == 0 {
+		return nil, fmt.Errorf("sequence diagram has no participants")
+	}
+	return seq, nil
+}
+
+// participant returns the participant with the given id, adding it on
+// first use.
+func (seq *sequence) participant(id string) *participant {
+	if p, ok := seq.byID[id]; ok {
+		return p
+	}
+	p := &participant{id: id, label: []string{id}}
+	seq.byID[id] = p
+	seq.participants = append(seq.participants, p)
+	return p
+}
+
+func (seq *sequence) messages() []*seqEvent {
+	var msgs []*seqEvent
+	for _, ev := range seq.events {
+		if ev.note == "" {
+			msgs = append(msgs, ev)
+		}
+	}
+	return msgs
+}
+
+func (seq *sequence) index(p *participant) int {
+	for i, q := range seq.participants {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// Layout spacing.
+const (
+	boxHeight   = 36
+	messageGap  = 40
+	selfLoopW   = 40
+	noteSpacing = 10
+)
+
+// layout spaces the lifelines so every message label fits between them.
+func (seq *sequence) layout() {
+	ps := seq.participants
+	for i, p := range ps {
+		p.w = max(textWidth(p.label)+24, 80)
+		if i == 0 {
+			p.x = padding + p.w/2
+		} else {
+			p.x = ps[i-1].x + (ps[i-1].w+p.w)/2 + 30
+		}
+	}
+
+	push := func(from int, need float64) {
+		if from >= len(ps) {
+			return
+		}
+		edge := float64(padding)
+		if from > 0 {
+			edge = ps[from-1].x
+		}
+		if gap := ps[from].x - edge; gap < need {
+			for _, p := range ps[from:] {
+				p.x += need - gap
+			}
+		}
+	}
+	for _, ev := range seq.events {
+		a, b := seq.index(ev.from), seq.index(ev.to)
+		if a > b {
+			a, b = b, a
+		}
+		w := textWidth(ev.label)
+		switch {
+		case ev.note == "right" || (ev.note == "" && a == b):
+			push(a+1, w+selfLoopW+30)
+		case ev.note == "left":
+			push(a, w+40)
+		case ev.note == "" && b == a+1:
+			push(b, w+40)
+		case ev.note == "" && b > a+1:
+			if span := ps[b].x - ps[a].x; span < w+40 {
+				for _, p := range ps[b:] {
+					p.x += w + 40 - span
+				}
+			}
+		}
+	}
+}
+
+func renderSequence(lines []sourceLine) (string, error) {
+	seq, err := parseSequence(lines)
+	if err != nil {
+		return "", err
+	}
+	seq.layout()
+
+	var s svg
+	var body svg
+	width := 0.0
+	for _, p := range seq.participants {
+		width = max(width, p.x+p.w/2+padding)
+	}
+
+	y := float64(padding + boxHeight + 20)
+	for _, ev := range seq.events {
+		w := textWidth(ev.label)
+		h := float64(len(ev.label) * lineHeight)
+		switch {
+		case ev.note != "":
+			var left, right float64
+			switch ev.note {
+			case "left":
+				right = ev.from.x - noteSpacing
+				left = right - w - 20
+			case "right":
+				left = ev.from.x + noteSpacing
+				right = left + w + 20
+			default:
+				left = min(ev.from.x, ev.to.x) - 30
+				right = max(ev.from.x, ev.to.x) + 30
+				if right-left < w+20 {
+					mid := (left + right) / 2
+					left, right = mid-w/2-10, mid+w/2+10
+				}
+			}
+			body.elem("rect", "x", num(left), "y", num(y), "width", num(right-left), "height", num(h+12),
+				"fill", "#fff8c5", "stroke", "currentColor", "class", "diagram-note")
+			body.text((left+right)/2, y+6+h/2, ev.label, "diagram-text")
+			width = max(width, right+padding)
+			y += h + 12 + 15
+
+		case ev.from == ev.to:
+			x := ev.from.x
+			body.text(x+selfLoopW+8+w/2, y+h/2, ev.label, "diagram-label")
+			line := y + h + 4
+			attrs := []string{"d", "M" + num(x) + "," + num(line) + " H" + num(x+selfLoopW) + " V" + num(line+20) + " H" + num(x),
+				"fill", "none", "stroke", "currentColor", "class", "diagram-edge"}
+			body.elem("path", append(attrs, ev.style.attrs()...)...)
+			body.messageHead(x+selfLoopW, line+20, x, line+20, ev.head)
+			width = max(width, x+selfLoopW+8+w+padding)
+			y += h + 24 + 20
+
+		default:
+			x1, x2 := ev.from.x, ev.to.x
+			body.text((x1+x2)/2, y+h/2, ev.label, "diagram-label")
+			line := y + h + 6
+			body.line(x1, line, x2, line, ev.style)
+			body.messageHead(x1, line, x2, line, ev.head)
+			y += h + 6 + messageGap - lineHeight
+		}
+	}
+	y += 10
+
+	// Lifelines first so boxes and messages draw over them
+	for _, p := range seq.participants {
+		s.elem("line", "x1", num(p.x), "y1", num(padding+boxHeight), "x2", num(p.x), "y2", num(y),
+			"stroke", "currentColor", "stroke-width", "1", "stroke-dasharray", "3 3", "class", "diagram-lifeline")
+	}
+	s.b.WriteString(body.b.String())
+	for _, top := range []float64{padding, y} {
+		for _, p := range seq.participants {
+			s.elem("rect", "x", num(p.x-p.w/2), "y", num(top), "width", num(p.w), "height", num(boxHeight), "rx", "4",
+				"fill", "#ffffff", "stroke", "currentColor", "stroke-width", "1.5", "class", "diagram-node")
+			s.text(p.x, top+boxHeight/2, p.label, "diagram-text")
+		}
+	}
+
+	return s.wrap(width, y+boxHeight+padding, "Sequence diagram"), nil
+}
+
+// messageHead draws the end of a message arrow pointing at (x, y).
+func (s *svg) messageHead(fx, fy, x, y float64, head string) {
+	dir := 1.0
+	if x < fx {
+		dir = -1
+	}
+	switch head {
+	case "filled":
+		s.arrowhead(fx, fy, x, y)
+	case "open":
+		s.elem("path", "d", "M"+num(x-dir*10)+","+num(y-5)+" L"+num(x)+","+num(y)+" L"+num(x-dir*10)+","+num(y+5),
+			"fill", "none", "stroke", "currentColor", "stroke-width", "1.5", "class", "diagram-arrow")
+	case "cross":
+		s.line(x-dir*10-4, y-4, x-dir*10+4, y+4, solid)
+		s.line(x-dir*10-4, y+4, x-dir*10+4, y-4, solid)
+	}
+}
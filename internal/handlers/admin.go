@@ -20,6 +20,7 @@ func (app *App) AdminDashboard(w http.ResponseWriter, r *http.Request) {
 		"Username":  username,
 		"PageTitle": "Dashboard",
 		"Stats":     stats,
+		"Rerender":  app.Rerender.Progress(),
 	}
 	
 	app.Render(w, r, "dashboard.html", data)
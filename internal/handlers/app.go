@@ -1,73 +1,95 @@
 package handlers
 
 import (
-	"bytes"
+	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alextreichler/personal-website/internal/auth"
 	"github.com/alextreichler/personal-website/internal/config"
+	"github.com/alextreichler/personal-website/internal/jsonld"
 	"github.com/alextreichler/personal-website/internal/middleware"
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/pagecache"
+	"github.com/alextreichler/personal-website/internal/render"
 	"github.com/alextreichler/personal-website/internal/repository"
-	"github.com/microcosm-cc/bluemonday"
-	"github.com/yuin/goldmark"
-	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/alextreichler/personal-website/internal/siteurl"
+	"github.com/alextreichler/personal-website/internal/storage"
+	"github.com/alextreichler/personal-website/internal/theme"
 )
 
 type App struct {
-	DB            *repository.Database
-	TemplateCache map[string]*template.Template
-	Config        *config.Config
+	DB       *repository.Database
+	Config   *config.Config
+	Storage  storage.Storage
+	URLs     *siteurl.Builder
+	Renderer *render.Pipeline
+	Rerender *render.Job
+	Cache    *pagecache.Cache
+
+	views      atomic.Pointer[views] // Templates and static files
+	reload     sync.Mutex            // Held while checking for edits in development mode
+	viewsStamp string                // Summary of the files views were loaded from, see theme.Stamp
+	linkCheck  sync.Mutex            // Held while links are being checked
+
+	relatedPending chan struct{} // Holds a value while a related posts recompute is scheduled
+	related        sync.Mutex    // Held while related posts are recomputed
 }
 
-func NewApp(db *repository.Database, cfg *config.Config) *App {
+func NewApp(db *repository.Database, cfg *config.Config, store storage.Storage) (*App, error) {
 	// Pre-compile templates into a cache
-	cache := make(map[string]*template.Template)
-
-	pages := []string{
-		"home.html",
-		"login.html",
-		"dashboard.html",
-		"admin_posts.html",
-		"admin_post_new.html",
-		"admin_post_edit.html",
-		"admin_about.html",
-		"admin_media.html",
-		"post.html",
-		"error.html",
-		// Add other templates here as they are created
-	}
-
-	for _, page := range pages {
-		name := page
-		
-		// Corrected: Ensure paths are explicit relative to the project root.
-		// The error suggests "web/template/" might be prefixed again internally.
-		// By providing the full relative paths directly, we avoid filepath.Join's
-		// potential for unexpected behavior with ParseFiles in this context.
-		// TODO: Use cfg.StaticPath logic if templates move, but they are in template/ not static/
-		ts, err := template.ParseFiles("web/template/base.html", "web/template/"+page)
-		if err != nil {
-			slog.Error("Error parsing template", "name", name, "error", err)
-			os.Exit(1)
-		}
+	templates, static := viewFiles(cfg.WebPath, cfg.ThemePath, cfg.UploadPath, cfg.DevReload)
+	v, err := loadViews(templates, static, cfg.MinifyAssets)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := siteurl.New(cfg.BaseURL, cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid site URL configuration: %w", err)
+	}
 
-		cache[name] = ts
+	app := &App{
+		DB:       db,
+		Config:   cfg,
+		Storage:  store,
+		URLs:     urls,
+		Rerender: &render.Job{},
+	}
+	app.views.Store(v)
+	if cfg.DevReload {
+		app.viewsStamp, _ = theme.Stamp(templates, static)
+	}
+	app.Renderer = &render.Pipeline{PostTitle: app.postTitle}
+	if cfg.SnippetsPath != "" {
+		app.Renderer.Snippets = os.DirFS(cfg.SnippetsPath)
 	}
 
-	return &App{
-		DB:            db,
-		TemplateCache: cache,
-		Config:        cfg,
+	// Rendered public pages are kept until the content they show changes.
+	// Logged in users see admin links, so they always get fresh pages.
+	// Templates being edited would go stale there, so development mode
+	// does without.
+	cacheBytes := cfg.PageCacheBytes
+	if cfg.DevReload {
+		cacheBytes = 0
 	}
+	app.Cache = pagecache.New(cacheBytes, "page", "q")
+	app.Cache.Origin = urls.Origin
+	app.Cache.Bypass = func(r *http.Request) bool { return app.CurrentUser(r) != "" }
+	db.OnChange(func(c repository.Change) { app.Cache.Invalidate(string(c)) })
+
+	app.startRelated()
+	return app, nil
 }
 
 func (app *App) Render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
-	ts, ok := app.TemplateCache[name]
+	ts, ok := app.currentViews().templates[name]
 	if !ok {
 		http.Error(w, "Template not found", http.StatusInternalServerError)
 		slog.Error("Template not found in cache", "name", name)
@@ -81,23 +103,21 @@ func (app *App) Render(w http.ResponseWriter, r *http.Request, name string, data
 		}
 
 		// Inject login status
-		cookie, err := r.Cookie(app.Config.SessionCookie)
-		if err == nil && cookie.Value != "" {
-			username, err := auth.Verify(cookie.Value)
-			if err == nil {
-				dataMap["IsLoggedIn"] = true
-				dataMap["Username"] = username
-			} else {
-				dataMap["IsLoggedIn"] = false
-				dataMap["Username"] = ""
-			}
-		} else {
-			dataMap["IsLoggedIn"] = false
-			dataMap["Username"] = ""
-		}
+		username := app.CurrentUser(r)
+		dataMap["IsLoggedIn"] = username != ""
+		dataMap["Username"] = username
 
 		// Inject CSRF token
 		dataMap["CSRFToken"] = middleware.GetCSRFToken(r)
+
+		// Menu links to pages marked for navigation
+		if _, exists := dataMap["NavPages"]; !exists {
+			dataMap["NavPages"] = app.navPages()
+		}
+
+		if _, exists := dataMap["CanonicalURL"]; !exists {
+			dataMap["CanonicalURL"] = app.URLs.Canonical(r)
+		}
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -110,7 +130,37 @@ func (app *App) Render(w http.ResponseWriter, r *http.Request, name string, data
 	}
 }
 
+// CurrentUser returns the username from a valid session cookie, or "" for visitors.
+func (app *App) CurrentUser(r *http.Request) string {
+	cookie, err := r.Cookie(app.Config.SessionCookie)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	username, err := auth.Verify(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return username
+}
+
+// cacheFor lets the page cache keep the response to r until one of changes
+// is made. Pages with the site menu depend on ChangePages too. Handlers that
+// don't call it are never cached.
+func cacheFor(r *http.Request, changes ...repository.Change) {
+	topics := make([]string, len(changes))
+	for i, c := range changes {
+		topics[i] = string(c)
+	}
+	pagecache.Depends(r, topics...)
+}
+
 func (app *App) Home(w http.ResponseWriter, r *http.Request) {
+	if m := archivePath.FindStringSubmatch(r.URL.Path); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		app.renderArchive(w, r, year, month)
+		return
+	}
 	if r.URL.Path != "/" {
 		app.NotFound(w, r)
 		return
@@ -144,25 +194,15 @@ func (app *App) Home(w http.ResponseWriter, r *http.Request) {
 		aboutContent = "Welcome! (Edit this in admin)"
 	}
 
-	var aboutBuf bytes.Buffer
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			highlighting.NewHighlighting(
-				highlighting.WithStyle("dracula"),
-			),
-		),
-	)
-	if err := md.Convert([]byte(aboutContent), &aboutBuf); err != nil {
+	safeAboutHTML, err := app.Renderer.HTML(aboutContent)
+	if err != nil {
 		slog.Error("Error rendering about markdown", "error", err)
 	}
 
-	// Sanitize HTML
-	p := bluemonday.UGCPolicy()
-	p.AllowAttrs("style").OnElements("pre", "code", "span")
-	safeAboutHTML := p.Sanitize(aboutBuf.String())
-
 	data := map[string]interface{}{
 		"Posts":           posts,
+		"Covers":          app.coverURLs(posts),
+		"ArchiveYears":    app.archiveYears(),
 		"AboutHTML":       template.HTML(safeAboutHTML),
 		"PageTitle":       "Home",
 		"MetaDescription": "Welcome to the personal website and blog of Alex Treichler. Read my latest thoughts on technology and more.",
@@ -174,6 +214,15 @@ func (app *App) Home(w http.ResponseWriter, r *http.Request) {
 		"PrevPage":        page - 1,
 	}
 
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags, repository.ChangeSettings, repository.ChangePages)
+
+	site := app.URLs.Origin(r)
+	about := models.Truncate(models.PlainText(safeAboutHTML), models.MetaDescriptionLength)
+	setJSONLD(data,
+		jsonld.NewWebSite(siteOwner, site),
+		jsonld.NewPerson(siteOwner, site, about),
+	)
+
 	app.Render(w, r, "home.html", data)
 }
 
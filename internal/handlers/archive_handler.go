@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/jsonld"
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/repository"
+)
+
+// archivePath matches /{year}/ and /{year}/{month}/. These are dispatched
+// from Home, because as mux patterns they would overlap /post/, /media/
+// and /static/.
+var archivePath = regexp.MustCompile(`^/(\d{4})/(?:(0[1-9]|1[0-2])/)?$`)
+
+// archiveYears loads the post counts per month for the archive navigator.
+func (app *App) archiveYears() []models.ArchiveYear {
+	months, err := app.DB.GetArchiveMonths()
+	if err != nil {
+		slog.Error("Error loading archive", "error", err)
+		return nil
+	}
+	return models.GroupArchive(months)
+}
+
+// ArchiveIndex lists every published post, grouped by year and month.
+func (app *App) ArchiveIndex(w http.ResponseWriter, r *http.Request) {
+	app.renderArchive(w, r, 0, 0)
+}
+
+// renderArchive lists the posts of a year, a month, or (with year 0) all time.
+func (app *App) renderArchive(w http.ResponseWriter, r *http.Request, year, month int) {
+	prefix, title, path := "", "Archive", "/archive"
+	switch {
+	case month != 0:
+		prefix = fmt.Sprintf("%04d-%02d", year, month)
+		title = models.ArchiveMonth{Year: year, Month: month}.Name()
+		path = models.ArchiveMonth{Year: year, Month: month}.URL()
+	case year != 0:
+		prefix = fmt.Sprintf("%04d", year)
+		title = strconv.Itoa(year)
+		path = models.ArchiveYear{Year: year}.URL()
+	}
+
+	posts, err := app.DB.GetArchivePosts(prefix)
+	if err != nil {
+		slog.Error("Error loading archive posts", "prefix", prefix, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(posts) == 0 && year != 0 {
+		app.NotFound(w, r)
+		return
+	}
+
+	// Group the listing by month, newest first
+	type monthGroup struct {
+		Name  string
+		URL   string
+		Posts []*models.Post
+	}
+	var groups []*monthGroup
+	for _, post := range posts {
+		m := models.ArchiveMonth{Year: post.CreatedAt.Year(), Month: int(post.CreatedAt.Month())}
+		if len(groups) == 0 || groups[len(groups)-1].URL != m.URL() {
+			groups = append(groups, &monthGroup{Name: m.Name(), URL: m.URL()})
+		}
+		g := groups[len(groups)-1]
+		g.Posts = append(g.Posts, post)
+	}
+
+	data := map[string]interface{}{
+		"Heading":         title,
+		"Groups":          groups,
+		"PostCount":       len(posts),
+		"ArchiveYears":    app.archiveYears(),
+		"PageTitle":       title,
+		"MetaDescription": fmt.Sprintf("Posts published in %s.", title),
+	}
+	if year == 0 {
+		data["MetaDescription"] = "Every post, by month of publication."
+	}
+
+	site := app.URLs.Origin(r)
+	crumbs := []jsonld.Crumb{{Name: "Home", URL: site + "/"}, {Name: "Archive", URL: site + "/archive"}}
+	if year != 0 {
+		crumbs = append(crumbs, jsonld.Crumb{Name: strconv.Itoa(year), URL: site + models.ArchiveYear{Year: year}.URL()})
+	}
+	if month != 0 {
+		crumbs = append(crumbs, jsonld.Crumb{Name: time.Month(month).String(), URL: site + path})
+	}
+	setJSONLD(data, jsonld.NewBreadcrumbList(crumbs...))
+
+	cacheFor(r, repository.ChangePosts, repository.ChangePages)
+	app.Render(w, r, "archive.html", data)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/textdiff"
+)
+
+// conflictField is a submitted form field carried over to the conflict page.
+type conflictField struct {
+	Name, Value string
+}
+
+// AdminAutosave stores the editor's unsaved content of a post. The response
+// also says whether the post has been saved elsewhere since the editor
+// loaded the given version. Without an id the content is of a new post,
+// kept for the signed in user until the post is created.
+func (app *App) AdminAutosave(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("id") == "" {
+		app.autosaveNewPost(w, r)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+	post, err := app.DB.GetPostByID(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	autosave := &models.Autosave{
+		PostID:  post.ID,
+		Title:   r.FormValue("title"),
+		Content: r.FormValue("content"),
+		SavedAt: time.Now(),
+	}
+	if err := app.DB.SaveAutosave(autosave); err != nil {
+		slog.Error("Error autosaving post", "id", post.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	version, err := strconv.Atoi(r.FormValue("version"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"saved_at": autosave.SavedAt.Format(time.RFC3339),
+		"stale":    err == nil && version != post.Version,
+	})
+}
+
+// autosaveNewPost stores the editor's content of a post that hasn't been
+// created yet.
+func (app *App) autosaveNewPost(w http.ResponseWriter, r *http.Request) {
+	username := app.CurrentUser(r)
+	autosave := &models.Autosave{
+		Title:   r.FormValue("title"),
+		Content: r.FormValue("content"),
+		SavedAt: time.Now(),
+	}
+	if err := app.DB.SaveNewPostAutosave(username, autosave); err != nil {
+		slog.Error("Error autosaving new post", "user", username, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"saved_at": autosave.SavedAt.Format(time.RFC3339),
+		"stale":    false,
+	})
+}
+
+// AdminDiscardAutosave drops a post's autosave when the author chooses not
+// to restore it. Without an id it drops the user's new post autosave.
+func (app *App) AdminDiscardAutosave(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("id") == "" {
+		if err := app.DB.DeleteNewPostAutosave(app.CurrentUser(r)); err != nil {
+			slog.Error("Error discarding new post autosave", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+	if err := app.DB.DeleteAutosave(id); err != nil {
+		slog.Error("Error discarding autosave", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newerAutosave returns the autosave of a post if it holds edits made after
+// the post was last saved.
+func (app *App) newerAutosave(post *models.Post) *models.Autosave {
+	autosave, err := app.DB.GetAutosave(post.ID)
+	if err != nil {
+		return nil
+	}
+	if !autosave.SavedAt.After(post.UpdatedAt) || (autosave.Title == post.Title && autosave.Content == post.Content) {
+		return nil
+	}
+	return autosave
+}
+
+// postConflict answers a save made from an outdated editor with the
+// differences between the stored post and the submitted one, and a form to
+// save the submission over it. The submission is also kept as the autosave
+// so reopening the editor offers to restore it.
+func (app *App) postConflict(w http.ResponseWriter, r *http.Request, id int) {
+	current, err := app.DB.GetPostByID(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	title, content := r.FormValue("title"), r.FormValue("content")
+	if err := app.DB.SaveAutosave(&models.Autosave{PostID: id, Title: title, Content: content, SavedAt: time.Now()}); err != nil {
+		slog.Error("Error keeping conflicting edit", "id", id, "error", err)
+	}
+
+	var fields []conflictField
+	for _, name := range slices.Sorted(maps.Keys(r.PostForm)) {
+		switch name {
+		case "csrf_token", "id", "version", "title", "content":
+			continue
+		}
+		for _, v := range r.PostForm[name] {
+			fields = append(fields, conflictField{name, v})
+		}
+	}
+
+	data := map[string]interface{}{
+		"PageTitle":    "Edit Conflict",
+		"Post":         current,
+		"Title":        title,
+		"Content":      content,
+		"TitleChanged": title != current.Title,
+		"Diff":         textdiff.Lines(current.Content, content),
+		"Fields":       fields,
+	}
+	w.WriteHeader(http.StatusConflict)
+	app.Render(w, r, "admin_post_conflict.html", data)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alextreichler/personal-website/internal/jsonld"
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/repository"
+)
+
+// TagPage lists the published posts carrying a tag.
+func (app *App) TagPage(w http.ResponseWriter, r *http.Request) {
+	name := strings.ToLower(r.PathValue("name"))
+
+	posts, err := app.DB.GetPostsByTag(name)
+	if err != nil {
+		slog.Error("Error loading posts for tag", "tag", name, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	tag, tagErr := app.DB.GetTag(name)
+	if len(posts) == 0 {
+		// Renamed and merged tags keep their old URLs working
+		if tagErr != nil {
+			if newName, err := app.DB.GetTagRedirect(name); err == nil {
+				http.Redirect(w, r, "/tag/"+url.PathEscape(newName), http.StatusMovedPermanently)
+				return
+			}
+		}
+		app.NotFound(w, r)
+		return
+	}
+	if tagErr != nil {
+		tag = &models.Tag{Name: name}
+	}
+
+	description := tag.Description
+	if description == "" {
+		description = "Posts tagged " + name + "."
+	}
+	data := map[string]interface{}{
+		"Tag":             tag,
+		"Posts":           posts,
+		"Covers":          app.coverURLs(posts),
+		"PageTitle":       tag.Title(),
+		"MetaDescription": description,
+	}
+
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags, repository.ChangePages)
+
+	site := app.URLs.Origin(r)
+	setJSONLD(data, jsonld.NewBreadcrumbList(
+		jsonld.Crumb{Name: "Home", URL: site + "/"},
+		jsonld.Crumb{Name: tag.Title(), URL: site + "/tag/" + url.PathEscape(name)},
+	))
+
+	app.Render(w, r, "tag.html", data)
+}
+
+// Search finds published posts whose title or content mention the query.
+func (app *App) Search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	data := map[string]interface{}{
+		"Query":     query,
+		"PageTitle": "Search",
+	}
+
+	if query != "" {
+		posts, err := app.DB.SearchPosts(query)
+		if err != nil {
+			slog.Error("Error searching posts", "query", query, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data["Posts"] = posts
+		data["Covers"] = app.coverURLs(posts)
+	}
+
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags, repository.ChangePages)
+	app.Render(w, r, "search.html", data)
+}
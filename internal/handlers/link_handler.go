@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/linkcheck"
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+// brokenLink is a link that didn't resolve, for the report and the editor.
+type brokenLink struct {
+	*models.PostLink
+	Problem string
+}
+
+// recordLinks stores the links in a post's rendered content.
+func (app *App) recordLinks(post *models.Post) {
+	if err := app.DB.ReplacePostLinks(post.ID, linkcheck.Extract(post.HTMLContent)); err != nil {
+		slog.Error("Error storing post links", "id", post.ID, "error", err)
+	}
+}
+
+// internalProblems describe internal links to content that doesn't exist,
+// by kind of content.
+var internalProblems = map[string]string{
+	linkcheck.KindPost:   "No published post with this slug",
+	linkcheck.KindPage:   "No published page with this slug",
+	linkcheck.KindTag:    "No published posts with this tag",
+	linkcheck.KindSeries: "No series with this slug",
+	linkcheck.KindMedia:  "No uploaded file with this name",
+}
+
+// brokenLinks picks the links that are known not to resolve. Links to posts,
+// pages, tags, series and media are checked against the database and storage
+// straight away; external links use the result of their last check.
+func (app *App) brokenLinks(links []*models.PostLink) []brokenLink {
+	var broken []brokenLink
+	host := app.URLs.Host()
+	exist := map[string]bool{} // By kind and name
+	for _, l := range links {
+		var problem string
+		switch {
+		case linkcheck.External(l.URL, host):
+			if l.Check == nil {
+				continue
+			}
+			if l.Check.Error != "" {
+				problem = l.Check.Error
+			} else if l.Check.Status >= 400 {
+				problem = fmt.Sprintf("%d %s", l.Check.Status, http.StatusText(l.Check.Status))
+			}
+		default:
+			kind, name, ok := linkcheck.Internal(l.URL, host)
+			if !ok || (kind == linkcheck.KindPage && !pageSlugAllowed(name)) {
+				// Other routes, such as /archive
+				continue
+			}
+			found, seen := exist[kind+" "+name]
+			if !seen {
+				found = app.linkTargetExists(kind, name)
+				exist[kind+" "+name] = found
+			}
+			if !found {
+				problem = internalProblems[kind]
+			}
+		}
+		if problem != "" {
+			broken = append(broken, brokenLink{PostLink: l, Problem: problem})
+		}
+	}
+	return broken
+}
+
+// linkTargetExists reports whether the content an internal link points at,
+// as classified by linkcheck.Internal, is there to be shown.
+func (app *App) linkTargetExists(kind, name string) bool {
+	switch kind {
+	case linkcheck.KindPost:
+		_, err := app.DB.GetPostBySlug(name)
+		return err == nil
+	case linkcheck.KindPage:
+		_, err := app.DB.GetPublishedPageBySlug(name)
+		return err == nil
+	case linkcheck.KindTag:
+		posts, err := app.DB.GetPostsByTag(name)
+		if err == nil && len(posts) > 0 {
+			return true
+		}
+		// Renamed and merged tags redirect to their new name
+		_, err = app.DB.GetTagRedirect(name)
+		return err == nil
+	case linkcheck.KindSeries:
+		_, err := app.DB.GetSeriesBySlug(name)
+		return err == nil
+	case linkcheck.KindMedia:
+		if _, err := app.DB.GetMediaByKey(name); err == nil {
+			return true
+		}
+		// Files uploaded before the media library have no record
+		obj, err := app.Storage.Get(context.Background(), name)
+		if err != nil {
+			return false
+		}
+		obj.Close()
+		return true
+	}
+	return true
+}
+
+// redirectAfterSave records the links of a saved post and returns to the
+// post list, or to the editor when some of them are broken so the author
+// sees the warning.
+func (app *App) redirectAfterSave(w http.ResponseWriter, r *http.Request, post *models.Post) {
+	app.recordLinks(post)
+	if len(app.postBrokenLinks(post.ID)) > 0 {
+		http.Redirect(w, r, "/admin/posts/edit?id="+strconv.Itoa(post.ID), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
+}
+
+// postBrokenLinks lists the broken links of one post.
+func (app *App) postBrokenLinks(postID int) []brokenLink {
+	links, err := app.DB.GetPostLinks(postID)
+	if err != nil {
+		slog.Error("Error loading post links", "id", postID, "error", err)
+		return nil
+	}
+	return app.brokenLinks(links)
+}
+
+// CheckLinks refreshes the links of every post and checks the external ones
+// not checked since before. Only one check runs at a time; it returns false
+// if one is already running.
+func (app *App) CheckLinks(before time.Time) bool {
+	if !app.linkCheck.TryLock() {
+		return false
+	}
+	defer app.linkCheck.Unlock()
+
+	posts, err := app.DB.GetAllPosts()
+	if err != nil {
+		slog.Error("Error loading posts for link check", "error", err)
+		return true
+	}
+	for _, post := range posts {
+		app.recordLinks(post)
+	}
+
+	due, err := app.DB.GetLinksDue(before)
+	if err != nil {
+		slog.Error("Error loading links to check", "error", err)
+		return true
+	}
+	var external []string
+	for _, u := range due {
+		if linkcheck.External(u, app.URLs.Host()) {
+			external = append(external, u)
+		}
+	}
+
+	failed := 0
+	for _, res := range linkcheck.New().Check(context.Background(), external) {
+		if !res.OK() {
+			failed++
+		}
+		check := models.LinkCheck{URL: res.URL, Status: res.Status, Error: res.Err, CheckedAt: res.CheckedAt}
+		if err := app.DB.SaveLinkCheck(check); err != nil {
+			slog.Error("Error storing link check", "url", res.URL, "error", err)
+		}
+	}
+	slog.Info("Link check finished", "checked", len(external), "broken", failed)
+	return true
+}
+
+// StartLinkChecker checks external links in the background every
+// LinkCheckInterval.
+func (app *App) StartLinkChecker() {
+	interval := app.Config.LinkCheckInterval
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			app.CheckLinks(time.Now().Add(-interval))
+			<-ticker.C
+		}
+	}()
+}
+
+// AdminLinks reports the broken links across all posts.
+func (app *App) AdminLinks(w http.ResponseWriter, r *http.Request) {
+	links, err := app.DB.GetPostLinks(0)
+	if err != nil {
+		slog.Error("Error loading post links", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	checking := !app.linkCheck.TryLock()
+	if !checking {
+		app.linkCheck.Unlock()
+	}
+	unchecked := 0
+	for _, l := range links {
+		if l.Check == nil && linkcheck.External(l.URL, app.URLs.Host()) {
+			unchecked++
+		}
+	}
+
+	data := map[string]interface{}{
+		"PageTitle":  "Broken Links",
+		"Broken":     app.brokenLinks(links),
+		"TotalLinks": len(links),
+		"Unchecked":  unchecked,
+		"Checking":   checking,
+	}
+	app.Render(w, r, "admin_links.html", data)
+}
+
+// AdminCheckLinks re-checks every external link now, in the background.
+func (app *App) AdminCheckLinks(w http.ResponseWriter, r *http.Request) {
+	go app.CheckLinks(time.Now())
+	http.Redirect(w, r, "/admin/links", http.StatusSeeOther)
+}
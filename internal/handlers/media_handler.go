@@ -2,159 +2,484 @@ package handlers
 
 import (
 	"bytes" // Keep import, needed by imaging.Decode
-	// Keep import, needed by imaging.Decode
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Register decoders for image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/alextreichler/personal-website/internal/imagegen"
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/repository"
+	"github.com/alextreichler/personal-website/internal/storage"
 	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
+	_ "golang.org/x/image/webp"
 )
 
+// uploadError is a validation failure that is shown to the user on the media page.
+type uploadError struct {
+	status  int
+	message string
+}
+
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+func uploadErrorf(status int, format string, args ...interface{}) error {
+	return &uploadError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
 func (app *App) AdminMediaManager(w http.ResponseWriter, r *http.Request) {
-	// List files in uploads directory
-	uploadsDir := app.Config.UploadPath
-	optimizedDir := filepath.Join(app.Config.UploadPath, "optimized")
+	app.renderMediaManager(w, r, http.StatusOK, "")
+}
 
-	// Ensure optimized directory exists
-	if err := os.MkdirAll(optimizedDir, 0755); err != nil {
-		slog.Error("Error creating optimized upload dir", "error", err)
+func (app *App) renderMediaManager(w http.ResponseWriter, r *http.Request, status int, errMsg string) {
+	library, err := app.DB.GetAllMedia("")
+	if err != nil {
+		slog.Error("Error loading media library", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	files, err := os.ReadDir(uploadsDir)
+	// Files uploaded before the library existed only live in storage
+	known := make(map[string]bool)
+	for _, m := range library {
+		known[m.StorageKey] = true
+		if m.PreviewKey != "" {
+			known[m.PreviewKey] = true
+			base := strings.TrimSuffix(m.PreviewKey, ".jpg")
+			known[base+"_800w.jpg"] = true
+			known[base+"_400w.jpg"] = true
+		}
+	}
+	objects, err := app.Storage.List(r.Context(), "")
 	if err != nil {
-		slog.Error("Error reading uploads dir", "error", err)
+		slog.Error("Error listing uploads", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-
-	var images []string
-	for _, file := range files {
-		if !file.IsDir() && !strings.HasPrefix(file.Name(), "optimized_") { // Filter out optimized versions
-			images = append(images, file.Name())
+	var legacy []string
+	for _, obj := range objects {
+		if !known[obj.Key] {
+			legacy = append(legacy, app.Storage.URL(obj.Key))
 		}
 	}
 
-	// Also list optimized images
-	optimizedFiles, err := os.ReadDir(optimizedDir)
+	usage, err := app.DB.GetMediaUsage(app.CurrentUser(r))
 	if err != nil {
-		slog.Error("Error reading optimized upload dir", "error", err)
-		// Don't fail if optimized dir is empty
-	} else {
-		for _, file := range optimizedFiles {
-			if !file.IsDir() {
-				images = append(images, filepath.Join("optimized", file.Name()))
-			}
-		}
+		slog.Error("Error computing media usage", "error", err)
 	}
 
 	data := map[string]interface{}{
-		"Images": images,
+		"Library":       app.mediaViews(library),
+		"Images":        legacy,
+		"Error":         errMsg,
+		"Usage":         formatBytes(usage),
+		"Quota":         formatBytes(app.Config.UploadQuotaBytes),
+		"MaxUpload":     formatBytes(app.Config.MaxUploadBytes),
+		"MaxAttachment": formatBytes(app.Config.MaxAttachmentBytes),
 	}
 
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
 	app.Render(w, r, "admin_media.html", data)
 }
 
+// mediaView bundles a library item with the URLs templates need.
+type mediaView struct {
+	*models.Media
+	URL        string
+	PreviewURL string
+	PageURL    string
+	SizeLabel  string
+}
+
+func (app *App) mediaView(m *models.Media) mediaView {
+	v := mediaView{
+		Media:     m,
+		URL:       app.Storage.URL(m.StorageKey),
+		PageURL:   "/attachments/" + strconv.Itoa(m.ID),
+		SizeLabel: formatBytes(m.Size),
+	}
+	if m.PreviewKey != "" {
+		v.PreviewURL = app.Storage.URL(m.PreviewKey)
+	} else if m.IsImage() {
+		v.PreviewURL = v.URL
+	}
+	return v
+}
+
+func (app *App) mediaViews(media []*models.Media) []mediaView {
+	views := make([]mediaView, 0, len(media))
+	for _, m := range media {
+		views = append(views, app.mediaView(m))
+	}
+	return views
+}
+
 func (app *App) AdminUploadImage(w http.ResponseWriter, r *http.Request) {
-	// Limit upload size to 10MB
-	r.ParseMultipartForm(10 << 20)
+	if _, err := app.storeUpload(w, r); err != nil {
+		var ue *uploadError
+		if errors.As(err, &ue) {
+			app.renderMediaManager(w, r, ue.status, ue.message)
+			return
+		}
+		slog.Error("Error storing upload", "error", err)
+		app.renderMediaManager(w, r, http.StatusInternalServerError, "The upload failed because of a server error. Please try again.")
+		return
+	}
 
-	file, _, err := r.FormFile("image") // Removed 'header'
+	http.Redirect(w, r, "/admin/media", http.StatusSeeOther)
+}
+
+// storeUpload validates the uploaded file, stores the original and any
+// generated variants, and records it in the media library.
+func (app *App) storeUpload(w http.ResponseWriter, r *http.Request) (*models.Media, error) {
+	maxBytes := max(app.Config.MaxUploadBytes, app.Config.MaxAttachmentBytes)
+
+	// Cap the whole request body (plus room for the multipart framing) before parsing it
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, uploadErrorf(http.StatusRequestEntityTooLarge, "The file is too large. The maximum upload size is %s.", formatBytes(maxBytes))
+		}
+		return nil, uploadErrorf(http.StatusBadRequest, "The upload could not be read.")
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
 	if err != nil {
-		slog.Error("Error retrieving file", "error", err)
-		http.Redirect(w, r, "/admin/media", http.StatusSeeOther)
-		return
+		return nil, uploadErrorf(http.StatusBadRequest, "Please choose a file to upload.")
 	}
 	defer file.Close()
 
-	// Read file into a buffer to allow multiple reads
-	fileBytes, err := io.ReadAll(file)
+	username := app.CurrentUser(r)
+	usage, err := app.DB.GetMediaUsage(username)
 	if err != nil {
-		slog.Error("Error reading file into buffer", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		return nil, err
+	}
+	if usage+header.Size > app.Config.UploadQuotaBytes {
+		return nil, uploadErrorf(http.StatusInsufficientStorage, "This upload would exceed your storage quota (%s of %s used).", formatBytes(usage), formatBytes(app.Config.UploadQuotaBytes))
 	}
 
-	// Validate content type (Magic Numbers)
-	fileType := http.DetectContentType(fileBytes)
+	info, err := inspectUpload(file, app.Config.MaxImageDimension, app.Config.MaxImagePixels)
+	if err != nil {
+		return nil, err
+	}
 
-	originalExt := ""
-	switch fileType {
-	case "image/jpeg":
-		originalExt = ".jpg"
-	case "image/png":
-		originalExt = ".png"
-	case "image/gif":
-		originalExt = ".gif"
-	case "image/webp":
-		originalExt = ".webp"
-	default:
-		http.Error(w, "Invalid file type. Only JPG, PNG, GIF, WEBP allowed.", http.StatusBadRequest)
-		return
+	limit := app.Config.MaxAttachmentBytes
+	if info.isImage {
+		limit = app.Config.MaxUploadBytes
+	}
+	if header.Size > limit {
+		return nil, uploadErrorf(http.StatusRequestEntityTooLarge, "The file is too large. The maximum size for this type is %s.", formatBytes(limit))
 	}
 
-	// Generate unique filename for original
-	originalFilename := uuid.New().String() + originalExt
-	originalFilePath := filepath.Join(app.Config.UploadPath, originalFilename)
+	// Generate unique key for original
+	id := uuid.New().String()
+	originalKey := id + info.ext
 
-	// Save original image
-	dst, err := os.Create(originalFilePath)
-	if err != nil {
-		slog.Error("Error creating original file", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	// Save original file
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
 	}
-	defer dst.Close()
-	if _, err := dst.Write(fileBytes); err != nil {
-		slog.Error("Error writing original file", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	if err := app.Storage.Put(r.Context(), originalKey, file, header.Size, info.contentType); err != nil {
+		return nil, fmt.Errorf("storing original %s: %w", originalKey, err)
 	}
 
-	// --- Image Optimization ---
-	img, err := imaging.Decode(bytes.NewReader(fileBytes))
-	if err != nil {
-		slog.Error("Error decoding image for optimization", "error", err)
-		// Continue without optimization if decode fails
-		http.Redirect(w, r, "/admin/media", http.StatusSeeOther)
-		return
+	media := &models.Media{
+		StorageKey:   originalKey,
+		OriginalName: header.Filename,
+		ContentType:  info.contentType,
+		Size:         header.Size,
+		Width:        info.width,
+		Height:       info.height,
+		UploadedBy:   username,
+		CreatedAt:    time.Now(),
+	}
+
+	switch {
+	case media.IsImage():
+		// --- Image Optimization ---
+		// Dimensions were checked above, so a full decode is safe now
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		img, err := imaging.Decode(file)
+		if err != nil {
+			// Continue without optimization if decode fails
+			slog.Error("Error decoding image for optimization", "error", err)
+		} else {
+			media.PreviewKey, media.VariantsSize = app.saveVariants(r, img)
+		}
+		// --- End Image Optimization ---
+	case media.IsPDF():
+		media.PreviewKey, media.VariantsSize = app.savePoster(r, id, "PDF", media.DisplayName())
+	}
+
+	if err := app.DB.CreateMedia(media); err != nil {
+		return nil, err
 	}
+	return media, nil
+}
 
+// saveVariants stores the resized copies used for srcset and returns the key
+// of the default (1200w) variant and the total size of all variants.
+func (app *App) saveVariants(r *http.Request, img image.Image) (string, int64) {
 	// Base UUID for this upload
-	fileUUID := uuid.New().String()
-	baseFilename := "optimized_" + fileUUID
+	baseKey := "optimized/optimized_" + uuid.New().String()
+	var total int64
 
-	// Helper to resize and save
+	// Helper to resize and store. Variants are JPEG because the imaging
+	// package has no pure Go WebP encoder.
 	saveVariant := func(width int, suffix string) {
 		var resizedImg = img
 		if img.Bounds().Dx() > width {
 			resizedImg = imaging.Resize(img, width, 0, imaging.Lanczos)
 		}
-		
-		filename := baseFilename + suffix + ".webp"
-		filePath := filepath.Join(app.Config.UploadPath, "optimized", filename)
 
-		if err := imaging.Save(resizedImg, filePath); err != nil {
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, resizedImg, imaging.JPEG, imaging.JPEGQuality(82)); err != nil {
 			slog.Error("Error encoding optimized image", "width", width, "error", err)
+			return
+		}
+
+		key := baseKey + suffix + ".jpg"
+		size := int64(buf.Len())
+		if err := app.Storage.Put(r.Context(), key, &buf, size, "image/jpeg"); err != nil {
+			slog.Error("Error storing optimized image", "key", key, "error", err)
+			return
 		}
+		total += size
 	}
 
 	// Generate 3 sizes
 	// 1. Large/Default (1200w) - Keep original naming convention (no suffix) for backward compat
 	saveVariant(1200, "")
-	
+
 	// 2. Medium (800w)
 	saveVariant(800, "_800w")
 
 	// 3. Small (400w)
 	saveVariant(400, "_400w")
-	
-	// --- End Image Optimization ---
 
-	http.Redirect(w, r, "/admin/media", http.StatusSeeOther)
+	return baseKey + ".jpg", total
+}
+
+// savePoster stores a generated thumbnail for a file we can't render ourselves.
+func (app *App) savePoster(r *http.Request, id, label, title string) (string, int64) {
+	img, err := imagegen.DocumentPoster(label, title)
+	if err != nil {
+		slog.Error("Error generating poster", "error", err)
+		return "", 0
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, imaging.PNG); err != nil {
+		slog.Error("Error encoding poster", "error", err)
+		return "", 0
+	}
+	key := "posters/" + id + ".png"
+	size := int64(buf.Len())
+	if err := app.Storage.Put(r.Context(), key, &buf, size, "image/png"); err != nil {
+		slog.Error("Error storing poster", "key", key, "error", err)
+		return "", 0
+	}
+	return key, size
+}
+
+type uploadInfo struct {
+	ext         string
+	contentType string
+	isImage     bool
+	width       int
+	height      int
+}
+
+// inspectUpload identifies an upload from its magic number. Images are
+// additionally validated by inspectImage.
+func inspectUpload(r io.ReadSeeker, maxDimension, maxPixels int) (*uploadInfo, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, uploadErrorf(http.StatusBadRequest, "The upload could not be read.")
+	}
+	head = head[:n]
+
+	// SVG can carry scripts and event handlers, so it is never accepted
+	if looksLikeSVG(head) {
+		return nil, uploadErrorf(http.StatusUnsupportedMediaType, "SVG files are not allowed because they can contain scripts. Please upload a PNG instead.")
+	}
+
+	if ext, contentType := detectAttachment(head); ext != "" {
+		return &uploadInfo{ext: ext, contentType: contentType}, nil
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return inspectImage(r, head, maxDimension, maxPixels)
+}
+
+// detectAttachment recognizes the non-image formats we accept.
+func detectAttachment(head []byte) (ext, contentType string) {
+	// MP4 and M4A share the ISO base media container; the major brand in the
+	// leading ftyp box tells them apart
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "M4A ", "M4B ":
+			return ".m4a", "audio/mp4"
+		case "isom", "iso2", "mp41", "mp42", "avc1", "dash", "M4V ":
+			return ".mp4", "video/mp4"
+		}
+	}
+
+	switch ct := http.DetectContentType(head); ct {
+	case "application/pdf":
+		return ".pdf", ct
+	case "audio/mpeg":
+		return ".mp3", ct
+	case "video/webm":
+		return ".webm", ct
+	case "video/mp4":
+		return ".mp4", ct
+	}
+	// MP3 files without an ID3 tag start directly with an MPEG frame sync
+	if len(head) >= 2 && head[0] == 0xFF && head[1]&0xE6 == 0xE2 {
+		return ".mp3", "audio/mpeg"
+	}
+	return "", ""
+}
+
+// inspectImage validates an image from its magic number and header without
+// decoding the pixel data, so oversized images are rejected before they can
+// be expanded in memory.
+func inspectImage(r io.Reader, head []byte, maxDimension, maxPixels int) (*uploadInfo, error) {
+	// Validate content type (Magic Numbers)
+	info := &uploadInfo{contentType: http.DetectContentType(head), isImage: true}
+	var format string
+	switch info.contentType {
+	case "image/jpeg":
+		info.ext, format = ".jpg", "jpeg"
+	case "image/png":
+		info.ext, format = ".png", "png"
+	case "image/gif":
+		info.ext, format = ".gif", "gif"
+	case "image/webp":
+		info.ext, format = ".webp", "webp"
+	default:
+		return nil, uploadErrorf(http.StatusUnsupportedMediaType, "Invalid file type. Allowed: JPG, PNG, GIF, WEBP, PDF, MP3, M4A, MP4, WEBM.")
+	}
+
+	// Don't trust the magic number alone: the header must parse as the same format
+	cfg, decoded, err := image.DecodeConfig(r)
+	if err != nil || decoded != format {
+		return nil, uploadErrorf(http.StatusUnsupportedMediaType, "The file looks like %s but is not a valid image.", strings.ToUpper(format))
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, uploadErrorf(http.StatusUnsupportedMediaType, "The image has invalid dimensions.")
+	}
+	if cfg.Width > maxDimension || cfg.Height > maxDimension {
+		return nil, uploadErrorf(http.StatusRequestEntityTooLarge, "The image is %dx%d pixels. The maximum is %d pixels per side.", cfg.Width, cfg.Height, maxDimension)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, uploadErrorf(http.StatusRequestEntityTooLarge, "The image has too many pixels (%dx%d). The maximum is %d megapixels.", cfg.Width, cfg.Height, maxPixels/1_000_000)
+	}
+
+	info.width, info.height = cfg.Width, cfg.Height
+	return info, nil
+}
+
+func looksLikeSVG(head []byte) bool {
+	lower := bytes.ToLower(head)
+	return bytes.Contains(lower, []byte("<svg")) || bytes.Contains(lower, []byte("<!doctype svg"))
+}
+
+// formatBytes renders a byte count for humans, e.g. "10.0 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// ServeMedia streams an uploaded object from the storage backend. Objects are
+// stored under unique names, so they can be cached forever. Range requests
+// are supported so audio and video can seek.
+func (app *App) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, storage.URLPrefix)
+
+	obj, err := app.Storage.Get(r.Context(), key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			slog.Error("Error opening media", "key", key, "error", err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer obj.Close()
+
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+	if r.URL.Query().Has("download") {
+		name := key
+		if m, err := app.DB.GetMediaByKey(key); err == nil {
+			name = m.DisplayName()
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	// Keys never change content, so the key itself is a strong validator
+	w.Header().Set("ETag", `"`+strings.ReplaceAll(key, `"`, "")+`"`)
+	http.ServeContent(w, r, key, obj.ModTime, obj)
+}
+
+// LegacyUpload redirects the /static/uploads/ URLs of media uploaded before
+// the storage backends to where the object is served now. Posts written back
+// then still link to them, and the local backend keeps the files in the same
+// directory under the same names.
+func (app *App) LegacyUpload(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, storage.URLPrefix+r.PathValue("key"), http.StatusMovedPermanently)
+}
+
+// AttachmentPage is the public download page for a PDF, audio or video file.
+func (app *App) AttachmentPage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+	m, err := app.DB.GetMediaByID(id)
+	if err != nil || !m.IsAttachment() {
+		app.NotFound(w, r)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Media":           app.mediaView(m),
+		"PageTitle":       m.DisplayName(),
+		"MetaDescription": fmt.Sprintf("Download %s (%s, %s).", m.DisplayName(), m.Kind(), formatBytes(m.Size)),
+	}
+	// Uploads never change, only the menu around them
+	cacheFor(r, repository.ChangePages)
+	app.Render(w, r, "attachment.html", data)
 }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alextreichler/personal-website/internal/storage"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInspectImage(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		wantStatus int // 0 means accepted
+	}{
+		{"valid png", encodePNG(t, 20, 10), 0},
+		{"too wide", encodePNG(t, 3000, 1), http.StatusRequestEntityTooLarge},
+		{"too many pixels", encodePNG(t, 1000, 1000), http.StatusRequestEntityTooLarge},
+		{"svg", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), http.StatusUnsupportedMediaType},
+		{"truncated png", encodePNG(t, 20, 10)[:20], http.StatusUnsupportedMediaType},
+		{"text", []byte("just some text"), http.StatusUnsupportedMediaType},
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj"), 0},
+		{"mp3 with id3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), 0},
+		{"m4a", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00isommp42"), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := inspectUpload(bytes.NewReader(tt.data), 2000, 500_000)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if info.isImage && (info.ext != ".png" || info.width != 20 || info.height != 10) {
+					t.Errorf("got %+v", info)
+				}
+				return
+			}
+
+			var ue *uploadError
+			if !errors.As(err, &ue) {
+				t.Fatalf("expected uploadError, got %v", err)
+			}
+			if ue.status != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", ue.status, tt.wantStatus, ue.message)
+			}
+		})
+	}
+}
+
+func TestDetectAttachment(t *testing.T) {
+	tests := map[string]struct{ ext, contentType string }{
+		"%PDF-1.4":                          {".pdf", "application/pdf"},
+		"ID3\x03\x00":                       {".mp3", "audio/mpeg"},
+		"\xff\xfb\x90\x64":                  {".mp3", "audio/mpeg"},
+		"\x00\x00\x00\x18ftypM4A \x00\x00":  {".m4a", "audio/mp4"},
+		"\x00\x00\x00\x18ftypisom\x00\x00":  {".mp4", "video/mp4"},
+		"\x1a\x45\xdf\xa3\x9fB\x86\x81\x01": {".webm", "video/webm"},
+		"\xff\xd8\xff\xe0":                  {"", ""}, // JPEG is an image, not an attachment
+	}
+	for head, want := range tests {
+		ext, ct := detectAttachment([]byte(head))
+		if ext != want.ext || ct != want.contentType {
+			t.Errorf("detectAttachment(%q) = %q, %q; want %q, %q", head, ext, ct, want.ext, want.contentType)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 10 << 20: "10.0 MB", 1536: "1.5 KB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+	if !strings.HasSuffix(formatBytes(1<<30), "GB") {
+		t.Error("expected GB suffix")
+	}
+}
+
+func TestLegacyUploadURL(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "photo.png"), []byte("png"), 0644)
+	store, err := storage.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &App{Storage: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /media/", app.ServeMedia)
+	mux.HandleFunc("GET /static/uploads/{key...}", app.LegacyUpload)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/uploads/photo.png", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/media/photo.png" {
+		t.Fatalf("GET /static/uploads/photo.png = %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/media/photo.png", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "png" {
+		t.Errorf("GET /media/photo.png = %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alextreichler/personal-website/internal/imagegen"
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+const ogSiteName = "alextreichler.com"
+
+// ogCard builds the text shown on a post's generated sharing card.
+func ogCard(post *models.Post) imagegen.Card {
+	return imagegen.Card{
+		Title:       post.Title,
+		Date:        post.CreatedAt.Format("January 02, 2006"),
+		ReadingTime: post.ReadingTime(),
+		SiteName:    ogSiteName,
+	}
+}
+
+// ogVersion fingerprints the card text, so a changed title produces a new file.
+func ogVersion(c imagegen.Card) string {
+	sum := sha256.Sum256([]byte(c.Title + "\x00" + c.Date + "\x00" + c.ReadingTime + "\x00" + c.SiteName))
+	return hex.EncodeToString(sum[:6])
+}
+
+// ogImageURL returns the absolute sharing image URL for a post: its cover
+// image when one is set, otherwise the generated card.
+func (app *App) ogImageURL(r *http.Request, post *models.Post) string {
+	if post.Cover != nil {
+		return app.URLs.Abs(r, app.coverURL(post.Cover))
+	}
+	return app.URLs.Abs(r, fmt.Sprintf("/og/%s.png?v=%s", post.Slug, ogVersion(ogCard(post))))
+}
+
+// OGImage serves the generated Open Graph card for a post, rendering it on
+// first request and caching the PNG on disk.
+func (app *App) OGImage(w http.ResponseWriter, r *http.Request) {
+	slug := strings.TrimSuffix(r.PathValue("slug"), ".png")
+	if slug == "" || strings.ContainsAny(slug, `/\.`) {
+		app.NotFound(w, r)
+		return
+	}
+
+	post, err := app.DB.GetPostBySlug(slug)
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+
+	card := ogCard(post)
+	file := filepath.Join(app.Config.OGCachePath, slug+"."+ogVersion(card)+".png")
+	if _, err := os.Stat(file); err != nil {
+		if err := writeOGCard(file, slug, card); err != nil {
+			slog.Error("Error generating OG image", "slug", slug, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFile(w, r, file)
+}
+
+// writeOGCard renders a card to file and removes older versions for the slug.
+func writeOGCard(file, slug string, card imagegen.Card) error {
+	img, err := imagegen.OGCard(card)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".og-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	stale, _ := filepath.Glob(filepath.Join(filepath.Dir(file), slug+".*.png"))
+	for _, old := range stale {
+		if old != file && strings.Count(filepath.Base(old), ".") == 2 {
+			os.Remove(old)
+		}
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/jsonld"
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/render"
+	"github.com/alextreichler/personal-website/internal/repository"
+)
+
+// reservedSlugs are first path segments used by other routes, which pages
+// served at /{slug} must not shadow.
+var reservedSlugs = map[string]bool{
+	"admin":       true,
+	"archive":     true,
+	"attachments": true,
+	"healthz":     true,
+	"logout":      true,
+	"media":       true,
+	"metrics":     true,
+	"og":          true,
+	"post":        true,
+	"preview":     true,
+	"robots.txt":  true,
+	"rss.xml":     true,
+	"search":      true,
+	"series":      true,
+	"sitemap":     true,
+	"sitemap.xml": true,
+	"static":      true,
+	"tag":         true,
+}
+
+// pageSlugAllowed reports whether a page may use slug. Besides the reserved
+// route names, four-digit slugs are kept for yearly archives.
+func pageSlugAllowed(slug string) bool {
+	if slug == "" || reservedSlugs[slug] {
+		return false
+	}
+	if len(slug) == 4 && strings.Trim(slug, "0123456789") == "" {
+		return false
+	}
+	return true
+}
+
+// navPages lists the pages linked from the site menu.
+func (app *App) navPages() []*models.Page {
+	pages, err := app.DB.GetNavPages()
+	if err != nil {
+		slog.Error("Error loading navigation pages", "error", err)
+	}
+	return pages
+}
+
+// ViewPage serves a published page such as /about or /now.
+func (app *App) ViewPage(w http.ResponseWriter, r *http.Request) {
+	page, err := app.DB.GetPublishedPageBySlug(r.PathValue("slug"))
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+
+	if render.Stale(page.HTMLContent, page.RenderVersion) {
+		if err := app.rerenderPage(page); err != nil {
+			slog.Error("Error rendering markdown", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	safeHTML := page.HTMLContent
+
+	data := map[string]interface{}{
+		"Page":            page,
+		"ContentHTML":     template.HTML(safeHTML),
+		"PageTitle":       page.Title,
+		"MetaDescription": page.Description(),
+	}
+
+	site := app.URLs.Origin(r)
+	setJSONLD(data, jsonld.NewBreadcrumbList(
+		jsonld.Crumb{Name: "Home", URL: site + "/"},
+		jsonld.Crumb{Name: page.Title, URL: site + page.URL()},
+	))
+
+	cacheFor(r, repository.ChangePages)
+	app.Render(w, r, "page.html", data)
+}
+
+func (app *App) AdminListPages(w http.ResponseWriter, r *http.Request) {
+	pages, err := app.DB.GetAllPages()
+	if err != nil {
+		slog.Error("Error loading pages", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Pages":     pages,
+		"PageTitle": "Pages",
+	}
+	app.Render(w, r, "admin_pages.html", data)
+}
+
+func (app *App) AdminNewPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"Page":      &models.Page{Status: "draft"},
+		"PageTitle": "New Page",
+	}
+	app.Render(w, r, "admin_page_edit.html", data)
+}
+
+// pageFromForm validates the page editor fields into p, returning a message
+// for the first invalid field.
+func (app *App) pageFromForm(r *http.Request, p *models.Page) string {
+	p.Title = strings.TrimSpace(r.FormValue("title"))
+	p.Content = r.FormValue("content")
+	p.MetaDescription = strings.TrimSpace(r.FormValue("meta_description"))
+	p.Status = r.FormValue("status")
+	if p.Status != "draft" && p.Status != "published" {
+		p.Status = "draft"
+	}
+	p.ShowInNav = r.FormValue("show_in_nav") != ""
+	p.NavOrder, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("nav_order")))
+
+	p.Slug = slugify(r.FormValue("slug"))
+	if p.Slug == "" {
+		p.Slug = slugify(p.Title)
+	}
+
+	switch {
+	case p.Title == "":
+		return "Title cannot be empty"
+	case strings.TrimSpace(p.Content) == "":
+		return "Content cannot be empty"
+	case !pageSlugAllowed(p.Slug):
+		return "The slug \"" + p.Slug + "\" is reserved, please choose another"
+	}
+
+	if safeHTML, err := app.Renderer.HTML(p.Content); err == nil {
+		p.HTMLContent, p.RenderVersion = safeHTML, render.Version
+	}
+	return ""
+}
+
+func (app *App) AdminCreatePage(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	page := &models.Page{CreatedAt: now, UpdatedAt: now}
+	if msg := app.pageFromForm(r, page); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if err := app.DB.CreatePage(page); err != nil {
+		slog.Error("Error creating page", "error", err)
+		http.Error(w, "Error creating page (is the slug already taken?)", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/pages", http.StatusSeeOther)
+}
+
+func (app *App) AdminEditPage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid page ID", http.StatusBadRequest)
+		return
+	}
+	page, err := app.DB.GetPageByID(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Page":      page,
+		"PageTitle": "Edit Page",
+	}
+	app.Render(w, r, "admin_page_edit.html", data)
+}
+
+func (app *App) AdminUpdatePage(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid page ID", http.StatusBadRequest)
+		return
+	}
+	page, err := app.DB.GetPageByID(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if msg := app.pageFromForm(r, page); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	page.UpdatedAt = time.Now()
+	if err := app.DB.UpdatePage(page); err != nil {
+		slog.Error("Error updating page", "error", err)
+		http.Error(w, "Error updating page (is the slug already taken?)", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/pages", http.StatusSeeOther)
+}
+
+func (app *App) AdminDeletePage(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid page ID", http.StatusBadRequest)
+		return
+	}
+	if err := app.DB.DeletePage(id); err != nil {
+		slog.Error("Error deleting page", "error", err)
+		http.Error(w, "Error deleting page", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/pages", http.StatusSeeOther)
+}
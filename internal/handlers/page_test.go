@@ -0,0 +1,25 @@
+package handlers
+
+import "testing"
+
+func TestPageSlugAllowed(t *testing.T) {
+	tests := []struct {
+		slug string
+		want bool
+	}{
+		{"about", true},
+		{"now", true},
+		{"uses", true},
+		{"2024-goals", true},
+		{"", false},
+		{"admin", false},
+		{"search", false},
+		{"rss.xml", false},
+		{"2024", false},
+	}
+	for _, tt := range tests {
+		if got := pageSlugAllowed(tt.slug); got != tt.want {
+			t.Errorf("pageSlugAllowed(%q) = %v, want %v", tt.slug, got, tt.want)
+		}
+	}
+}
@@ -1,33 +1,24 @@
 package handlers
 
 import (
-	"bytes"
-	"fmt"
+	"errors"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv" // Added this import
 	"strings"
 	"time"
 
+	"github.com/alextreichler/personal-website/internal/imagegen"
+	"github.com/alextreichler/personal-website/internal/jsonld"
+	"github.com/alextreichler/personal-website/internal/markdown"
 	"github.com/alextreichler/personal-website/internal/models"
-	"github.com/microcosm-cc/bluemonday"
-	"github.com/yuin/goldmark"
-	highlighting "github.com/yuin/goldmark-highlighting/v2"
-	"golang.org/x/net/html"
+	"github.com/alextreichler/personal-website/internal/pagecache"
+	"github.com/alextreichler/personal-website/internal/render"
+	"github.com/alextreichler/personal-website/internal/repository"
 )
 
-// Helper to get a configured Goldmark instance
-func getMarkdown() goldmark.Markdown {
-	return goldmark.New(
-		goldmark.WithExtensions(
-			highlighting.NewHighlighting(
-				highlighting.WithStyle("dracula"),
-			),
-		),
-	)
-}
-
 func (app *App) ViewPost(w http.ResponseWriter, r *http.Request) {
 	slug := strings.TrimPrefix(r.URL.Path, "/post/")
 
@@ -36,68 +27,170 @@ func (app *App) ViewPost(w http.ResponseWriter, r *http.Request) {
 		app.NotFound(w, r)
 		return
 	}
+	app.countView(slug)
+	pagecache.OnHit(r, func() { app.countView(slug) })
 
-	var safeHTML string
-	if post.HTMLContent != "" {
-		// Use cached content
-		safeHTML = post.HTMLContent
-	} else {
-		// Fallback: Render on the fly
-		var buf bytes.Buffer
-		md := getMarkdown()
-		if err := md.Convert([]byte(post.Content), &buf); err != nil {
+	// Use cached content unless an older pipeline produced it
+	if render.Stale(post.HTMLContent, post.RenderVersion) {
+		if err := app.rerenderPost(post); err != nil {
 			slog.Error("Error rendering markdown", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-		
-p := bluemonday.UGCPolicy()
-		p.AllowAttrs("style").OnElements("pre", "code", "span")
-		safeHTML = p.Sanitize(buf.String())
-		
-		// Inject loading="lazy" into images
-		safeHTML = strings.ReplaceAll(safeHTML, "<img ", "<img loading=\"lazy\" ")
-		
-		// Inject srcset for responsive images
-		safeHTML = injectSrcset(safeHTML)
 	}
 
-	// Create description snippet (first 150 chars)
-	desc := post.Content
-	if len(desc) > 150 {
-		desc = desc[:150] + "..."
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags, repository.ChangeSeries, repository.ChangePages)
+	app.showPost(w, r, post, map[string]interface{}{})
+}
+
+// countView counts a view of a published post.
+func (app *App) countView(slug string) {
+	if err := app.DB.IncrementViews(slug); err != nil {
+		slog.Error("Error counting view", "slug", slug, "error", err)
 	}
+}
 
-	data := map[string]interface{}{
-		"Post":            post,
-		"ContentHTML":     template.HTML(safeHTML),
-		"PageTitle":       post.Title,
-		"MetaDescription": desc,
+// showPost renders a post whose HTMLContent is up to date, adding to data.
+func (app *App) showPost(w http.ResponseWriter, r *http.Request, post *models.Post, data map[string]interface{}) {
+	slug := post.Slug
+	safeHTML := post.HTMLContent
+
+	// Load the audio attachment, if any
+	if post.AudioMediaID != 0 {
+		if audio, err := app.DB.GetMediaByID(post.AudioMediaID); err == nil {
+			post.Audio = audio
+		}
+	}
+	if post.CoverMediaID != 0 {
+		if cover, err := app.DB.GetMediaByID(post.CoverMediaID); err == nil && cover.IsImage() {
+			post.Cover = cover
+		}
 	}
 
-	app.Render(w, r, "post.html", data)
-}
+	data["Post"] = post
+	data["ContentHTML"] = template.HTML(safeHTML)
+	data["PageTitle"] = post.Title
+	data["MetaDescription"] = post.Description()
+	data["OGImage"] = app.ogImageURL(r, post)
+	if post.Cover != nil {
+		data["CoverURL"] = app.coverURL(post.Cover)
+	} else {
+		data["OGImageWidth"] = imagegen.OGWidth
+		data["OGImageHeight"] = imagegen.OGHeight
+	}
 
+	// Long posts get a table of contents
+	if toc := markdown.TOC(safeHTML); len(toc) >= markdown.TOCMinHeadings {
+		data["TOC"] = toc
+	}
 
-func (app *App) AdminListPosts(w http.ResponseWriter, r *http.Request) {
-	posts, err := app.DB.GetAllPosts()
+	prev, next, err := app.DB.GetAdjacentPosts(post.ID)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		slog.Error("Error loading adjacent posts", "slug", slug, "error", err)
 	}
+	data["PrevPost"] = prev
+	data["NextPost"] = next
 
-	data := map[string]interface{}{
-		"Posts": posts,
+	// Series table of contents
+	if post.SeriesID != 0 {
+		if series, err := app.DB.GetSeriesByID(post.SeriesID); err == nil && app.loadSeries(series) == nil {
+			post.Series = series
+		}
+	}
+
+	relatedPosts, err := app.DB.GetRelatedPosts(post.ID)
+	if err != nil {
+		slog.Error("Error loading related posts", "slug", slug, "error", err)
 	}
+	data["RelatedPosts"] = relatedPosts
 
-	app.Render(w, r, "admin_posts.html", data)
+	site := app.URLs.Origin(r)
+	crumbs := []jsonld.Crumb{{Name: "Home", URL: site + "/"}}
+	if len(post.Tags) > 0 {
+		crumbs = append(crumbs, jsonld.Crumb{Name: "#" + post.Tags[0], URL: site + "/tag/" + url.PathEscape(post.Tags[0])})
+	}
+	crumbs = append(crumbs, jsonld.Crumb{Name: post.Title, URL: site + "/post/" + post.Slug})
+	setJSONLD(data,
+		jsonld.NewBlogPosting(post, site, siteOwner, data["OGImage"].(string)),
+		jsonld.NewBreadcrumbList(crumbs...),
+	)
+	if post.Audio != nil {
+		data["AudioURL"] = app.Storage.URL(post.Audio.StorageKey)
+	}
+
+	app.Render(w, r, "post.html", data)
 }
 
+
 func (app *App) AdminNewPost(w http.ResponseWriter, r *http.Request) {
 	data := make(map[string]interface{})
+	data["AudioMedia"] = app.audioChoices()
+	data["ImageMedia"] = app.imageChoices()
+	data["AllSeries"] = app.seriesChoices()
+	if autosave, err := app.DB.GetNewPostAutosave(app.CurrentUser(r)); err == nil {
+		data["Autosave"] = autosave
+	}
 	app.Render(w, r, "admin_post_new.html", data)
 }
 
+// audioChoices lists the audio files that can be attached to a post.
+func (app *App) audioChoices() []*models.Media {
+	audio, err := app.DB.GetAllMedia("audio/")
+	if err != nil {
+		slog.Error("Error loading audio media", "error", err)
+	}
+	return audio
+}
+
+// coverURL returns the URL of a cover image, preferring its optimized variant.
+func (app *App) coverURL(m *models.Media) string {
+	if m.PreviewKey != "" {
+		return app.Storage.URL(m.PreviewKey)
+	}
+	return app.Storage.URL(m.StorageKey)
+}
+
+// coverURLs maps post IDs to cover image URLs for posts that have one. The
+// covers are loaded in one query.
+func (app *App) coverURLs(posts []*models.Post) map[int]string {
+	urls := make(map[int]string)
+	var ids []int
+	for _, post := range posts {
+		if post.CoverMediaID != 0 {
+			ids = append(ids, post.CoverMediaID)
+		}
+	}
+	covers, err := app.DB.GetMediaByIDs(ids)
+	if err != nil {
+		slog.Error("Error loading cover images", "error", err)
+		return urls
+	}
+	for _, post := range posts {
+		if cover, ok := covers[post.CoverMediaID]; ok && cover.IsImage() {
+			urls[post.ID] = app.coverURL(cover)
+		}
+	}
+	return urls
+}
+
+// imageChoices lists the images that can be used as a post cover.
+func (app *App) imageChoices() []*models.Media {
+	images, err := app.DB.GetAllMedia("image/")
+	if err != nil {
+		slog.Error("Error loading image media", "error", err)
+	}
+	return images
+}
+
+// formID reads an optional ID from a form field; empty means none.
+func formID(r *http.Request, field string) int {
+	id, err := strconv.Atoi(r.FormValue(field))
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
 func (app *App) AdminCreatePost(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -141,41 +234,37 @@ func (app *App) AdminCreatePost(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: now,
 		UpdatedAt: now,
 		Views:     0,
+
+		AudioMediaID: formID(r, "audio_media_id"),
+		CoverMediaID: formID(r, "cover_media_id"),
+
+		Excerpt:         strings.TrimSpace(r.FormValue("excerpt")),
+		MetaDescription: strings.TrimSpace(r.FormValue("meta_description")),
 	}
+	app.applySeriesForm(r, post)
 
 	// Render Markdown to HTML for caching
-	var buf bytes.Buffer
-	md := getMarkdown()
-	if err := md.Convert([]byte(content), &buf); err == nil {
-		p := bluemonday.UGCPolicy()
-		p.AllowAttrs("style").OnElements("pre", "code", "span")
-		safeHTML := p.Sanitize(buf.String())
-		safeHTML = strings.ReplaceAll(safeHTML, "<img ", "<img loading=\"lazy\" ")
-		safeHTML = injectSrcset(safeHTML)
-		post.HTMLContent = safeHTML
-	}
-
-	// We need the ID to set tags, so CreatePost must return the ID or update the struct
-	// Assuming CreatePost in db.go uses LastInsertId and likely doesn't return it easily
-	// Let's modify CreatePost in db.go to return ID or we assume title+slug is unique enough?
-	// Better: Update CreatePost to use LastInsertId. 
-	// For now, let's modify db.go CreatePost signature first, OR fetch it back.
-	// Let's fetch it back by slug since slug is unique.
-	
+	if safeHTML, err := app.Renderer.HTML(content); err == nil {
+		post.HTMLContent, post.RenderVersion = safeHTML, render.Version
+	}
+
 	if err := app.DB.CreatePost(post); err != nil {
 		slog.Error("Error creating post", "error", err)
 		http.Error(w, "Error creating post", http.StatusInternalServerError)
 		return
 	}
-	
-	// Fetch back to get ID
-	createdPost, err := app.DB.GetPostBySlug(post.Slug)
-	if err == nil {
-		tags := strings.Split(tagsInput, ",")
-		app.DB.SetPostTags(createdPost.ID, tags)
+
+	// CreatePost sets the ID, drafts included
+	tags := strings.Split(tagsInput, ",")
+	if err := app.DB.SetPostTags(post.ID, tags); err != nil {
+		slog.Error("Error setting tags", "error", err)
+	}
+	if err := app.DB.DeleteNewPostAutosave(app.CurrentUser(r)); err != nil {
+		slog.Error("Error clearing new post autosave", "error", err)
 	}
+	app.RefreshRelated()
 
-	http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
+	app.redirectAfterSave(w, r, post)
 }
 
 func slugify(s string) string {
@@ -195,84 +284,6 @@ func slugify(s string) string {
 	return s
 }
 
-// injectSrcset parses the HTML and adds srcset attributes to our optimized images
-func injectSrcset(htmlContent string) string {
-	doc, err := html.Parse(strings.NewReader(htmlContent))
-	if err != nil {
-		return htmlContent // Fallback to original if parsing fails
-	}
-
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "img" {
-			var src string
-			for _, a := range n.Attr {
-				if a.Key == "src" {
-					src = a.Val
-					break
-				}
-			}
-
-			// Check if this is one of our optimized images
-			// Expected format: .../optimized/optimized_UUID.webp
-			if strings.Contains(src, "/optimized/optimized_") && strings.HasSuffix(src, ".webp") {
-				base := strings.TrimSuffix(src, ".webp")
-				
-				// Construct srcset
-				// We have: base.webp (1200w), base_800w.webp, base_400w.webp
-				srcset := fmt.Sprintf("%s_400w.webp 400w, %s_800w.webp 800w, %s.webp 1200w", base, base, base)
-				
-				// Add srcset attribute
-				n.Attr = append(n.Attr, html.Attribute{Key: "srcset", Val: srcset})
-				
-				// Add sizes attribute
-				sizes := "(max-width: 600px) 400px, (max-width: 900px) 800px, 1200px"
-				n.Attr = append(n.Attr, html.Attribute{Key: "sizes", Val: sizes})
-			}
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
-	}
-	f(doc)
-
-	var buf bytes.Buffer
-	if err := html.Render(&buf, doc); err != nil {
-		return htmlContent
-	}
-	
-	// html.Render wraps content in <html><head></head><body>...</body></html> if it's a full doc,
-	// or just nodes. Since we parsed a fragment (likely), html.Parse might add html/body tags.
-	// Let's check. html.Parse usually expects a full doc.
-	// For fragments, we should traverse the body's children.
-	// However, simple hack: render and strip the tags if they were added, or just return the body content.
-	// Actually, for post content, it's a fragment. html.Parse will put it in <html><body>...
-	
-	// Correct approach for fragment:
-	// Find <body> and render its children
-	var body *html.Node
-	var findBody func(*html.Node)
-	findBody = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "body" {
-			body = n
-			return
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			findBody(c)
-		}
-	}
-	findBody(doc)
-	
-	if body != nil {
-		var bodyBuf bytes.Buffer
-		for c := body.FirstChild; c != nil; c = c.NextSibling {
-			html.Render(&bodyBuf, c)
-		}
-		return bodyBuf.String()
-	}
-
-	return buf.String()
-}
 
 func (app *App) AdminEditPost(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
@@ -291,6 +302,25 @@ func (app *App) AdminEditPost(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
 		"Post":       post,
 		"TagsString": strings.Join(post.Tags, ", "),
+		"AudioMedia": app.audioChoices(),
+		"ImageMedia": app.imageChoices(),
+		"AllSeries":  app.seriesChoices(),
+	}
+
+	// Related posts and the admin's overrides
+	if related, err := app.DB.GetRelatedPosts(post.ID); err == nil {
+		data["RelatedPosts"] = related
+	}
+	if overrides, err := app.DB.GetRelatedOverrides(post.ID); err == nil {
+		data["RelatedOverrides"] = overrides
+	}
+	if published, err := app.DB.GetArchivePosts(""); err == nil {
+		data["PublishedPosts"] = published
+	}
+	data["BrokenLinks"] = app.postBrokenLinks(post.ID)
+	data["PreviewURL"], data["PreviewExpires"] = app.previewURL(r, post)
+	if autosave := app.newerAutosave(post); autosave != nil {
+		data["Autosave"] = autosave
 	}
 
 	app.Render(w, r, "admin_post_edit.html", data)
@@ -316,6 +346,12 @@ func (app *App) AdminUpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse to overwrite a save made since this editor was opened
+	if version, err := strconv.Atoi(r.FormValue("version")); err == nil && version != post.Version {
+		app.postConflict(w, r, post.ID)
+		return
+	}
+
 	// --- Input Validation ---
 	title := r.FormValue("title")
 	content := r.FormValue("content")
@@ -345,6 +381,11 @@ func (app *App) AdminUpdatePost(w http.ResponseWriter, r *http.Request) {
 	post.Title = title
 	post.Content = content
 	post.Status = status
+	post.AudioMediaID = formID(r, "audio_media_id")
+	post.CoverMediaID = formID(r, "cover_media_id")
+	post.Excerpt = strings.TrimSpace(r.FormValue("excerpt"))
+	post.MetaDescription = strings.TrimSpace(r.FormValue("meta_description"))
+	app.applySeriesForm(r, post)
 
 	if slug == "" {
 		post.Slug = slugify(post.Title)
@@ -354,33 +395,35 @@ func (app *App) AdminUpdatePost(w http.ResponseWriter, r *http.Request) {
 	post.UpdatedAt = now
 	
 	// Render Markdown to HTML for caching
-	var buf bytes.Buffer
-	md := getMarkdown()
-	if err := md.Convert([]byte(content), &buf); err == nil {
-		p := bluemonday.UGCPolicy()
-		p.AllowAttrs("style").OnElements("pre", "code", "span")
-		safeHTML := p.Sanitize(buf.String())
-		safeHTML = strings.ReplaceAll(safeHTML, "<img ", "<img loading=\"lazy\" ")
-		safeHTML = injectSrcset(safeHTML)
-		post.HTMLContent = safeHTML
+	if safeHTML, err := app.Renderer.HTML(content); err == nil {
+		post.HTMLContent, post.RenderVersion = safeHTML, render.Version
 	}
 	
 	// --- End Input Validation ---
 
 	err = app.DB.UpdatePost(post)
+	if errors.Is(err, repository.ErrConflict) {
+		app.postConflict(w, r, post.ID)
+		return
+	}
 	if err != nil {
 		slog.Error("Error updating post", "error", err)
 		http.Error(w, "Error updating post", http.StatusInternalServerError)
 		return
 	}
 
+	if err := app.DB.DeleteAutosave(post.ID); err != nil {
+		slog.Error("Error clearing autosave", "id", post.ID, "error", err)
+	}
+
 	// Update Tags
 	tags := strings.Split(tagsInput, ",")
 	if err := app.DB.SetPostTags(post.ID, tags); err != nil {
 		slog.Error("Error updating tags", "error", err)
 	}
+	app.RefreshRelated()
 
-	http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
+	app.redirectAfterSave(w, r, post)
 }
 
 func (app *App) AdminDeletePost(w http.ResponseWriter, r *http.Request) {
@@ -403,6 +446,7 @@ func (app *App) AdminDeletePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Error deleting post", http.StatusInternalServerError)
 		return
 	}
+	app.RefreshRelated()
 
 	http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
 }
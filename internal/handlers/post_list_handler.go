@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/repository"
+)
+
+// adminPostsPerPage is the page size of the admin post list.
+const adminPostsPerPage = 25
+
+// postListFilter reads the admin post list's filters, sort order and page
+// from the query string.
+func postListFilter(q url.Values) (repository.PostFilter, int) {
+	f := repository.PostFilter{
+		Status: q.Get("status"),
+		Tag:    strings.TrimSpace(q.Get("tag")),
+		Query:  strings.TrimSpace(q.Get("q")),
+		Sort:   q.Get("sort"),
+		Desc:   q.Get("dir") != "asc",
+		Limit:  adminPostsPerPage,
+	}
+	if f.Status != "draft" && f.Status != "published" {
+		f.Status = ""
+	}
+	if f.Sort == "" {
+		f.Sort = "created_at"
+	}
+	if from, err := time.Parse("2006-01-02", q.Get("from")); err == nil {
+		f.From = from
+	}
+	// The end date is inclusive in the form
+	if to, err := time.Parse("2006-01-02", q.Get("to")); err == nil {
+		f.To = to.AddDate(0, 0, 1)
+	}
+
+	page, err := strconv.Atoi(q.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	f.Offset = (page - 1) * adminPostsPerPage
+	return f, page
+}
+
+// postListURL links to the admin post list with q, changing the given
+// parameters.
+func postListURL(q url.Values, set ...string) string {
+	v := url.Values{}
+	for k, vals := range q {
+		v[k] = vals
+	}
+	for i := 0; i+1 < len(set); i += 2 {
+		v.Set(set[i], set[i+1])
+	}
+	for k, vals := range v {
+		if len(vals) == 0 || vals[0] == "" {
+			delete(v, k)
+		}
+	}
+	if len(v) == 0 {
+		return "/admin/posts"
+	}
+	return "/admin/posts?" + v.Encode()
+}
+
+func (app *App) AdminListPosts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	changed := q.Get("changed")
+	q.Del("changed")
+	filter, page := postListFilter(q)
+
+	posts, total, err := app.DB.ListPosts(filter)
+	if err != nil {
+		slog.Error("Error listing posts", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	totalPages := (total + adminPostsPerPage - 1) / adminPostsPerPage
+
+	// Column headers sort by their column, toggling the direction when
+	// already sorted by it
+	sortURLs := make(map[string]string)
+	for _, col := range repository.PostSortColumns {
+		dir := "desc"
+		if col == filter.Sort && filter.Desc {
+			dir = "asc"
+		}
+		sortURLs[col] = postListURL(q, "sort", col, "dir", dir, "page", "")
+	}
+
+	data := map[string]interface{}{
+		"PageTitle":  "Manage Posts",
+		"Posts":      posts,
+		"Total":      total,
+		"Filter":     q,
+		"Sort":       filter.Sort,
+		"Desc":       filter.Desc,
+		"SortURLs":   sortURLs,
+		"Page":       page,
+		"TotalPages": totalPages,
+		"Return":     q.Encode(),
+		"Changed":    changed,
+	}
+	if page > 1 {
+		data["PrevURL"] = postListURL(q, "page", strconv.Itoa(page-1))
+	}
+	if page < totalPages {
+		data["NextURL"] = postListURL(q, "page", strconv.Itoa(page+1))
+	}
+	if tags, err := app.DB.GetPublishedTags(); err == nil {
+		data["AllTags"] = tags
+	}
+
+	app.Render(w, r, "admin_posts.html", data)
+}
+
+// AdminBulkPosts applies one action to the posts selected in the post list
+// and returns to the list as it was.
+func (app *App) AdminBulkPosts(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	var ids []int
+	for _, v := range r.PostForm["id"] {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	action := r.FormValue("action")
+	switch action {
+	case repository.BulkPublish, repository.BulkUnpublish, repository.BulkDelete:
+	case repository.BulkAddTag, repository.BulkRemoveTag:
+		if strings.TrimSpace(r.FormValue("tag")) == "" {
+			http.Error(w, "Enter the tag to add or remove", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	changed, err := app.DB.BulkUpdatePosts(ids, action, r.FormValue("tag"))
+	if err != nil {
+		slog.Error("Error updating posts", "action", action, "error", err)
+		http.Error(w, "Error updating posts", http.StatusInternalServerError)
+		return
+	}
+	if changed > 0 {
+		app.RefreshRelated()
+	}
+
+	// Return to the same filters, sort and page
+	q, err := url.ParseQuery(r.FormValue("return"))
+	if err != nil {
+		q = url.Values{}
+	}
+	http.Redirect(w, r, postListURL(q, "changed", strconv.FormatInt(changed, 10)), http.StatusSeeOther)
+}
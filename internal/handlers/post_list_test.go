@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPostListFilter(t *testing.T) {
+	q, _ := url.ParseQuery("status=bogus&tag=+Go+&from=2024-01-01&to=2024-01-31&sort=title&dir=asc&page=3")
+	f, page := postListFilter(q)
+
+	if f.Status != "" {
+		t.Errorf("Status = %q, want unknown statuses ignored", f.Status)
+	}
+	if f.Tag != "Go" {
+		t.Errorf("Tag = %q, want trimmed", f.Tag)
+	}
+	if want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC); !f.To.Equal(want) {
+		t.Errorf("To = %v, want the day after the inclusive end date", f.To)
+	}
+	if f.Sort != "title" || f.Desc {
+		t.Errorf("Sort = %q desc=%v, want title ascending", f.Sort, f.Desc)
+	}
+	if page != 3 || f.Offset != 2*adminPostsPerPage {
+		t.Errorf("page %d offset %d, want page 3", page, f.Offset)
+	}
+}
+
+func TestPostListURL(t *testing.T) {
+	q, _ := url.ParseQuery("q=hello&page=2")
+	if got, want := postListURL(q, "page", ""), "/admin/posts?q=hello"; got != want {
+		t.Errorf("postListURL = %q, want %q", got, want)
+	}
+	if got, want := postListURL(url.Values{}), "/admin/posts"; got != want {
+		t.Errorf("postListURL = %q, want %q", got, want)
+	}
+	if q.Get("page") != "2" {
+		t.Error("postListURL modified its input")
+	}
+}
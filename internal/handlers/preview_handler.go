@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/auth"
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/render"
+)
+
+// previewPurpose scopes signed share links so they are only accepted here.
+const previewPurpose = "preview"
+
+// previewRenderer renders editor previews like published content, but
+// without logging broken post links on every keystroke.
+func (app *App) previewRenderer() *render.Pipeline {
+	p := *app.Renderer
+	p.PostTitle = func(slug string) (string, bool) {
+		post, err := app.DB.GetPostBySlug(slug)
+		if err != nil {
+			return "", false
+		}
+		return post.Title, true
+	}
+	return &p
+}
+
+// AdminPreview renders the editor's Markdown through the publishing pipeline
+// and returns the HTML with word count and reading time as JSON.
+func (app *App) AdminPreview(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	content := r.FormValue("content")
+	safeHTML, err := app.previewRenderer().HTML(content)
+	if err != nil {
+		slog.Error("Error rendering preview", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	post := &models.Post{Content: content, HTMLContent: safeHTML}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"html":         safeHTML,
+		"word_count":   post.WordCount(),
+		"reading_time": post.ReadingTime(),
+	})
+}
+
+// previewURL returns a link that shows the post as published, without
+// logging in, until the returned time.
+func (app *App) previewURL(r *http.Request, post *models.Post) (string, time.Time) {
+	expires := time.Now().Add(app.Config.PreviewLinkTTL)
+	token := auth.SignFor(previewPurpose, strconv.Itoa(post.ID), expires)
+	return app.URLs.Origin(r) + "/preview/" + token, expires
+}
+
+// SharedPreview serves a post, draft or not, to anyone holding a valid share
+// link. The content is rendered fresh so reviewers see the latest edits.
+func (app *App) SharedPreview(w http.ResponseWriter, r *http.Request) {
+	data, err := auth.VerifyFor(previewPurpose, r.PathValue("token"))
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+	id, err := strconv.Atoi(data)
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+	post, err := app.DB.GetPostByID(id)
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+
+	safeHTML, err := app.previewRenderer().HTML(post.Content)
+	if err != nil {
+		slog.Error("Error rendering markdown", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	post.HTMLContent = safeHTML
+
+	// Keep previews out of caches and search engines
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	app.showPost(w, r, post, map[string]interface{}{
+		"Preview":      true,
+		"NoIndex":      true,
+		"CanonicalURL": "",
+	})
+}
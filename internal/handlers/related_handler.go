@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/related"
+)
+
+// relatedLimit is how many related posts are shown under a post.
+const relatedLimit = 3
+
+// relatedDelay is how long a scheduled recompute of related posts waits for
+// further changes, so a burst of saves costs a single recompute.
+const relatedDelay = 2 * time.Second
+
+// RefreshRelated schedules a recompute of the related posts of every
+// published post. It is called whenever a post is created, changed or
+// deleted, since any of those can change the lists of other posts too.
+// Comparing every post with every other is too slow to do while a save
+// waits, so it returns at once and the work is done in the background.
+func (app *App) RefreshRelated() {
+	select {
+	case app.relatedPending <- struct{}{}:
+	default: // Already scheduled
+	}
+}
+
+// startRelated runs the recomputes scheduled by RefreshRelated, one at a
+// time.
+func (app *App) startRelated() {
+	app.relatedPending = make(chan struct{}, 1)
+	go func() {
+		for range app.relatedPending {
+			time.Sleep(relatedDelay)
+			// Calls made while waiting are covered by this recompute; calls
+			// made while it runs schedule the next one
+			select {
+			case <-app.relatedPending:
+			default:
+			}
+			app.refreshRelated()
+		}
+	}()
+}
+
+// refreshRelated recomputes the related posts of every published post.
+// Recomputes run one at a time, so a slow one can't overwrite the result of
+// a later one with older data.
+func (app *App) refreshRelated() {
+	app.related.Lock()
+	defer app.related.Unlock()
+
+	posts, err := app.DB.GetRelatedCorpus()
+	if err != nil {
+		slog.Error("Error loading posts for related posts", "error", err)
+		return
+	}
+	overrides, err := app.DB.GetRelatedOverrides(0)
+	if err != nil {
+		slog.Error("Error loading related post overrides", "error", err)
+		return
+	}
+	if err := app.DB.ReplaceRelatedPosts(related.Compute(posts, overrides, relatedLimit)); err != nil {
+		slog.Error("Error storing related posts", "error", err)
+	}
+}
+
+// AdminRelatedOverride pins, excludes or resets a post in another post's
+// related list.
+func (app *App) AdminRelatedOverride(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+	relatedID, err := strconv.Atoi(r.FormValue("related_id"))
+	if err != nil || relatedID == postID {
+		http.Error(w, "Invalid related post ID", http.StatusBadRequest)
+		return
+	}
+
+	action := r.FormValue("action")
+	switch action {
+	case models.RelatedPin, models.RelatedExclude:
+	case "clear":
+		action = ""
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.DB.SetRelatedOverride(postID, relatedID, action); err != nil {
+		slog.Error("Error saving related post override", "error", err)
+		http.Error(w, "Error saving override", http.StatusInternalServerError)
+		return
+	}
+	// The editor is about to show the result, so don't wait for it
+	app.refreshRelated()
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/posts/edit?id=%d#related", postID), http.StatusSeeOther)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/render"
+)
+
+// postTitle looks up a published post for a post-link shortcode, logging
+// links to posts that don't exist.
+func (app *App) postTitle(slug string) (string, bool) {
+	post, err := app.DB.GetPostBySlug(slug)
+	if err != nil {
+		slog.Warn("Broken post link", "slug", slug, "error", err)
+		return "", false
+	}
+	return post.Title, true
+}
+
+// rerenderPost rebuilds the cached HTML of a post and stores it.
+func (app *App) rerenderPost(post *models.Post) error {
+	safeHTML, err := app.Renderer.HTML(post.Content)
+	if err != nil {
+		return err
+	}
+	post.HTMLContent, post.RenderVersion = safeHTML, render.Version
+	if err := app.DB.UpdatePostHTML(post.ID, post.Content, safeHTML, render.Version); err != nil {
+		slog.Error("Error storing rendered post", "id", post.ID, "error", err)
+	}
+	return nil
+}
+
+// rerenderPage rebuilds the cached HTML of a page and stores it.
+func (app *App) rerenderPage(page *models.Page) error {
+	safeHTML, err := app.Renderer.HTML(page.Content)
+	if err != nil {
+		return err
+	}
+	page.HTMLContent, page.RenderVersion = safeHTML, render.Version
+	if err := app.DB.UpdatePageHTML(page.ID, page.Content, safeHTML, render.Version); err != nil {
+		slog.Error("Error storing rendered page", "id", page.ID, "error", err)
+	}
+	return nil
+}
+
+// RerenderAll rebuilds the HTML of every post and page in the background.
+// It returns false if a rebuild is already running. Posts and pages saved
+// while it runs keep the HTML their save rendered, see UpdatePostHTML.
+func (app *App) RerenderAll() bool {
+	posts, err := app.DB.GetAllPosts()
+	if err != nil {
+		slog.Error("Error loading posts to re-render", "error", err)
+		return false
+	}
+	pages, err := app.DB.GetAllPages()
+	if err != nil {
+		slog.Error("Error loading pages to re-render", "error", err)
+		return false
+	}
+
+	started := app.Rerender.Start(len(posts)+len(pages), func(i int) error {
+		var err error
+		if i < len(posts) {
+			if err = app.rerenderPost(posts[i]); err != nil {
+				slog.Error("Error re-rendering post", "id", posts[i].ID, "error", err)
+			}
+		} else if err = app.rerenderPage(pages[i-len(posts)]); err != nil {
+			slog.Error("Error re-rendering page", "id", pages[i-len(posts)].ID, "error", err)
+		}
+		return err
+	})
+	if started {
+		slog.Info("Re-rendering content", "posts", len(posts), "pages", len(pages), "version", render.Version)
+	}
+	return started
+}
+
+// AdminRerender starts rebuilding all cached HTML and returns to the dashboard.
+func (app *App) AdminRerender(w http.ResponseWriter, r *http.Request) {
+	app.RerenderAll()
+	http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+}
+
+// AdminRerenderStatus reports the progress of the current or last rebuild.
+func (app *App) AdminRerenderStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(app.Rerender.Progress()); err != nil {
+		slog.Error("Error encoding re-render progress", "error", err)
+	}
+}
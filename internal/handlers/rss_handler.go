@@ -2,13 +2,18 @@ package handlers
 
 import (
 	"encoding/xml"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/repository"
 )
 
 type RSS struct {
 	XMLName     xml.Name `xml:"rss"`
 	Version     string   `xml:"version,attr"`
+	ItunesNS    string   `xml:"xmlns:itunes,attr"`
 	Channel     Channel  `xml:"channel"`
 }
 
@@ -16,15 +21,39 @@ type Channel struct {
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
-	Items       []Item `xml:"item"`
+	Language    string `xml:"language"`
+
+	// iTunes podcast tags, so posts with audio work in podcast apps
+	ItunesAuthor   string          `xml:"itunes:author"`
+	ItunesSummary  string          `xml:"itunes:summary"`
+	ItunesExplicit string          `xml:"itunes:explicit"`
+	ItunesCategory *ItunesCategory `xml:"itunes:category"`
+
+	Items []Item `xml:"item"`
+}
+
+type ItunesCategory struct {
+	Text string `xml:"text,attr"`
 }
 
 type Item struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	Guid        string `xml:"guid"`
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description"`
+	PubDate     string     `xml:"pubDate"`
+	Guid        string     `xml:"guid"`
+	Enclosure   *Enclosure `xml:"enclosure"`
+
+	ItunesAuthor   string `xml:"itunes:author,omitempty"`
+	ItunesSummary  string `xml:"itunes:summary,omitempty"`
+	ItunesExplicit string `xml:"itunes:explicit,omitempty"`
+}
+
+// Enclosure attaches a media file (podcast episode) to an item.
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
 }
 
 func (app *App) RSSFeed(w http.ResponseWriter, r *http.Request) {
@@ -35,29 +64,46 @@ func (app *App) RSSFeed(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags)
+
 	// Site configuration (could be moved to settings DB later)
-	siteTitle := "Alex Treichler's Blog"
-	siteLink := "http://localhost:6060" // TODO: Make this configurable/dynamic based on Host header
-	if r.Host != "" {
-		siteLink = "http://" + r.Host // Simple protocol assumption, ideally use config
-	}
-	siteDesc := "Personal website and blog of Alex Treichler."
+	app.writeFeed(w, r, "Alex Treichler's Blog", app.URLs.Origin(r), "Personal website and blog of Alex Treichler.", posts)
+}
+
+// writeFeed renders posts as an RSS channel with the given title, link and
+// description.
+func (app *App) writeFeed(w http.ResponseWriter, r *http.Request, siteTitle, link, siteDesc string, posts []*models.Post) {
+	siteLink := app.URLs.Origin(r)
+	siteAuthor := siteOwner
 
 	rss := RSS{
-		Version: "2.0",
+		Version:  "2.0",
+		ItunesNS: "http://www.itunes.com/dtds/podcast-1.0.dtd",
 		Channel: Channel{
-			Title:       siteTitle,
-			Link:        siteLink,
-			Description: siteDesc,
+			Title:          siteTitle,
+			Link:           link,
+			Description:    siteDesc,
+			Language:       "en",
+			ItunesAuthor:   siteAuthor,
+			ItunesSummary:  siteDesc,
+			ItunesExplicit: "false",
+			ItunesCategory: &ItunesCategory{Text: "Technology"},
 		},
 	}
-
+	// Load the audio of every episode in one query
+	var audioIDs []int
 	for _, post := range posts {
-		// Create a snippet for description
-		desc := post.Content
-		if len(desc) > 200 {
-			desc = desc[:200] + "..."
+		if post.AudioMediaID != 0 {
+			audioIDs = append(audioIDs, post.AudioMediaID)
 		}
+	}
+	audio, err := app.DB.GetMediaByIDs(audioIDs)
+	if err != nil {
+		slog.Error("Error loading podcast audio", "error", err)
+	}
+
+	for _, post := range posts {
+		desc := post.Summary()
 
 		item := Item{
 			Title:       post.Title,
@@ -66,6 +112,20 @@ func (app *App) RSSFeed(w http.ResponseWriter, r *http.Request) {
 			PubDate:     post.CreatedAt.Format(time.RFC1123Z),
 			Guid:        siteLink + "/post/" + post.Slug,
 		}
+
+		// Posts with an audio attachment become podcast episodes
+		if post.AudioMediaID != 0 {
+			if audio, ok := audio[post.AudioMediaID]; ok {
+				item.Enclosure = &Enclosure{
+					URL:    siteLink + app.Storage.URL(audio.StorageKey),
+					Length: audio.Size,
+					Type:   audio.ContentType,
+				}
+				item.ItunesAuthor = siteAuthor
+				item.ItunesSummary = desc
+				item.ItunesExplicit = "false"
+			}
+		}
 		rss.Channel.Items = append(rss.Channel.Items, item)
 	}
 
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/jsonld"
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/repository"
+)
+
+// loadSeries fetches a series with its published posts in reading order.
+func (app *App) loadSeries(s *models.Series) error {
+	posts, err := app.DB.GetSeriesPosts(s.ID)
+	if err != nil {
+		return err
+	}
+	s.Posts = posts
+	s.PostCount = len(posts)
+	return nil
+}
+
+// SeriesPage is the landing page of a series, listing its parts in order.
+func (app *App) SeriesPage(w http.ResponseWriter, r *http.Request) {
+	series, err := app.DB.GetSeriesBySlug(r.PathValue("slug"))
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+	if err := app.loadSeries(series); err != nil {
+		slog.Error("Error loading series posts", "series", series.Slug, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(series.Posts) == 0 {
+		app.NotFound(w, r)
+		return
+	}
+
+	desc := series.Description
+	if desc == "" {
+		desc = "A series of " + strconv.Itoa(len(series.Posts)) + " posts."
+	}
+	data := map[string]interface{}{
+		"Series":          series,
+		"PageTitle":       series.Title,
+		"MetaDescription": models.Truncate(desc, models.MetaDescriptionLength),
+		"FeedURL":         series.URL() + "/rss.xml",
+		"FeedTitle":       series.Title,
+	}
+
+	site := app.URLs.Origin(r)
+	setJSONLD(data, jsonld.NewBreadcrumbList(
+		jsonld.Crumb{Name: "Home", URL: site + "/"},
+		jsonld.Crumb{Name: series.Title, URL: site + series.URL()},
+	))
+
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags, repository.ChangeSeries, repository.ChangePages)
+	app.Render(w, r, "series.html", data)
+}
+
+// SeriesFeed is an RSS feed of the posts in one series.
+func (app *App) SeriesFeed(w http.ResponseWriter, r *http.Request) {
+	series, err := app.DB.GetSeriesBySlug(r.PathValue("slug"))
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+	if err := app.loadSeries(series); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	desc := series.Description
+	if desc == "" {
+		desc = series.Title
+	}
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags, repository.ChangeSeries)
+	app.writeFeed(w, r, series.Title+" - Alex Treichler", app.URLs.Abs(r, series.URL()), desc, series.Posts)
+}
+
+// seriesChoices lists every series for the post editor.
+func (app *App) seriesChoices() []*models.Series {
+	all, err := app.DB.GetAllSeries()
+	if err != nil {
+		slog.Error("Error loading series", "error", err)
+	}
+	return all
+}
+
+// applySeriesForm sets a post's series membership from the editor form. A
+// post joining a series without an explicit position goes at the end.
+func (app *App) applySeriesForm(r *http.Request, post *models.Post) {
+	seriesID := formID(r, "series_id")
+	position, err := strconv.Atoi(strings.TrimSpace(r.FormValue("series_position")))
+	if seriesID == 0 {
+		post.SeriesID, post.SeriesPosition = 0, 0
+		return
+	}
+	if err != nil || position < 1 {
+		if seriesID == post.SeriesID && post.SeriesPosition > 0 {
+			position = post.SeriesPosition
+		} else if position, err = app.DB.NextSeriesPosition(seriesID); err != nil {
+			slog.Error("Error finding series position", "series", seriesID, "error", err)
+			position = 1
+		}
+	}
+	post.SeriesID, post.SeriesPosition = seriesID, position
+}
+
+func (app *App) AdminListSeries(w http.ResponseWriter, r *http.Request) {
+	all, err := app.DB.GetAllSeries()
+	if err != nil {
+		slog.Error("Error loading series", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"AllSeries": all,
+		"PageTitle": "Series",
+	}
+	app.Render(w, r, "admin_series.html", data)
+}
+
+// seriesFromForm validates the series form fields into s.
+func seriesFromForm(r *http.Request, s *models.Series) bool {
+	s.Title = strings.TrimSpace(r.FormValue("title"))
+	s.Description = strings.TrimSpace(r.FormValue("description"))
+	s.Slug = slugify(r.FormValue("slug"))
+	if s.Slug == "" {
+		s.Slug = slugify(s.Title)
+	}
+	return s.Title != "" && s.Slug != ""
+}
+
+func (app *App) AdminCreateSeries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	series := &models.Series{CreatedAt: now, UpdatedAt: now}
+	if !seriesFromForm(r, series) {
+		http.Error(w, "Title cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if err := app.DB.CreateSeries(series); err != nil {
+		slog.Error("Error creating series", "error", err)
+		http.Error(w, "Error creating series (is the slug already taken?)", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/series", http.StatusSeeOther)
+}
+
+func (app *App) AdminEditSeries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+	series, err := app.DB.GetSeriesByID(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := app.loadSeries(series); err != nil {
+		slog.Error("Error loading series posts", "error", err)
+	}
+
+	data := map[string]interface{}{
+		"Series":    series,
+		"PageTitle": "Edit Series",
+	}
+	app.Render(w, r, "admin_series_edit.html", data)
+}
+
+func (app *App) AdminUpdateSeries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+	series, err := app.DB.GetSeriesByID(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !seriesFromForm(r, series) {
+		http.Error(w, "Title cannot be empty", http.StatusBadRequest)
+		return
+	}
+	series.UpdatedAt = time.Now()
+	if err := app.DB.UpdateSeries(series); err != nil {
+		slog.Error("Error updating series", "error", err)
+		http.Error(w, "Error updating series", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/series", http.StatusSeeOther)
+}
+
+func (app *App) AdminDeleteSeries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+	if err := app.DB.DeleteSeries(id); err != nil {
+		slog.Error("Error deleting series", "error", err)
+		http.Error(w, "Error deleting series", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/series", http.StatusSeeOther)
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+	"github.com/alextreichler/personal-website/internal/repository"
+	"golang.org/x/net/html"
+)
+
+// sitemapMaxURLs is the most URLs the sitemap protocol allows in one file.
+// Larger sites get a sitemap index pointing at numbered pages.
+var sitemapMaxURLs = 50000
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	ImageNS string       `xml:"xmlns:image,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string         `xml:"loc"`
+	LastMod string         `xml:"lastmod,omitempty"`
+	Images  []sitemapImage `xml:"image:image"`
+
+	modified time.Time
+}
+
+type sitemapImage struct {
+	Loc string `xml:"image:loc"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+const (
+	sitemapNS      = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapImageNS = "http://www.google.com/schemas/sitemap-image/1.1"
+)
+
+func newSitemapURL(loc string, modified time.Time) sitemapURL {
+	u := sitemapURL{Loc: loc, modified: modified}
+	if !modified.IsZero() {
+		u.LastMod = modified.UTC().Format(time.RFC3339)
+	}
+	return u
+}
+
+// sitemapURLs lists every public page: the homepage, posts with their
+// images, standalone pages, archive pages, series and tag pages.
+func (app *App) sitemapURLs(r *http.Request) ([]sitemapURL, error) {
+	posts, err := app.DB.GetSitemapPosts()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := app.DB.GetPublishedTags()
+	if err != nil {
+		return nil, err
+	}
+
+	// The homepage changes whenever the newest post does
+	var newest time.Time
+	for _, post := range posts {
+		if post.UpdatedAt.After(newest) {
+			newest = post.UpdatedAt
+		}
+	}
+	urls := []sitemapURL{newSitemapURL(app.URLs.Abs(r, "/"), newest)}
+
+	covers := app.coverURLs(posts)
+	abs := func(src string) string { return app.URLs.Abs(r, src) }
+	for _, post := range posts {
+		u := newSitemapURL(app.URLs.Abs(r, "/post/"+post.Slug), post.UpdatedAt)
+		var images []string
+		if cover, ok := covers[post.ID]; ok {
+			images = append(images, abs(cover))
+		}
+		images = append(images, imageURLs(post.HTMLContent, abs)...)
+		for _, img := range dedupe(images) {
+			u.Images = append(u.Images, sitemapImage{Loc: img})
+		}
+		urls = append(urls, u)
+	}
+
+	pages, err := app.DB.GetPublishedPages()
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		urls = append(urls, newSitemapURL(app.URLs.Abs(r, page.URL()), page.UpdatedAt))
+	}
+
+	urls = append(urls, newSitemapURL(app.URLs.Abs(r, "/archive"), newest))
+	months, err := app.DB.GetArchiveMonths()
+	if err != nil {
+		return nil, err
+	}
+	for _, y := range models.GroupArchive(months) {
+		urls = append(urls, newSitemapURL(app.URLs.Abs(r, y.URL()), time.Time{}))
+		for _, m := range y.Months {
+			urls = append(urls, newSitemapURL(app.URLs.Abs(r, m.URL()), time.Time{}))
+		}
+	}
+
+	all, err := app.DB.GetAllSeries()
+	if err != nil {
+		return nil, err
+	}
+	seriesModified := map[int]time.Time{}
+	for _, post := range posts {
+		if post.SeriesID != 0 && post.UpdatedAt.After(seriesModified[post.SeriesID]) {
+			seriesModified[post.SeriesID] = post.UpdatedAt
+		}
+	}
+	for _, s := range all {
+		if s.PostCount > 0 {
+			urls = append(urls, newSitemapURL(app.URLs.Abs(r, s.URL()), seriesModified[s.ID]))
+		}
+	}
+
+	for _, tag := range tags {
+		urls = append(urls, newSitemapURL(app.URLs.Abs(r, "/tag/"+url.PathEscape(tag.Name)), tag.LastModified))
+	}
+
+	return urls, nil
+}
+
+// imageURLs returns the absolute URLs of the images in an HTML fragment.
+// Site-relative sources are resolved with abs; inline data URIs are skipped.
+func imageURLs(fragment string, abs func(string) string) []string {
+	var urls []string
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return urls
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		if name, hasAttr := z.TagName(); string(name) != "img" || !hasAttr {
+			continue
+		}
+		for {
+			key, val, more := z.TagAttr()
+			if string(key) == "src" {
+				src := string(val)
+				switch {
+				case strings.HasPrefix(src, "http://"), strings.HasPrefix(src, "https://"):
+					urls = append(urls, src)
+				case strings.HasPrefix(src, "/") && !strings.HasPrefix(src, "//"):
+					urls = append(urls, abs(src))
+				}
+				break
+			}
+			if !more {
+				break
+			}
+		}
+	}
+}
+
+func dedupe(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	out := items[:0]
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// sitemapPages splits urls into pages of at most sitemapMaxURLs entries.
+func sitemapPages(urls []sitemapURL) [][]sitemapURL {
+	var pages [][]sitemapURL
+	for len(urls) > sitemapMaxURLs {
+		pages = append(pages, urls[:sitemapMaxURLs])
+		urls = urls[sitemapMaxURLs:]
+	}
+	return append(pages, urls)
+}
+
+// Sitemap serves the sitemap, or a sitemap index when the site has more
+// URLs than fit in a single file.
+func (app *App) Sitemap(w http.ResponseWriter, r *http.Request) {
+	urls, err := app.sitemapURLs(r)
+	if err != nil {
+		slog.Error("Error building sitemap", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags, repository.ChangeSeries, repository.ChangePages)
+
+	pages := sitemapPages(urls)
+	if len(pages) == 1 {
+		writeXML(w, urlSet{Xmlns: sitemapNS, ImageNS: sitemapImageNS, URLs: pages[0]})
+		return
+	}
+
+	index := sitemapIndex{Xmlns: sitemapNS}
+	for i, page := range pages {
+		var modified time.Time
+		for _, u := range page {
+			if u.modified.After(modified) {
+				modified = u.modified
+			}
+		}
+		ref := sitemapRef{Loc: app.URLs.Abs(r, "/sitemap/"+strconv.Itoa(i+1)+".xml")}
+		if !modified.IsZero() {
+			ref.LastMod = modified.UTC().Format(time.RFC3339)
+		}
+		index.Sitemaps = append(index.Sitemaps, ref)
+	}
+	writeXML(w, index)
+}
+
+// SitemapPage serves one numbered page of a split sitemap.
+func (app *App) SitemapPage(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(strings.TrimSuffix(r.PathValue("page"), ".xml"))
+	if err != nil {
+		app.NotFound(w, r)
+		return
+	}
+
+	urls, err := app.sitemapURLs(r)
+	if err != nil {
+		slog.Error("Error building sitemap", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	cacheFor(r, repository.ChangePosts, repository.ChangeTags, repository.ChangeSeries, repository.ChangePages)
+
+	pages := sitemapPages(urls)
+	if n < 1 || n > len(pages) || len(pages) == 1 {
+		app.NotFound(w, r)
+		return
+	}
+	writeXML(w, urlSet{Xmlns: sitemapNS, ImageNS: sitemapImageNS, URLs: pages[n-1]})
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		slog.Error("Error encoding XML", "error", err)
+	}
+}
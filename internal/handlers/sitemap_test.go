@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImageURLs(t *testing.T) {
+	fragment := `<p><img src="/media/a.jpg" alt="a"><img src="https://cdn.test/b.png"/>` +
+		`<img src="data:image/png;base64,AAAA"><img alt="no src"><img src="//evil.test/c.gif"></p>`
+	got := imageURLs(fragment, func(p string) string { return "https://site.test" + p })
+	want := []string{"https://site.test/media/a.jpg", "https://cdn.test/b.png"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imageURLs = %v, want %v", got, want)
+	}
+}
+
+func TestSitemapPages(t *testing.T) {
+	defer func(n int) { sitemapMaxURLs = n }(sitemapMaxURLs)
+	sitemapMaxURLs = 2
+
+	urls := make([]sitemapURL, 5)
+	pages := sitemapPages(urls)
+	if len(pages) != 3 || len(pages[0]) != 2 || len(pages[2]) != 1 {
+		t.Errorf("got %d pages: %v", len(pages), pages)
+	}
+	if pages := sitemapPages(urls[:2]); len(pages) != 1 {
+		t.Errorf("expected a single page, got %d", len(pages))
+	}
+}
+
+func TestSitemapEncoding(t *testing.T) {
+	u := newSitemapURL("https://site.test/tag/a&b", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	u.Images = []sitemapImage{{Loc: "https://site.test/media/x.jpg"}}
+
+	out, err := xml.Marshal(urlSet{Xmlns: sitemapNS, ImageNS: sitemapImageNS, URLs: []sitemapURL{u}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<loc>https://site.test/tag/a&amp;b</loc>`,
+		`<lastmod>2024-01-02T03:04:05Z</lastmod>`,
+		`<image:image><image:loc>https://site.test/media/x.jpg</image:loc></image:image>`,
+		`xmlns:image="` + sitemapImageNS + `"`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/alextreichler/personal-website/internal/repository"
+)
+
+func (app *App) AdminListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.DB.GetAllTags()
+	if err != nil {
+		slog.Error("Error loading tags", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"PageTitle": "Tags",
+		"Tags":      tags,
+	}
+	app.Render(w, r, "admin_tags.html", data)
+}
+
+func (app *App) AdminEditTag(w http.ResponseWriter, r *http.Request) {
+	tag, err := app.DB.GetTag(r.URL.Query().Get("name"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	data := map[string]interface{}{
+		"PageTitle": "Edit Tag",
+		"Tag":       tag,
+	}
+	app.Render(w, r, "admin_tag_edit.html", data)
+}
+
+// AdminUpdateTag saves a tag's display name and description, and renames it
+// if the name changed.
+func (app *App) AdminUpdateTag(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	name := r.FormValue("name")
+
+	newName := repository.NormalizeTag(r.FormValue("new_name"))
+	if strings.Contains(newName, ",") {
+		http.Error(w, "Tag names cannot contain commas", http.StatusBadRequest)
+		return
+	}
+	if newName != "" && newName != name {
+		err := app.DB.RenameTag(name, newName)
+		if errors.Is(err, repository.ErrTagExists) {
+			http.Error(w, "The tag \""+newName+"\" already exists, merge the tags instead", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("Error renaming tag", "tag", name, "new_name", newName, "error", err)
+			http.Error(w, "Error renaming tag", http.StatusInternalServerError)
+			return
+		}
+		app.RefreshRelated()
+		name = newName
+	}
+
+	if err := app.DB.UpdateTag(name, r.FormValue("display_name"), r.FormValue("description")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.Error("Error updating tag", "tag", name, "error", err)
+		http.Error(w, "Error updating tag", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+}
+
+// AdminMergeTags moves the posts of the selected tags into one tag.
+func (app *App) AdminMergeTags(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	sources := r.PostForm["name"]
+	target := repository.NormalizeTag(r.FormValue("target"))
+	if len(sources) == 0 || target == "" {
+		http.Error(w, "Select the tags to merge and enter the tag to merge them into", http.StatusBadRequest)
+		return
+	}
+	if strings.Contains(target, ",") {
+		http.Error(w, "Tag names cannot contain commas", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.DB.MergeTags(sources, target); err != nil {
+		slog.Error("Error merging tags", "tags", sources, "target", target, "error", err)
+		http.Error(w, "Error merging tags", http.StatusInternalServerError)
+		return
+	}
+	app.RefreshRelated()
+
+	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+}
+
+func (app *App) AdminDeleteTag(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	name := r.FormValue("name")
+	if err := app.DB.DeleteTag(name); err != nil {
+		slog.Error("Error deleting tag", "tag", name, "error", err)
+		http.Error(w, "Error deleting tag", http.StatusInternalServerError)
+		return
+	}
+	app.RefreshRelated()
+
+	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+}
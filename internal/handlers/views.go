@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alextreichler/personal-website/internal/assets"
+	"github.com/alextreichler/personal-website/internal/theme"
+	"github.com/alextreichler/personal-website/web"
+)
+
+// pages are the templates rendered with base.html as their layout.
+var pages = []string{
+	"home.html",
+	"login.html",
+	"dashboard.html",
+	"admin_posts.html",
+	"admin_post_new.html",
+	"admin_post_edit.html",
+	"admin_about.html",
+	"admin_media.html",
+	"post.html",
+	"attachment.html",
+	"tag.html",
+	"search.html",
+	"archive.html",
+	"series.html",
+	"admin_series.html",
+	"admin_series_edit.html",
+	"page.html",
+	"admin_pages.html",
+	"admin_page_edit.html",
+	"admin_links.html",
+	"admin_post_conflict.html",
+	"admin_tags.html",
+	"admin_tag_edit.html",
+	"error.html",
+	// Add other templates here as they are created
+}
+
+// views are the parsed templates and the static files they link to.
+type views struct {
+	templates map[string]*template.Template
+	assets    *assets.Manifest
+}
+
+// viewFiles returns where templates and static files are read from: the
+// copies embedded in the binary, or the source tree in development mode,
+// with the theme directory on top. Uploads kept below the static directory
+// are left out, so they aren't hashed on every request.
+func viewFiles(webPath, themePath, uploadPath string, dev bool) (templates, static fs.FS) {
+	templates, static = web.Templates(), web.Static()
+	if dev {
+		dir := filepath.Join(webPath, "static")
+		templates = os.DirFS(filepath.Join(webPath, "template"))
+		static = theme.Without(os.DirFS(dir), below(dir, uploadPath))
+	}
+	if themePath != "" {
+		templates = theme.Overlay(templates, filepath.Join(themePath, "template"))
+		static = theme.Overlay(static, filepath.Join(themePath, "static"))
+	}
+	return templates, static
+}
+
+// below returns path as a slash separated path relative to dir, or "" when
+// it isn't inside dir.
+func below(dir, path string) string {
+	if path == "" {
+		return ""
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// loadViews fingerprints the static files and parses every page template.
+func loadViews(templates, static fs.FS, minify bool) (*views, error) {
+	manifest, err := assets.Load(static, minify)
+	if err != nil {
+		return nil, fmt.Errorf("loading static files: %w", err)
+	}
+	funcs := template.FuncMap{
+		// asset "style.css" is the cache-busting URL of a static file
+		"asset": manifest.Path,
+	}
+
+	v := &views{templates: make(map[string]*template.Template), assets: manifest}
+	for _, page := range pages {
+		ts, err := template.New("base.html").Funcs(funcs).ParseFS(templates, "base.html", page)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template %s: %w", page, err)
+		}
+		v.templates[page] = ts
+	}
+	return v, nil
+}
+
+// currentViews returns the loaded views. In development mode they are
+// loaded again first if any template or static file changed, so edits show
+// up without restarting the server. A broken edit is logged and the last
+// working views are kept.
+func (app *App) currentViews() *views {
+	if !app.Config.DevReload {
+		return app.views.Load()
+	}
+
+	app.reload.Lock()
+	defer app.reload.Unlock()
+
+	templates, static := viewFiles(app.Config.WebPath, app.Config.ThemePath, app.Config.UploadPath, true)
+	stamp, err := theme.Stamp(templates, static)
+	if err != nil {
+		slog.Error("Error checking templates for changes", "error", err)
+		return app.views.Load()
+	}
+	if stamp == app.viewsStamp {
+		return app.views.Load()
+	}
+	app.viewsStamp = stamp
+
+	v, err := loadViews(templates, static, app.Config.MinifyAssets)
+	if err != nil {
+		slog.Error("Error reloading templates", "error", err)
+		return app.views.Load()
+	}
+	app.views.Store(v)
+	slog.Info("Reloaded templates and static files")
+	return v
+}
+
+// ServeStatic serves the static files below /static/.
+func (app *App) ServeStatic(w http.ResponseWriter, r *http.Request) {
+	app.currentViews().assets.ServeHTTP(w, r)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alextreichler/personal-website/web"
+)
+
+func TestEmbeddedViews(t *testing.T) {
+	v, err := loadViews(web.Templates(), web.Static(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.templates) != len(pages) {
+		t.Errorf("parsed %d templates, want %d", len(v.templates), len(pages))
+	}
+	if css := v.assets.Path("style.css"); css == "/static/style.css" {
+		t.Error("style.css is not embedded")
+	}
+}
+
+func TestThemeOverridesTemplate(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "template"), 0755)
+	os.WriteFile(filepath.Join(dir, "template", "error.html"), []byte(`{{define "content"}}themed error{{end}}`), 0644)
+
+	templates, static := viewFiles("", dir, "", false)
+	v, err := loadViews(templates, static, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := v.templates["error.html"].ExecuteTemplate(&out, "content", nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "themed error" {
+		t.Errorf("error.html rendered %q, want the theme's version", out.String())
+	}
+}
+
+func TestDevViewFilesSkipUploads(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "static", "uploads"), 0755)
+	os.WriteFile(filepath.Join(dir, "static", "style.css"), []byte("body{}"), 0644)
+	os.WriteFile(filepath.Join(dir, "static", "uploads", "photo.jpg"), []byte("jpg"), 0644)
+
+	_, static := viewFiles(dir, "", filepath.Join(dir, "static", "uploads"), true)
+	if _, err := fs.Stat(static, "style.css"); err != nil {
+		t.Errorf("style.css: %v", err)
+	}
+	if _, err := fs.Stat(static, "uploads/photo.jpg"); err == nil {
+		t.Error("uploads are part of the static files")
+	}
+}
@@ -0,0 +1,212 @@
+// Package imagegen draws simple generated images (document posters, social
+// cards) in pure Go using the embedded Go fonts.
+package imagegen
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	fontsOnce   sync.Once
+	regularFont *opentype.Font
+	boldFont    *opentype.Font
+	fontsErr    error
+)
+
+func loadFonts() error {
+	fontsOnce.Do(func() {
+		regularFont, fontsErr = opentype.Parse(goregular.TTF)
+		if fontsErr != nil {
+			return
+		}
+		boldFont, fontsErr = opentype.Parse(gobold.TTF)
+	})
+	return fontsErr
+}
+
+func face(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// drawText draws s with its baseline starting at (x, y).
+func drawText(dst draw.Image, f font.Face, c color.Color, x, y int, s string) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: f,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// wrap splits s into at most maxLines lines no wider than width pixels,
+// ending the last line with an ellipsis if text had to be dropped.
+func wrap(f font.Face, s string, width, maxLines int) []string {
+	words := strings.Fields(s)
+	var lines []string
+	current := ""
+	for _, w := range words {
+		candidate := w
+		if current != "" {
+			candidate = current + " " + w
+		}
+		if font.MeasureString(f, candidate).Ceil() <= width || current == "" {
+			current = candidate
+			continue
+		}
+		lines = append(lines, current)
+		current = w
+		if len(lines) == maxLines {
+			// Out of room: mark the truncation on the last line
+			last := lines[maxLines-1]
+			for font.MeasureString(f, last+"…").Ceil() > width && strings.Contains(last, " ") {
+				last = last[:strings.LastIndex(last, " ")]
+			}
+			lines[maxLines-1] = last + "…"
+			return lines
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+func fill(dst draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// DocumentPoster renders a placeholder thumbnail for files we can't rasterize
+// in pure Go (such as PDFs): a page with a type badge and the file name.
+func DocumentPoster(label, title string) (image.Image, error) {
+	if err := loadFonts(); err != nil {
+		return nil, err
+	}
+	const w, h = 400, 520
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	fill(img, img.Bounds(), color.RGBA{0xf3, 0xf4, 0xf6, 0xff})
+
+	// Paper with a folded corner
+	paper := image.Rect(40, 30, w-40, h-30)
+	fill(img, paper, color.White)
+	fill(img, image.Rect(paper.Max.X-50, paper.Min.Y, paper.Max.X, paper.Min.Y+50), color.RGBA{0xe5, 0xe7, 0xeb, 0xff})
+
+	// Faux text lines
+	for y := paper.Min.Y + 90; y < paper.Max.Y-150; y += 22 {
+		fill(img, image.Rect(paper.Min.X+30, y, paper.Max.X-30, y+8), color.RGBA{0xe5, 0xe7, 0xeb, 0xff})
+	}
+
+	badgeFace, err := face(boldFont, 36)
+	if err != nil {
+		return nil, err
+	}
+	defer badgeFace.Close()
+	badge := image.Rect(paper.Min.X+30, paper.Max.Y-130, paper.Min.X+30+font.MeasureString(badgeFace, label).Ceil()+30, paper.Max.Y-80)
+	fill(img, badge, color.RGBA{0xdc, 0x26, 0x26, 0xff})
+	drawText(img, badgeFace, color.White, badge.Min.X+15, badge.Max.Y-13, label)
+
+	titleFace, err := face(regularFont, 18)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	for i, line := range wrap(titleFace, title, paper.Dx()-60, 2) {
+		drawText(img, titleFace, color.RGBA{0x37, 0x41, 0x51, 0xff}, paper.Min.X+30, paper.Max.Y-50+i*22, line)
+	}
+
+	return img, nil
+}
+
+// Card holds the text rendered on a social sharing card.
+type Card struct {
+	Title       string
+	Date        string
+	ReadingTime string
+	SiteName    string
+}
+
+// OGWidth and OGHeight are the recommended Open Graph image dimensions.
+const (
+	OGWidth  = 1200
+	OGHeight = 630
+)
+
+// OGCard renders a 1200x630 Open Graph image for a post.
+func OGCard(c Card) (image.Image, error) {
+	if err := loadFonts(); err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, OGWidth, OGHeight))
+
+	// Vertical gradient background
+	top, bottom := color.RGBA{0x1e, 0x1b, 0x4b, 0xff}, color.RGBA{0x0f, 0x17, 0x2a, 0xff}
+	for y := 0; y < OGHeight; y++ {
+		t := float64(y) / OGHeight
+		row := color.RGBA{
+			R: uint8(float64(top.R)*(1-t) + float64(bottom.R)*t),
+			G: uint8(float64(top.G)*(1-t) + float64(bottom.G)*t),
+			B: uint8(float64(top.B)*(1-t) + float64(bottom.B)*t),
+			A: 0xff,
+		}
+		fill(img, image.Rect(0, y, OGWidth, y+1), row)
+	}
+
+	// Accent bar
+	accent := color.RGBA{0x81, 0x8c, 0xf8, 0xff}
+	fill(img, image.Rect(80, 80, 200, 88), accent)
+
+	const margin = 80
+	light := color.RGBA{0xe0, 0xe7, 0xff, 0xff}
+
+	siteFace, err := face(boldFont, 30)
+	if err != nil {
+		return nil, err
+	}
+	defer siteFace.Close()
+	drawText(img, siteFace, light, margin, 140, c.SiteName)
+
+	// Shrink long titles so they still fit in three lines
+	var titleFace font.Face
+	var lines []string
+	for _, size := range []float64{72, 60, 50} {
+		if titleFace != nil {
+			titleFace.Close()
+		}
+		titleFace, err = face(boldFont, size)
+		if err != nil {
+			return nil, err
+		}
+		lines = wrap(titleFace, c.Title, OGWidth-2*margin, 3)
+		if len(lines) == 0 || !strings.HasSuffix(lines[len(lines)-1], "…") {
+			break
+		}
+	}
+	defer titleFace.Close()
+	lineHeight := titleFace.Metrics().Height.Ceil() + 8
+	for i, line := range lines {
+		drawText(img, titleFace, color.White, margin, 250+i*lineHeight, line)
+	}
+
+	metaFace, err := face(regularFont, 30)
+	if err != nil {
+		return nil, err
+	}
+	defer metaFace.Close()
+	meta := c.Date
+	if c.ReadingTime != "" {
+		meta += "  •  " + c.ReadingTime
+	}
+	drawText(img, metaFace, light, margin, OGHeight-margin, meta)
+
+	return img, nil
+}
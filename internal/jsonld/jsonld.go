@@ -0,0 +1,156 @@
+// Package jsonld builds schema.org structured data for search engines.
+//
+// Each type marshals to a JSON-LD document; Script encodes one for embedding
+// in a <script type="application/ld+json"> element.
+package jsonld
+
+import (
+	"encoding/json"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+const context = "https://schema.org"
+
+// Person describes the site's author.
+type Person struct {
+	Context     string `json:"@context,omitempty"`
+	Type        string `json:"@type"`
+	Name        string `json:"name"`
+	URL         string `json:"url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// BlogPosting describes a single post.
+type BlogPosting struct {
+	Context          string   `json:"@context"`
+	Type             string   `json:"@type"`
+	Headline         string   `json:"headline"`
+	Description      string   `json:"description,omitempty"`
+	URL              string   `json:"url"`
+	MainEntityOfPage string   `json:"mainEntityOfPage"`
+	DatePublished    string   `json:"datePublished"`
+	DateModified     string   `json:"dateModified"`
+	Author           Person   `json:"author"`
+	Keywords         string   `json:"keywords,omitempty"`
+	WordCount        int      `json:"wordCount"`
+	Image            []string `json:"image,omitempty"`
+}
+
+// WebSite describes the site and how to search it.
+type WebSite struct {
+	Context         string        `json:"@context"`
+	Type            string        `json:"@type"`
+	Name            string        `json:"name"`
+	URL             string        `json:"url"`
+	PotentialAction *SearchAction `json:"potentialAction,omitempty"`
+}
+
+// SearchAction tells search engines where the site search lives.
+type SearchAction struct {
+	Type       string `json:"@type"`
+	Target     string `json:"target"`
+	QueryInput string `json:"query-input"`
+}
+
+// BreadcrumbList describes the path from the homepage to a page.
+type BreadcrumbList struct {
+	Context         string     `json:"@context"`
+	Type            string     `json:"@type"`
+	ItemListElement []ListItem `json:"itemListElement"`
+}
+
+// ListItem is one step of a BreadcrumbList.
+type ListItem struct {
+	Type     string `json:"@type"`
+	Position int    `json:"position"`
+	Name     string `json:"name"`
+	Item     string `json:"item"`
+}
+
+// Crumb is a named link used to build a BreadcrumbList.
+type Crumb struct {
+	Name string
+	URL  string
+}
+
+// Author returns the Person who writes the site.
+func Author(name, siteURL string) Person {
+	return Person{Type: "Person", Name: name, URL: siteURL + "/"}
+}
+
+// NewPerson returns a standalone Person document.
+func NewPerson(name, siteURL, description string) Person {
+	p := Author(name, siteURL)
+	p.Context = context
+	p.Description = description
+	return p
+}
+
+// NewBlogPosting describes post, published at siteURL. imageURL may be empty.
+func NewBlogPosting(post *models.Post, siteURL, author, imageURL string) BlogPosting {
+	url := siteURL + "/post/" + post.Slug
+	b := BlogPosting{
+		Context:          context,
+		Type:             "BlogPosting",
+		Headline:         post.Title,
+		Description:      post.Description(),
+		URL:              url,
+		MainEntityOfPage: url,
+		DatePublished:    post.CreatedAt.UTC().Format(time.RFC3339),
+		DateModified:     post.UpdatedAt.UTC().Format(time.RFC3339),
+		Author:           Author(author, siteURL),
+		Keywords:         strings.Join(post.Tags, ", "),
+		WordCount:        post.WordCount(),
+	}
+	if imageURL != "" {
+		b.Image = []string{imageURL}
+	}
+	return b
+}
+
+// NewWebSite describes the site, searchable at siteURL/search?q=.
+func NewWebSite(name, siteURL string) WebSite {
+	return WebSite{
+		Context: context,
+		Type:    "WebSite",
+		Name:    name,
+		URL:     siteURL + "/",
+		PotentialAction: &SearchAction{
+			Type:       "SearchAction",
+			Target:     siteURL + "/search?q={search_term_string}",
+			QueryInput: "required name=search_term_string",
+		},
+	}
+}
+
+// NewBreadcrumbList numbers crumbs in order, starting at 1.
+func NewBreadcrumbList(crumbs ...Crumb) BreadcrumbList {
+	list := BreadcrumbList{Context: context, Type: "BreadcrumbList"}
+	for i, c := range crumbs {
+		list.ItemListElement = append(list.ItemListElement, ListItem{
+			Type:     "ListItem",
+			Position: i + 1,
+			Name:     c.Name,
+			Item:     c.URL,
+		})
+	}
+	return list
+}
+
+// Script encodes docs for embedding in <script type="application/ld+json">.
+// encoding/json escapes <, > and &, so the output cannot close the element.
+func Script(docs ...interface{}) ([]template.JS, error) {
+	scripts := make([]template.JS, 0, len(docs))
+	for _, doc := range docs {
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, template.JS(b))
+	}
+	return scripts, nil
+}
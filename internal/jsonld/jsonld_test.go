@@ -0,0 +1,77 @@
+package jsonld
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+const site = "https://example.com"
+
+func golden(t *testing.T, name string, doc interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file (run go test -update): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	post := &models.Post{
+		Title:       `Writing a "fast" blog </script>`,
+		Slug:        "fast-blog",
+		Content:     "Go is fun to write.",
+		HTMLContent: "<p>Go is fun to write.</p>",
+		Tags:        []string{"go", "web"},
+		CreatedAt:   time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2024, 3, 2, 10, 0, 0, 0, time.FixedZone("CET", 3600)),
+	}
+
+	golden(t, "blogposting", NewBlogPosting(post, site, "Alex Treichler", site+"/og/fast-blog.png"))
+	golden(t, "website", NewWebSite("Alex Treichler", site))
+	golden(t, "breadcrumbs", NewBreadcrumbList(
+		Crumb{"Home", site + "/"},
+		Crumb{"#go", site + "/tag/go"},
+		Crumb{post.Title, site + "/post/fast-blog"},
+	))
+	golden(t, "person", NewPerson("Alex Treichler", site, "Engineer and writer."))
+}
+
+func TestScriptEscapesHTML(t *testing.T) {
+	scripts, err := Script(NewPerson("</script><script>alert(1)</script>", site, "a & b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(scripts[0])
+	if strings.ContainsAny(out, "<>&") {
+		t.Errorf("unescaped HTML in %s", out)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		t.Errorf("invalid JSON: %v", err)
+	}
+}
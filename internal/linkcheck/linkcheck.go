@@ -0,0 +1,298 @@
+// Package linkcheck finds the links in rendered content and checks that
+// they still resolve.
+package linkcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Extract returns the distinct link and image targets in an HTML fragment,
+// in document order. In-page anchors and non-web schemes are skipped.
+func Extract(fragment string) []string {
+	var links []string
+	seen := map[string]bool{}
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			key := map[string]string{"a": "href", "img": "src"}[tok.Data]
+			if key == "" {
+				continue
+			}
+			for _, a := range tok.Attr {
+				if a.Key != key {
+					continue
+				}
+				link := strings.TrimSpace(a.Val)
+				if link == "" || strings.HasPrefix(link, "#") || seen[link] {
+					break
+				}
+				if u, err := url.Parse(link); err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+					break
+				}
+				seen[link] = true
+				links = append(links, link)
+			}
+		}
+	}
+}
+
+// External reports whether link points at another site. Absolute links to
+// siteHost count as internal.
+func External(link, siteHost string) bool {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return !strings.EqualFold(u.Host, siteHost)
+}
+
+// Kinds of content an internal link can point at, see Internal.
+const (
+	KindPost   = "post"
+	KindPage   = "page"
+	KindTag    = "tag"
+	KindSeries = "series"
+	KindMedia  = "media"
+)
+
+// Internal works out what a link to this site points at: the kind of
+// content, and the slug, tag name or storage key naming it. Links to other
+// sites and to paths that aren't content, such as /archive, report false.
+// A single path segment is reported as a page even when it is the name of
+// another route; callers tell those apart.
+func Internal(link, siteHost string) (kind, name string, ok bool) {
+	u, err := url.Parse(link)
+	if err != nil || External(link, siteHost) || !strings.HasPrefix(u.Path, "/") {
+		return "", "", false
+	}
+	p := strings.TrimPrefix(u.Path, "/")
+	if key, ok := strings.CutPrefix(p, "media/"); ok {
+		return KindMedia, key, key != ""
+	}
+	// Uploads from before the storage backends
+	if key, ok := strings.CutPrefix(p, "static/uploads/"); ok {
+		return KindMedia, key, key != ""
+	}
+	first, rest, nested := strings.Cut(p, "/")
+	if first == "" || strings.Contains(rest, "/") {
+		return "", "", false
+	}
+	switch {
+	case !nested:
+		return KindPage, first, true
+	case first == "post":
+		return KindPost, rest, rest != ""
+	case first == "tag":
+		return KindTag, strings.ToLower(rest), rest != ""
+	case first == "series":
+		return KindSeries, rest, rest != ""
+	}
+	return "", "", false
+}
+
+// Result is the outcome of checking one URL.
+type Result struct {
+	URL       string
+	Status    int    // HTTP status of the last attempt, 0 if none completed
+	Err       string // Network error of the last attempt
+	CheckedAt time.Time
+}
+
+// OK reports whether the URL resolved.
+func (r Result) OK() bool {
+	return r.Err == "" && r.Status > 0 && r.Status < 400
+}
+
+// transient reports whether a later attempt might succeed.
+func (r Result) transient() bool {
+	return r.Err != "" || r.Status == http.StatusTooManyRequests || r.Status >= 500
+}
+
+// Checker checks external URLs politely: a few requests per host at a time,
+// and exponential backoff when a host fails or asks us to slow down. The
+// backoff holds back every URL on the host, not just the one that failed.
+type Checker struct {
+	Client     *http.Client
+	UserAgent  string
+	Concurrent int           // Requests in flight overall
+	PerHost    int           // Requests in flight per host
+	Retries    int           // Extra attempts after a transient failure
+	Backoff    time.Duration // Wait after a host's first failure, doubled after each in a row
+	MaxBackoff time.Duration // Longest wait, including Retry-After
+}
+
+// New returns a Checker with conservative defaults.
+func New() *Checker {
+	return &Checker{
+		Client:     &http.Client{Timeout: 15 * time.Second},
+		UserAgent:  "Mozilla/5.0 (compatible; link checker)",
+		Concurrent: 8,
+		PerHost:    2,
+		Retries:    2,
+		Backoff:    2 * time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// host is what the requests to one host share.
+type host struct {
+	slots chan struct{} // Requests in flight
+
+	mu    sync.Mutex
+	wait  time.Duration // Current backoff, 0 after a success
+	until time.Time     // No requests before this
+}
+
+// ready waits until the host may be sent another request.
+func (h *host) ready(ctx context.Context) error {
+	for {
+		h.mu.Lock()
+		wait := time.Until(h.until)
+		h.mu.Unlock()
+		if wait <= 0 {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// failed holds the host back after a transient failure: for retryAfter if
+// it asked for that, otherwise for its backoff, which doubles with every
+// failure in a row.
+func (h *host) failed(c *Checker, retryAfter time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.wait == 0 {
+		h.wait = c.Backoff
+	} else {
+		h.wait *= 2
+	}
+	wait := max(h.wait, retryAfter)
+	if c.MaxBackoff > 0 {
+		h.wait = min(h.wait, c.MaxBackoff)
+		wait = min(wait, c.MaxBackoff)
+	}
+	if until := time.Now().Add(wait); until.After(h.until) {
+		h.until = until
+	}
+}
+
+// answered resets the backoff once the host responds normally again.
+func (h *host) answered() {
+	h.mu.Lock()
+	h.wait = 0
+	h.mu.Unlock()
+}
+
+// Check checks every URL and returns the results in the same order.
+func (c *Checker) Check(ctx context.Context, urls []string) []Result {
+	results := make([]Result, len(urls))
+	all := make(chan struct{}, max(c.Concurrent, 1))
+	hosts := map[string]*host{}
+
+	var wg sync.WaitGroup
+	for i, link := range urls {
+		name := link
+		if u, err := url.Parse(link); err == nil {
+			name = strings.ToLower(u.Host)
+		}
+		h, ok := hosts[name]
+		if !ok {
+			h = &host{slots: make(chan struct{}, max(c.PerHost, 1))}
+			hosts[name] = h
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.checkOne(ctx, h, all, link)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// checkOne retries transient failures once the host's backoff allows. The
+// overall request limit isn't held while waiting, so other hosts carry on.
+func (c *Checker) checkOne(ctx context.Context, h *host, all chan struct{}, link string) Result {
+	res := Result{URL: link, CheckedAt: time.Now()}
+	for attempt := 0; ; attempt++ {
+		h.slots <- struct{}{}
+		if err := h.ready(ctx); err != nil {
+			<-h.slots
+			if res.Status == 0 && res.Err == "" {
+				res.Err = err.Error()
+			}
+			return res
+		}
+		all <- struct{}{}
+		var retryAfter time.Duration
+		res, retryAfter = c.try(ctx, link)
+		<-all
+		// Update the backoff before the next request to the host may start
+		if res.transient() {
+			h.failed(c, retryAfter)
+		} else {
+			h.answered()
+		}
+		<-h.slots
+
+		if !res.transient() || attempt >= c.Retries || ctx.Err() != nil {
+			return res
+		}
+	}
+}
+
+// try requests link with HEAD, falling back to GET for servers that don't
+// handle HEAD properly.
+func (c *Checker) try(ctx context.Context, link string) (Result, time.Duration) {
+	res, retryAfter := c.request(ctx, http.MethodHead, link)
+	if res.OK() || res.Status == http.StatusTooManyRequests {
+		return res, retryAfter
+	}
+	return c.request(ctx, http.MethodGet, link)
+}
+
+func (c *Checker) request(ctx context.Context, method, link string) (Result, time.Duration) {
+	res := Result{URL: link, CheckedAt: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		res.Err = err.Error()
+		return res, 0
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		res.Err = err.Error()
+		return res, 0
+	}
+	defer resp.Body.Close()
+	// Read a little so the connection can be reused
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+
+	res.Status = resp.StatusCode
+	var retryAfter time.Duration
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		retryAfter = time.Duration(secs) * time.Second
+	}
+	return res, retryAfter
+}
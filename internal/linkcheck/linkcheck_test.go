@@ -0,0 +1,203 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExtract(t *testing.T) {
+	got := Extract(`<p><a href="/post/one">1</a> <a href="#top">top</a> <a href="mailto:x@example.com">mail</a>
+<a href="https://example.com/a">a</a><img src="/media/x.jpg"><a href="/post/one">again</a></p>`)
+	want := []string{"/post/one", "https://example.com/a", "/media/x.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Extract() = %q, want %q", got, want)
+	}
+}
+
+func TestInternal(t *testing.T) {
+	for link, want := range map[string]string{
+		"/post/hello":                         "post hello",
+		"/post/hello#section":                 "post hello",
+		"https://blog.example.com/post/hello": "post hello",
+		"https://other.example.com/post/x":    "",
+		"/post/":                              "",
+		"/about":                              "page about",
+		"/about?x=1":                          "page about",
+		"/tag/Go%20Lang":                      "tag go lang",
+		"/series/intro":                       "series intro",
+		"/series/intro/rss.xml":               "",
+		"/media/2024/photo.jpg":               "media 2024/photo.jpg",
+		"/static/uploads/photo.jpg":           "media photo.jpg",
+		"/static/style.css":                   "",
+		"/":                                   "",
+		"relative":                            "",
+	} {
+		kind, name, ok := Internal(link, "blog.example.com")
+		got := ""
+		if ok {
+			got = kind + " " + name
+		}
+		if got != want {
+			t.Errorf("Internal(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
+
+func testChecker() *Checker {
+	c := New()
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func TestCheckFallsBackToGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	res := testChecker().Check(context.Background(), []string{srv.URL})[0]
+	if !res.OK() || res.Status != http.StatusOK {
+		t.Errorf("got %+v, want 200 from GET", res)
+	}
+}
+
+func TestCheckRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail both the HEAD and GET of the first attempt
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	res := testChecker().Check(context.Background(), []string{srv.URL})[0]
+	if !res.OK() {
+		t.Errorf("got %+v, want success after a retry", res)
+	}
+}
+
+func TestCheckDoesNotRetryNotFound(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	res := testChecker().Check(context.Background(), []string{srv.URL + "/gone"})[0]
+	if res.OK() || res.Status != http.StatusNotFound {
+		t.Errorf("got %+v, want 404", res)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("got %d requests, want HEAD and GET only", n)
+	}
+}
+
+func TestCheckGivesUpAfterRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := testChecker()
+	c.Retries = 2
+	res := c.Check(context.Background(), []string{srv.URL})[0]
+	if res.OK() || res.Status != http.StatusTooManyRequests {
+		t.Errorf("got %+v, want 429", res)
+	}
+	// 429 on HEAD skips the GET fallback
+	if n := calls.Load(); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+}
+
+func TestBackoffIsPerHost(t *testing.T) {
+	var mu sync.Mutex
+	var limited time.Time // When the first request was turned away
+	var early []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if limited.IsZero() {
+			limited = time.Now()
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if time.Since(limited) < 40*time.Millisecond {
+			early = append(early, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	c := testChecker()
+	c.Backoff = 50 * time.Millisecond
+	c.PerHost = 1 // Nothing else in flight when the host asks to slow down
+	results := c.Check(context.Background(), []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"})
+	for i, res := range results {
+		if !res.OK() {
+			t.Errorf("result %d = %+v", i, res)
+		}
+	}
+	if len(early) > 0 {
+		t.Errorf("requested %v while the host was backing off", early)
+	}
+}
+
+func TestCheckNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	link := srv.URL
+	srv.Close()
+
+	c := testChecker()
+	c.Retries = 0
+	res := c.Check(context.Background(), []string{link})[0]
+	if res.OK() || res.Err == "" {
+		t.Errorf("got %+v, want a network error", res)
+	}
+}
+
+func TestCheckLimitsRequestsPerHost(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	var urls []string
+	for _, p := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+		urls = append(urls, srv.URL+p)
+	}
+	c := testChecker()
+	c.PerHost = 2
+	results := c.Check(context.Background(), urls)
+	for i, res := range results {
+		if res.URL != urls[i] || !res.OK() {
+			t.Errorf("result %d = %+v", i, res)
+		}
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency per host = %d, want at most 2", peak)
+	}
+}
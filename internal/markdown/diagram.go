@@ -0,0 +1,73 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/alextreichler/personal-website/internal/diagram"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var kindDiagram = ast.NewNodeKind("Diagram")
+
+// diagramBlock is a ```mermaid fence rendered to SVG.
+type diagramBlock struct {
+	ast.BaseBlock
+	svg string
+}
+
+func (n *diagramBlock) Kind() ast.NodeKind { return kindDiagram }
+
+func (n *diagramBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// diagramExtension replaces mermaid code fences with inline SVG. Diagrams
+// that can't be rendered stay as highlighted code.
+type diagramExtension struct{}
+
+func (diagramExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(diagramTransformer{}, 900)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(diagramRenderer{}, 500)))
+}
+
+type diagramTransformer struct{}
+
+func (diagramTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var fences []*ast.FencedCodeBlock
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fence, ok := n.(*ast.FencedCodeBlock); ok && entering && string(fence.Language(source)) == "mermaid" {
+			fences = append(fences, fence)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, fence := range fences {
+		var src bytes.Buffer
+		for i := 0; i < fence.Lines().Len(); i++ {
+			line := fence.Lines().At(i)
+			src.Write(line.Value(source))
+		}
+		svg, err := diagram.Render(src.String())
+		if err != nil {
+			continue
+		}
+		fence.Parent().ReplaceChild(fence.Parent(), fence, &diagramBlock{svg: svg})
+	}
+}
+
+type diagramRenderer struct{}
+
+func (diagramRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindDiagram, func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			_, _ = w.WriteString(`<div class="diagram">` + node.(*diagramBlock).svg + "</div>\n")
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
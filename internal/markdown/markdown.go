@@ -0,0 +1,244 @@
+// Package markdown renders post and page Markdown to sanitized HTML.
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/net/html"
+)
+
+// TOCMinHeadings is how many section headings a document needs before a
+// table of contents is worth showing.
+const TOCMinHeadings = 3
+
+var (
+	md = goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			extension.Footnote,
+			extension.Typographer,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle("dracula"),
+			),
+			mathExtension{},
+			diagramExtension{},
+			shortcodeExtension{},
+		),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(util.Prioritized(anchorTransformer{}, 1000)),
+		),
+	)
+	policy = newPolicy()
+	// shortcodePolicy sanitizes the output of each shortcode on its own.
+	// Shortcodes may emit embeds and classes that Markdown can't ask for, so
+	// only they get the extra allowances.
+	shortcodePolicy = newShortcodePolicy()
+)
+
+// newPolicy extends the UGC policy with what the renderer produces:
+// highlighting styles, table alignment, heading anchors, footnotes, task
+// list checkboxes, MathML and diagram SVG.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("style").OnElements("pre", "code", "span")
+	p.AllowStyles("text-align").MatchingEnum("left", "center", "right").OnElements("th", "td")
+
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(heading-anchor|footnote-ref|footnote-backref)$`)).OnElements("a")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(footnotes|diagram)$`)).OnElements("div")
+	p.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-(noteref|backlink|endnotes)$`)).OnElements("a", "div")
+
+	p.AllowElements("input")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+
+	allowMathML(p)
+	allowSVG(p)
+	return p
+}
+
+// newShortcodePolicy extends the document policy with what the shortcodes
+// emit. Embeds are limited to privacy-enhanced YouTube players.
+func newShortcodePolicy() *bluemonday.Policy {
+	p := newPolicy()
+	p.AllowElements("figcaption")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(gallery|video)$`)).OnElements("div")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^snippet$`)).OnElements("figure")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^callout callout-(note|tip|warning|danger)$`)).OnElements("aside")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^callout-title$`)).OnElements("p")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^broken-link$`)).OnElements("span")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^shortcode-error$`)).OnElements("code")
+
+	p.AllowIFrames(bluemonday.SandboxAllowScripts, bluemonday.SandboxAllowSameOrigin, bluemonday.SandboxAllowPresentation, bluemonday.SandboxAllowPopups)
+	p.AllowAttrs("src").Matching(regexp.MustCompile(`^https://www\.youtube-nocookie\.com/embed/[A-Za-z0-9_-]{11}(\?start=[0-9]+)?$`)).OnElements("iframe")
+	p.AllowAttrs("loading").Matching(regexp.MustCompile(`^lazy$`)).OnElements("iframe")
+	p.AllowAttrs("allow").Matching(regexp.MustCompile(`^encrypted-media; picture-in-picture$`)).OnElements("iframe")
+	p.AllowAttrs("referrerpolicy").Matching(regexp.MustCompile(`^strict-origin-when-cross-origin$`)).OnElements("iframe")
+	p.AllowAttrs("allowfullscreen").OnElements("iframe")
+	return p
+}
+
+var (
+	boolean = regexp.MustCompile(`^(true|false)$`)
+	length  = regexp.MustCompile(`^-?[0-9.]+em$`)
+	number  = regexp.MustCompile(`^-?[0-9.]+$`)
+)
+
+// allowMathML permits the elements and attributes package mathml emits.
+func allowMathML(p *bluemonday.Policy) {
+	p.AllowNoAttrs().OnElements("math", "semantics", "annotation", "mrow", "mi", "mn", "mo", "mtext", "mspace",
+		"msup", "msub", "msubsup", "mfrac", "msqrt", "mroot", "munder", "mover", "munderover",
+		"mtable", "mtr", "mtd", "merror", "mstyle")
+	p.AllowAttrs("xmlns").Matching(regexp.MustCompile(`^http://www\.w3\.org/1998/Math/MathML$`)).OnElements("math")
+	p.AllowAttrs("display").Matching(regexp.MustCompile(`^(inline|block)$`)).OnElements("math")
+	p.AllowAttrs("encoding").Matching(regexp.MustCompile(`^application/x-tex$`)).OnElements("annotation")
+	p.AllowAttrs("mathvariant").Matching(regexp.MustCompile(`^normal$`)).OnElements("mi")
+	p.AllowAttrs("stretchy", "fence").Matching(boolean).OnElements("mo")
+	p.AllowAttrs("form").Matching(regexp.MustCompile(`^(prefix|infix|postfix)$`)).OnElements("mo")
+	p.AllowAttrs("lspace", "rspace", "minsize", "maxsize").Matching(length).OnElements("mo")
+	p.AllowAttrs("accent").Matching(boolean).OnElements("mover", "munderover")
+	p.AllowAttrs("accentunder").Matching(boolean).OnElements("munder", "munderover")
+	p.AllowAttrs("linethickness").Matching(regexp.MustCompile(`^0$`)).OnElements("mfrac")
+	p.AllowAttrs("displaystyle").Matching(boolean).OnElements("mstyle")
+	p.AllowAttrs("width").Matching(length).OnElements("mspace")
+	p.AllowAttrs("columnalign").Matching(regexp.MustCompile(`^(left|center|right)( (left|center|right))*$`)).OnElements("mtable")
+}
+
+// allowSVG permits the shapes package diagram draws. Attribute values are
+// restricted to numbers and the few colours and classes it uses, so no
+// script or external reference can get through.
+func allowSVG(p *bluemonday.Policy) {
+	p.AllowElements("svg", "rect", "circle", "polygon", "line", "path", "text")
+	p.AllowAttrs("xmlns").Matching(regexp.MustCompile(`^http://www\.w3\.org/2000/svg$`)).OnElements("svg")
+	p.AllowAttrs("viewbox").Matching(regexp.MustCompile(`^[0-9. ]+$`)).OnElements("svg")
+	p.AllowAttrs("role").Matching(regexp.MustCompile(`^img$`)).OnElements("svg")
+	p.AllowAttrs("aria-label").OnElements("svg")
+	p.AllowAttrs("font-family").Matching(regexp.MustCompile(`^sans-serif$`)).OnElements("svg")
+	p.AllowAttrs("font-size").Matching(number).OnElements("svg")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^diagram(-[a-z]+)*$`)).OnElements("svg", "rect", "circle", "polygon", "line", "path", "text")
+	p.AllowAttrs("width", "height").Matching(number).OnElements("svg", "rect")
+	p.AllowAttrs("x", "y", "rx").Matching(number).OnElements("rect", "text")
+	p.AllowAttrs("cx", "cy", "r").Matching(number).OnElements("circle")
+	p.AllowAttrs("x1", "y1", "x2", "y2").Matching(number).OnElements("line")
+	p.AllowAttrs("points").Matching(regexp.MustCompile(`^[0-9., -]+$`)).OnElements("polygon")
+	p.AllowAttrs("d").Matching(regexp.MustCompile(`^[MLHVCZ0-9., -]+$`)).OnElements("path")
+	p.AllowAttrs("fill").Matching(regexp.MustCompile(`^(none|currentColor|#[0-9a-f]{6})$`)).OnElements("rect", "circle", "polygon", "path", "text")
+	p.AllowAttrs("stroke").Matching(regexp.MustCompile(`^(none|currentColor)$`)).OnElements("rect", "circle", "polygon", "line", "path")
+	p.AllowAttrs("stroke-width").Matching(number).OnElements("rect", "circle", "polygon", "line", "path")
+	p.AllowAttrs("stroke-dasharray").Matching(regexp.MustCompile(`^[0-9 ]+$`)).OnElements("line", "path")
+	p.AllowAttrs("text-anchor").Matching(regexp.MustCompile(`^(start|middle|end)$`)).OnElements("text")
+	p.AllowAttrs("dominant-baseline").Matching(regexp.MustCompile(`^(central|middle|hanging)$`)).OnElements("text")
+}
+
+// Render converts Markdown to sanitized HTML. The document is sanitized
+// with shortcodes left as placeholders, which are then filled in with the
+// shortcode output sanitized on its own.
+func Render(source string, opts ...Option) (string, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	ctx := parser.NewContext()
+	ctx.Set(optionsKey, o)
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(stripSlots.Replace(source)), &buf, parser.WithContext(ctx)); err != nil {
+		return "", err
+	}
+	return o.fillSlots(policy.Sanitize(buf.String())), nil
+}
+
+// anchorTransformer appends a "#" link to every heading with an ID, so
+// readers can link to a section.
+type anchorTransformer struct{}
+
+func (anchorTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		id, ok := heading.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		link := ast.NewLink()
+		link.Destination = append([]byte("#"), id.([]byte)...)
+		link.Title = []byte("Link to this section")
+		link.SetAttributeString("class", []byte("heading-anchor"))
+		link.AppendChild(link, ast.NewString([]byte("#")))
+		heading.AppendChild(heading, ast.NewString([]byte(" ")))
+		heading.AppendChild(heading, link)
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// Heading is an entry in a table of contents.
+type Heading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// TOC lists the h2 and h3 headings of rendered HTML that can be linked to.
+func TOC(fragment string) []Heading {
+	var toc []Heading
+	var current *Heading
+	var text strings.Builder
+	inAnchor := false
+
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return toc
+		case html.StartTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "h2", "h3":
+				if id := attr(tok, "id"); id != "" {
+					current = &Heading{Level: int(tok.Data[1] - '0'), ID: id}
+					text.Reset()
+				}
+			case "a":
+				inAnchor = attr(tok, "class") == "heading-anchor"
+			}
+		case html.EndTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "h2", "h3":
+				if current != nil {
+					current.Text = strings.Join(strings.Fields(text.String()), " ")
+					toc = append(toc, *current)
+					current = nil
+				}
+			case "a":
+				inAnchor = false
+			}
+		case html.TextToken:
+			if current != nil && !inAnchor {
+				text.Write(z.Text())
+			}
+		}
+	}
+}
+
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
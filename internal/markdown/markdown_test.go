@@ -0,0 +1,136 @@
+package markdown
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestRenderGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no testdata")
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".md")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Render(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", name+".golden.html")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("missing golden file (run go test -update): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", name, got, want)
+			}
+		})
+	}
+}
+
+func TestTOC(t *testing.T) {
+	out, err := Render("# Title\n\n## One\n\n### One & a half\n\n## One\n\n#### Deep\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Heading{
+		{Level: 2, ID: "one", Text: "One"},
+		{Level: 3, ID: "one--a-half", Text: "One & a half"},
+		{Level: 2, ID: "one-1", Text: "One"},
+	}
+	if got := TOC(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("TOC() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPostLink(t *testing.T) {
+	posts := map[string]string{"hello-world": "Hello, World"}
+	lookup := func(slug string) (string, bool) {
+		title, ok := posts[slug]
+		return title, ok
+	}
+	for src, want := range map[string]string{
+		`{{< post-link hello-world >}}`:                `<a href="/post/hello-world" rel="nofollow">Hello, World</a>`,
+		`{{< post-link hello-world "the intro" >}}`:    `<a href="/post/hello-world" rel="nofollow">the intro</a>`,
+		`{{< post-link missing >}}`:                    `<span class="broken-link" title="No published post with slug missing">missing</span>`,
+		`{{< post-link slug="missing" text="Gone" >}}`: `<span class="broken-link" title="No published post with slug missing">Gone</span>`,
+	} {
+		got, err := Render(src, WithPosts(lookup))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want = "<p>" + want + "</p>\n"; got != want {
+			t.Errorf("Render(%q) = %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestShortcodePolicyIsScoped(t *testing.T) {
+	embed := `<div class="video"><iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"></iframe></div>`
+	if got := policy.Sanitize(embed); strings.Contains(got, "iframe") || strings.Contains(got, "video") {
+		t.Errorf("document policy let through %q", got)
+	}
+	if got := shortcodePolicy.Sanitize(embed); !strings.Contains(got, "<iframe") {
+		t.Errorf("shortcode policy dropped the embed: %q", got)
+	}
+
+	// Placeholders typed by the author don't pull in shortcode output
+	got, err := Render("{{< youtube dQw4w9WgXcQ >}}\n\n\uE0000\uE001\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(got, "<iframe"); n != 1 || strings.ContainsRune(got, '\uE000') {
+		t.Errorf("Render = %q, want the embed once", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	snippets := WithSnippets(fstest.MapFS{
+		"hello.go": {Data: []byte("package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n")},
+	})
+	got, err := Render(`{{< snippet "hello.go" lines="3-5" >}}`, snippets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, `<figure class="snippet"><pre style=`) || !strings.Contains(got, "<figcaption>hello.go</figcaption>") {
+		t.Errorf("Render = %q, want a highlighted block with a caption", got)
+	}
+	if strings.Contains(got, "package") || !strings.Contains(got, "println") {
+		t.Errorf("Render = %q, want lines 3 to 5 only", got)
+	}
+
+	for src, reason := range map[string]string{
+		`{{< snippet "missing.go" >}}`:           "no snippet missing.go",
+		`{{< snippet "../etc/passwd" >}}`:        "no snippet ../etc/passwd",
+		`{{< snippet "hello.go" lines="4-9" >}}`: "snippet lines must be a range within 1-5, not 4-9",
+	} {
+		got, err := Render(src, snippets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got, `title="`+reason+`"`) {
+			t.Errorf("Render(%q) = %q, want the error %q", src, got, reason)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/alextreichler/personal-website/internal/mathml"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	kindMath      = ast.NewNodeKind("Math")
+	kindMathBlock = ast.NewNodeKind("MathBlock")
+)
+
+// mathInline is $...$ (or $$...$$) math within a paragraph.
+type mathInline struct {
+	ast.BaseInline
+	tex     string
+	display bool
+}
+
+func (n *mathInline) Kind() ast.NodeKind { return kindMath }
+
+func (n *mathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": n.tex}, nil)
+}
+
+// mathBlock is display math between lines starting with $$.
+type mathBlock struct {
+	ast.BaseBlock
+	tex    string
+	closed bool
+}
+
+func (n *mathBlock) Kind() ast.NodeKind { return kindMathBlock }
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": n.tex}, nil)
+}
+
+// mathExtension renders TeX math to MathML when the document is converted.
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(util.Prioritized(mathInlineParser{}, 500)),
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 750)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 500)))
+}
+
+type mathInlineParser struct{}
+
+func (mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse follows Pandoc's rules so prices don't turn into math: the opening
+// $ must be followed by a non-space, and the closing $ must follow a
+// non-space and not be followed by a digit.
+func (mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if len(line) > 1 && line[1] == '$' {
+		end := bytes.Index(line[2:], []byte("$$"))
+		if end < 0 || util.IsBlank(line[2:2+end]) {
+			return nil
+		}
+		block.Advance(end + 4)
+		return &mathInline{tex: string(line[2 : 2+end]), display: true}
+	}
+	if len(line) < 3 || util.IsSpace(line[1]) {
+		return nil
+	}
+	for i := 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '$':
+			if i+1 < len(line) && line[i+1] == '$' {
+				// $$ belongs to display math, never closes inline math
+				i++
+				continue
+			}
+			if util.IsSpace(line[i-1]) || (i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9') {
+				continue
+			}
+			block.Advance(i + 1)
+			return &mathInline{tex: string(line[1:i])}
+		}
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	rest := line[pos+2:]
+	node := &mathBlock{}
+	if end := bytes.Index(rest, []byte("$$")); end >= 0 {
+		// $$...$$ on one line, unless text follows and it is inline math
+		if !util.IsBlank(rest[end+2:]) {
+			return nil, parser.NoChildren
+		}
+		node.tex = string(rest[:end])
+		node.closed = true
+	} else {
+		node.tex = string(rest)
+	}
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*mathBlock)
+	if n.closed {
+		return parser.Close
+	}
+	line, _ := reader.PeekLine()
+	if end := bytes.Index(line, []byte("$$")); end >= 0 {
+		n.tex += string(line[:end])
+		n.closed = true
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	n.tex += string(line)
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathRenderer struct{}
+
+func (mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, renderMath)
+	reg.Register(kindMathBlock, renderMath)
+}
+
+// renderMath writes MathML, or the TeX source as code when it can't be
+// converted so the author can spot the mistake.
+func renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	var tex string
+	var display, block bool
+	switch n := node.(type) {
+	case *mathInline:
+		tex, display = n.tex, n.display
+	case *mathBlock:
+		tex, display, block = n.tex, true, true
+	}
+
+	out, err := mathml.Convert(tex, display)
+	if err != nil {
+		delim := "$"
+		if display {
+			delim = "$$"
+		}
+		out = "<code>" + html.EscapeString(delim+tex+delim) + "</code>"
+		if block {
+			out = "<pre>" + out + "</pre>"
+		}
+	}
+	_, _ = w.WriteString(out)
+	if block {
+		_ = w.WriteByte('\n')
+	}
+	return ast.WalkSkipChildren, nil
+}
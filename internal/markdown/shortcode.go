@@ -0,0 +1,504 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// A shortcode is a {{< name args >}} tag implemented in Go. Paired
+// shortcodes wrap Markdown up to a matching {{< /name >}} line.
+type shortcode struct {
+	paired bool
+	inline bool // may be used within a paragraph
+	render func(c *call) (open, close string, err error)
+}
+
+// shortcodes is the registry of tags authors can use.
+var shortcodes = map[string]shortcode{
+	"figure":    {render: figure},
+	"gallery":   {paired: true, render: gallery},
+	"callout":   {paired: true, render: callout},
+	"details":   {paired: true, render: details},
+	"youtube":   {render: youtube},
+	"post-link": {inline: true, render: postLink},
+	"snippet":   {render: snippet},
+}
+
+// call is one use of a shortcode.
+type call struct {
+	name   string
+	args   []string // Positional arguments
+	params map[string]string
+	opts   *options
+}
+
+// get returns the named parameter, falling back to the positional argument
+// at pos (or none when pos is negative).
+func (c *call) get(name string, pos int) string {
+	if v, ok := c.params[name]; ok {
+		return v
+	}
+	if pos >= 0 && pos < len(c.args) {
+		return c.args[pos]
+	}
+	return ""
+}
+
+var (
+	tagRe = regexp.MustCompile(`^\{\{<\s*(/?)([a-z][a-z0-9-]*)((?:\s+(?:"[^"]*"|'[^']*'|[^\s"'>]+(?:=(?:"[^"]*"|'[^']*'|[^\s"'>]+))?))*)\s*>\}\}`)
+	argRe = regexp.MustCompile(`(?:([a-zA-Z][\w-]*)=)?(?:"([^"]*)"|'([^']*)'|([^\s"']+))`)
+)
+
+// parseTag reads a shortcode tag at the start of line, returning its length.
+func parseTag(line []byte) (c *call, closing bool, n int) {
+	m := tagRe.FindSubmatchIndex(line)
+	if m == nil {
+		return nil, false, 0
+	}
+	c = &call{name: string(line[m[4]:m[5]]), params: map[string]string{}}
+	for _, a := range argRe.FindAllSubmatch(line[m[6]:m[7]], -1) {
+		value := string(a[2]) + string(a[3]) + string(a[4])
+		if len(a[1]) > 0 {
+			c.params[string(a[1])] = value
+		} else {
+			c.args = append(c.args, value)
+		}
+	}
+	return c, m[3] > m[2], m[1]
+}
+
+// expand renders a call into placeholders for its output, see slot.
+func expand(c *call, src []byte, inline bool) (open, close string) {
+	open, close = expandHTML(c, src, inline)
+	return c.opts.slot(open), c.opts.slot(close)
+}
+
+// expandHTML renders a call, or the tag's source with the reason as its
+// title when it fails, so the author can spot the mistake.
+func expandHTML(c *call, src []byte, inline bool) (open, close string) {
+	sc, ok := shortcodes[c.name]
+	var err error
+	switch {
+	case !ok:
+		err = fmt.Errorf("unknown shortcode %s", c.name)
+	case inline && !sc.inline:
+		err = fmt.Errorf("%s must be on a line of its own", c.name)
+	default:
+		if open, close, err = sc.render(c); err == nil {
+			return open, close
+		}
+	}
+	open = `<code class="shortcode-error" title="` + html.EscapeString(err.Error()) + `">` + html.EscapeString(string(src)) + "</code>"
+	if !inline {
+		open = "<p>" + open + "</p>\n"
+	}
+	return open, ""
+}
+
+var kindShortcode = ast.NewNodeKind("Shortcode")
+
+// shortcodeBlock is a shortcode on a line of its own. Paired shortcodes
+// hold the Markdown they wrap as children.
+type shortcodeBlock struct {
+	ast.BaseBlock
+	name        string
+	closed      bool
+	open, close string
+}
+
+func (n *shortcodeBlock) Kind() ast.NodeKind { return kindShortcode }
+
+func (n *shortcodeBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.name}, nil)
+}
+
+var kindShortcodeInline = ast.NewNodeKind("ShortcodeInline")
+
+type shortcodeInline struct {
+	ast.BaseInline
+	html string
+}
+
+func (n *shortcodeInline) Kind() ast.NodeKind { return kindShortcodeInline }
+
+func (n *shortcodeInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// shortcodeExtension expands shortcodes while the document is parsed, so
+// post links are resolved when the post is saved.
+type shortcodeExtension struct{}
+
+func (shortcodeExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(shortcodeBlockParser{}, 760)),
+		parser.WithInlineParsers(util.Prioritized(shortcodeInlineParser{}, 500)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(shortcodeRenderer{}, 500)))
+}
+
+type shortcodeBlockParser struct{}
+
+func (shortcodeBlockParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (shortcodeBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+	c, closing, n := parseTag(line[pos:])
+	if c == nil || closing || !util.IsBlank(line[pos+n:]) || shortcodes[c.name].inline {
+		return nil, parser.NoChildren
+	}
+	c.opts = contextOptions(pc)
+	node := &shortcodeBlock{name: c.name}
+	node.open, node.close = expand(c, line[pos:pos+n], false)
+	reader.AdvanceToEOL()
+	if sc, ok := shortcodes[c.name]; ok && sc.paired {
+		return node, parser.HasChildren
+	}
+	node.closed = true
+	return node, parser.NoChildren
+}
+
+func (shortcodeBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*shortcodeBlock)
+	if n.closed {
+		return parser.Close
+	}
+	line, _ := reader.PeekLine()
+	trimmed := util.TrimLeftSpace(line)
+	if c, closing, end := parseTag(trimmed); c != nil && closing && c.name == n.name && util.IsBlank(trimmed[end:]) {
+		reader.AdvanceToEOL()
+		n.closed = true
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+func (shortcodeBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (shortcodeBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (shortcodeBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type shortcodeInlineParser struct{}
+
+func (shortcodeInlineParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (shortcodeInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	c, closing, n := parseTag(line)
+	if c == nil || closing {
+		return nil
+	}
+	c.opts = contextOptions(pc)
+	open, _ := expand(c, line[:n], true)
+	block.Advance(n)
+	return &shortcodeInline{html: open}
+}
+
+type shortcodeRenderer struct{}
+
+func (shortcodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindShortcode, func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		n := node.(*shortcodeBlock)
+		if entering {
+			_, _ = w.WriteString(n.open)
+		} else {
+			_, _ = w.WriteString(n.close)
+		}
+		return ast.WalkContinue, nil
+	})
+	reg.Register(kindShortcodeInline, func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			_, _ = w.WriteString(node.(*shortcodeInline).html)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// {{< figure src="/media/a.jpg" alt="..." caption="..." >}}
+func figure(c *call) (string, string, error) {
+	src := c.get("src", 0)
+	if src == "" {
+		return "", "", errors.New("figure needs a src")
+	}
+	var b strings.Builder
+	b.WriteString(`<figure><img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(c.get("alt", -1)) + `">`)
+	if caption := c.get("caption", 1); caption != "" {
+		b.WriteString("<figcaption>" + html.EscapeString(caption) + "</figcaption>")
+	}
+	b.WriteString("</figure>\n")
+	return b.String(), "", nil
+}
+
+// {{< gallery >}} images {{< /gallery >}}
+func gallery(c *call) (string, string, error) {
+	return `<div class="gallery">` + "\n", "</div>\n", nil
+}
+
+var calloutTitles = map[string]string{
+	"note":    "Note",
+	"tip":     "Tip",
+	"warning": "Warning",
+	"danger":  "Danger",
+}
+
+// {{< callout warning "Title" >}} Markdown {{< /callout >}}
+func callout(c *call) (string, string, error) {
+	kind := c.get("type", 0)
+	if kind == "" {
+		kind = "note"
+	}
+	title, ok := calloutTitles[kind]
+	if !ok {
+		return "", "", fmt.Errorf("callout type must be note, tip, warning or danger, not %s", kind)
+	}
+	if t := c.get("title", 1); t != "" {
+		title = t
+	}
+	return `<aside class="callout callout-` + kind + `"><p class="callout-title">` + html.EscapeString(title) + "</p>\n", "</aside>\n", nil
+}
+
+// {{< details "Summary" open >}} Markdown {{< /details >}}
+func details(c *call) (string, string, error) {
+	summary := c.get("summary", 0)
+	if summary == "" {
+		summary = "Details"
+	}
+	open := "<details>"
+	if _, ok := c.params["open"]; ok || (len(c.args) > 1 && c.args[1] == "open") {
+		open = "<details open>"
+	}
+	return open + "<summary>" + html.EscapeString(summary) + "</summary>\n", "</details>\n", nil
+}
+
+var (
+	youtubeID  = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	youtubeURL = regexp.MustCompile(`(?:youtu\.be/|[?&]v=|/embed/|/shorts/)([A-Za-z0-9_-]{11})`)
+)
+
+// {{< youtube dQw4w9WgXcQ start=30 >}} embeds from youtube-nocookie.com,
+// which sets no cookies until the visitor plays the video.
+func youtube(c *call) (string, string, error) {
+	id := c.get("id", 0)
+	if m := youtubeURL.FindStringSubmatch(id); m != nil {
+		id = m[1]
+	}
+	if !youtubeID.MatchString(id) {
+		return "", "", fmt.Errorf("youtube needs a video ID, not %s", id)
+	}
+	src := "https://www.youtube-nocookie.com/embed/" + id
+	if start := c.get("start", -1); start != "" {
+		if _, err := strconv.Atoi(start); err != nil {
+			return "", "", fmt.Errorf("youtube start must be seconds, not %s", start)
+		}
+		src += "?start=" + start
+	}
+	title := c.get("title", 1)
+	if title == "" {
+		title = "YouTube video"
+	}
+	return `<div class="video"><iframe src="` + src + `" title="` + html.EscapeString(title) + `" loading="lazy"` +
+		` allow="encrypted-media; picture-in-picture" referrerpolicy="strict-origin-when-cross-origin"` +
+		` sandbox="allow-scripts allow-same-origin allow-presentation allow-popups" allowfullscreen></iframe></div>` + "\n", "", nil
+}
+
+// {{< post-link my-slug "optional text" >}} links to another post by slug,
+// using its title as the text. Links to posts that aren't published are
+// marked as broken.
+func postLink(c *call) (string, string, error) {
+	slug := c.get("slug", 0)
+	if slug == "" {
+		return "", "", errors.New("post-link needs a slug")
+	}
+	label := c.get("text", 1)
+	if c.opts.posts != nil {
+		title, ok := c.opts.posts(slug)
+		if !ok {
+			if label == "" {
+				label = slug
+			}
+			return `<span class="broken-link" title="No published post with slug ` + html.EscapeString(slug) + `">` + html.EscapeString(label) + "</span>", "", nil
+		}
+		if label == "" {
+			label = title
+		}
+	}
+	if label == "" {
+		label = slug
+	}
+	return `<a href="/post/` + html.EscapeString(url.PathEscape(slug)) + `">` + html.EscapeString(label) + "</a>", "", nil
+}
+
+// {{< snippet "hello.go" lines="3-10" lang=go >}} shows a file from the
+// snippets directory as a highlighted code block. The language defaults to
+// the file extension. The file is read when the post is rendered, so edits
+// show up once posts are re-rendered.
+func snippet(c *call) (string, string, error) {
+	name := c.get("file", 0)
+	if name == "" {
+		return "", "", errors.New("snippet needs a file name")
+	}
+	if c.opts.snippets == nil {
+		return "", "", errors.New("snippets are not configured")
+	}
+	content, err := readSnippet(c.opts.snippets, name)
+	if err != nil {
+		return "", "", err
+	}
+	if lines := c.get("lines", -1); lines != "" {
+		if content, err = snippetLines(content, lines); err != nil {
+			return "", "", err
+		}
+	}
+	lang := c.get("lang", 1)
+	if lang == "" {
+		lang = strings.TrimPrefix(path.Ext(name), ".")
+	}
+	if !snippetLang.MatchString(lang) {
+		return "", "", fmt.Errorf("snippet language must be a word, not %s", lang)
+	}
+
+	// Highlight it as a fenced code block, with a fence longer than any
+	// backtick run in the file
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(fence+lang+"\n"+content+"\n"+fence+"\n"), &buf); err != nil {
+		return "", "", err
+	}
+	return `<figure class="snippet">` + buf.String() + "<figcaption>" + html.EscapeString(path.Base(name)) + "</figcaption></figure>\n", "", nil
+}
+
+// maxSnippet is the largest file the snippet shortcode includes.
+const maxSnippet = 256 << 10
+
+var snippetLang = regexp.MustCompile(`^[A-Za-z0-9_+-]*$`)
+
+func readSnippet(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("no snippet %s", name)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(io.LimitReader(f, maxSnippet+1))
+	if err != nil {
+		return "", fmt.Errorf("reading snippet %s: %w", name, err)
+	}
+	if len(content) > maxSnippet {
+		return "", fmt.Errorf("snippet %s is larger than %d KiB", name, maxSnippet>>10)
+	}
+	return strings.TrimRight(string(content), "\n"), nil
+}
+
+// snippetLines picks a line or a range of lines such as "3-10", counting
+// from 1.
+func snippetLines(content, spec string) (string, error) {
+	first, last, isRange := strings.Cut(spec, "-")
+	if !isRange {
+		last = first
+	}
+	from, err1 := strconv.Atoi(first)
+	to, err2 := strconv.Atoi(last)
+	lines := strings.Split(content, "\n")
+	if err1 != nil || err2 != nil || from < 1 || to < from || to > len(lines) {
+		return "", fmt.Errorf("snippet lines must be a range within 1-%d, not %s", len(lines), spec)
+	}
+	return strings.Join(lines[from-1:to], "\n"), nil
+}
+
+// Option configures a Render call.
+type Option func(*options)
+
+type options struct {
+	posts    func(slug string) (title string, ok bool)
+	snippets fs.FS
+	slots    []string // Shortcode output, by placeholder
+}
+
+// Placeholders for shortcode output are made of private use characters,
+// which are removed from the source so authors can't forge them.
+const (
+	slotStart = "\uE000"
+	slotEnd   = "\uE001"
+)
+
+var (
+	stripSlots = strings.NewReplacer(slotStart, "", slotEnd, "")
+	slotRe     = regexp.MustCompile(slotStart + `([0-9]+)` + slotEnd)
+)
+
+// slot keeps shortcode output aside and returns the placeholder the
+// renderer writes in its place.
+func (o *options) slot(html string) string {
+	if html == "" {
+		return ""
+	}
+	o.slots = append(o.slots, html)
+	return slotStart + strconv.Itoa(len(o.slots)-1) + slotEnd
+}
+
+// fillSlots replaces the placeholders in sanitized HTML with the shortcode
+// output they stand for, sanitized with the shortcode policy.
+func (o *options) fillSlots(safeHTML string) string {
+	return slotRe.ReplaceAllStringFunc(safeHTML, func(m string) string {
+		i, err := strconv.Atoi(slotRe.FindStringSubmatch(m)[1])
+		if err != nil || i >= len(o.slots) {
+			return ""
+		}
+		return shortcodePolicy.Sanitize(o.slots[i])
+	})
+}
+
+// WithPosts resolves post-link shortcodes: lookup returns the title of the
+// published post with slug.
+func WithPosts(lookup func(slug string) (title string, ok bool)) Option {
+	return func(o *options) {
+		o.posts = lookup
+	}
+}
+
+// WithSnippets resolves snippet shortcodes to files in fsys.
+func WithSnippets(fsys fs.FS) Option {
+	return func(o *options) {
+		o.snippets = fsys
+	}
+}
+
+var optionsKey = parser.NewContextKey()
+
+func contextOptions(pc parser.Context) *options {
+	if o, ok := pc.Get(optionsKey).(*options); ok {
+		return o
+	}
+	return &options{}
+}
@@ -0,0 +1,820 @@
+// Package mathml converts TeX math, in the subset KaTeX users typically
+// write, to MathML that browsers render natively.
+package mathml
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Convert renders a TeX math expression as a MathML <math> element. Display
+// math is rendered as a block. The TeX source is kept as an annotation so
+// copying the formula yields the original text.
+func Convert(tex string, display bool) (string, error) {
+	toks, err := lex(tex)
+	if err != nil {
+		return "", err
+	}
+	p := &parser{toks: toks, display: display}
+	nodes, err := p.parseRow(nil)
+	if err != nil {
+		return "", err
+	}
+
+	mode := "inline"
+	if display {
+		mode = "block"
+	}
+	return `<math xmlns="http://www.w3.org/1998/Math/MathML" display="` + mode + `"><semantics>` +
+		row(nodes) +
+		`<annotation encoding="application/x-tex">` + html.EscapeString(strings.TrimSpace(tex)) + `</annotation>` +
+		`</semantics></math>`, nil
+}
+
+type tokenKind int
+
+const (
+	tokChar    tokenKind = iota // a letter or symbol
+	tokNumber                   // digits with an optional decimal point
+	tokCommand                  // \name, without the backslash
+	tokText                     // raw braced argument of \text and similar
+	tokOpen                     // {
+	tokClose                    // }
+	tokSup                      // ^
+	tokSub                      // _
+	tokAmp                      // & between environment cells
+	tokNewline                  // \\ between environment rows
+	tokPrime                    // '
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+}
+
+// rawArgCommands take their braced argument as text rather than math.
+var rawArgCommands = map[string]bool{
+	"text": true, "textrm": true, "textit": true, "textbf": true, "textsf": true,
+	"texttt": true, "mbox": true, "operatorname": true, "mathrm": true,
+	"mathit": true, "mathbf": true, "mathbb": true, "mathcal": true,
+	"mathsf": true, "begin": true, "end": true,
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '%':
+			// Comment to the end of the line
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '\\':
+			if i+1 >= len(s) {
+				return nil, errors.New("trailing backslash")
+			}
+			j := i + 1
+			if isASCIILetter(s[j]) {
+				for j < len(s) && isASCIILetter(s[j]) {
+					j++
+				}
+			} else {
+				_, size := utf8.DecodeRuneInString(s[j:])
+				j += size
+			}
+			name := s[i+1 : j]
+			i = j
+			if name == `\` {
+				toks = append(toks, token{tokNewline, name})
+				continue
+			}
+			toks = append(toks, token{tokCommand, name})
+			if rawArgCommands[name] {
+				arg, end, err := rawArg(s, i)
+				if err != nil {
+					return nil, fmt.Errorf(`\%s: %w`, name, err)
+				}
+				toks = append(toks, token{tokText, arg})
+				i = end
+			}
+		case c == '{':
+			toks = append(toks, token{tokOpen, "{"})
+			i++
+		case c == '}':
+			toks = append(toks, token{tokClose, "}"})
+			i++
+		case c == '^':
+			toks = append(toks, token{tokSup, "^"})
+			i++
+		case c == '_':
+			toks = append(toks, token{tokSub, "_"})
+			i++
+		case c == '&':
+			toks = append(toks, token{tokAmp, "&"})
+			i++
+		case c == '\'':
+			toks = append(toks, token{tokPrime, "'"})
+			i++
+		case isDigit(c) || (c == '.' && i+1 < len(s) && isDigit(s[i+1])):
+			j, dot := i, false
+			for j < len(s) && (isDigit(s[j]) || (s[j] == '.' && !dot && j+1 < len(s) && isDigit(s[j+1]))) {
+				if s[j] == '.' {
+					dot = true
+				}
+				j++
+			}
+			toks = append(toks, token{tokNumber, s[i:j]})
+			i = j
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			toks = append(toks, token{tokChar, string(r)})
+			i += size
+		}
+	}
+	return toks, nil
+}
+
+// rawArg reads a braced argument starting at or after i, returning its
+// content and the offset after the closing brace.
+func rawArg(s string, i int) (string, int, error) {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	if i >= len(s) || s[i] != '{' {
+		return "", 0, errors.New("missing {argument}")
+	}
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[i+1 : j], j + 1, nil
+			}
+		}
+	}
+	return "", 0, errors.New("unbalanced braces")
+}
+
+type parser struct {
+	toks    []token
+	pos     int
+	display bool
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.toks) {
+		return token{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+func (p *parser) isCommand(name string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == tokCommand && t.val == name
+}
+
+func isClose(t token) bool { return t.kind == tokClose }
+
+// parseRow parses atoms until the input ends or stop matches the next token.
+func (p *parser) parseRow(stop func(token) bool) ([]string, error) {
+	var nodes []string
+	for {
+		t, ok := p.peek()
+		if !ok || (stop != nil && stop(t)) {
+			return nodes, nil
+		}
+		node, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		if node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// parseScripted parses an atom with its primes, subscript and superscript.
+func (p *parser) parseScripted() (string, error) {
+	var base string
+	var limits bool
+	if t, _ := p.peek(); t.kind != tokSup && t.kind != tokSub {
+		var err error
+		if base, limits, err = p.parseAtom(); err != nil {
+			return "", err
+		}
+	}
+
+	var sub, sup, primes string
+loop:
+	for {
+		t, ok := p.peek()
+		if !ok {
+			break
+		}
+		var err error
+		switch {
+		case t.kind == tokPrime:
+			p.pos++
+			primes += "′"
+		case t.kind == tokCommand && t.val == "limits":
+			p.pos++
+			limits = true
+		case t.kind == tokCommand && t.val == "nolimits":
+			p.pos++
+			limits = false
+		case t.kind == tokSup && sup == "":
+			p.pos++
+			sup, err = p.parseArg()
+		case t.kind == tokSub && sub == "":
+			p.pos++
+			sub, err = p.parseArg()
+		default:
+			break loop
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if primes != "" {
+		if sup == "" {
+			sup = "<mo>" + primes + "</mo>"
+		} else {
+			sup = "<mrow><mo>" + primes + "</mo>" + sup + "</mrow>"
+		}
+	}
+	if sub == "" && sup == "" {
+		return base, nil
+	}
+	if base == "" {
+		base = "<mrow></mrow>"
+	}
+
+	under := limits && p.display
+	switch {
+	case sub != "" && sup != "" && under:
+		return "<munderover>" + base + sub + sup + "</munderover>", nil
+	case sub != "" && sup != "":
+		return "<msubsup>" + base + sub + sup + "</msubsup>", nil
+	case sub != "" && under:
+		return "<munder>" + base + sub + "</munder>", nil
+	case sub != "":
+		return "<msub>" + base + sub + "</msub>", nil
+	case under:
+		return "<mover>" + base + sup + "</mover>", nil
+	default:
+		return "<msup>" + base + sup + "</msup>", nil
+	}
+}
+
+// parseArg parses a command or script argument: a braced group or a single
+// token. Only the first digit of a number is taken, as in TeX's x^23.
+func (p *parser) parseArg() (string, error) {
+	t, ok := p.peek()
+	if !ok {
+		return "", errors.New("missing argument")
+	}
+	switch t.kind {
+	case tokOpen:
+		return p.parseGroup()
+	case tokNumber:
+		if len(t.val) > 1 {
+			p.toks[p.pos].val = t.val[1:]
+			return "<mn>" + t.val[:1] + "</mn>", nil
+		}
+	case tokSup, tokSub, tokClose, tokAmp:
+		return "", fmt.Errorf("missing argument before %q", t.val)
+	}
+	node, _, err := p.parseAtom()
+	return node, err
+}
+
+// parseGroup parses a {...} group.
+func (p *parser) parseGroup() (string, error) {
+	p.pos++ // {
+	nodes, err := p.parseRow(isClose)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := p.peek(); !ok {
+		return "", errors.New("missing }")
+	}
+	p.pos++ // }
+	if len(nodes) == 0 {
+		return "<mrow></mrow>", nil
+	}
+	return row(nodes), nil
+}
+
+// parseAtom parses one element. limits reports whether scripts go above and
+// below in display math.
+func (p *parser) parseAtom() (node string, limits bool, err error) {
+	t := p.toks[p.pos]
+	switch t.kind {
+	case tokChar:
+		p.pos++
+		return charNode(t.val), false, nil
+	case tokNumber:
+		p.pos++
+		return "<mn>" + t.val + "</mn>", false, nil
+	case tokOpen:
+		node, err := p.parseGroup()
+		return node, false, err
+	case tokPrime:
+		p.pos++
+		return "<mo>′</mo>", false, nil
+	case tokNewline:
+		// Line breaks outside environments are ignored
+		p.pos++
+		return "", false, nil
+	case tokCommand:
+		p.pos++
+		return p.command(t.val)
+	case tokClose:
+		return "", false, errors.New("unexpected }")
+	case tokAmp:
+		return "", false, errors.New("& outside of an environment")
+	}
+	return "", false, fmt.Errorf("unexpected %q", t.val)
+}
+
+func charNode(c string) string {
+	r, _ := utf8.DecodeRuneInString(c)
+	if unicode.IsLetter(r) {
+		return "<mi>" + html.EscapeString(c) + "</mi>"
+	}
+	switch c {
+	case "-":
+		return "<mo>−</mo>"
+	case "*":
+		return "<mo>∗</mo>"
+	case "(", ")", "[", "]", "|":
+		return `<mo stretchy="false">` + c + "</mo>"
+	case "~":
+		return `<mspace width="0.25em"/>`
+	}
+	return "<mo>" + html.EscapeString(c) + "</mo>"
+}
+
+// textArg returns the raw argument lexed after a text-like command.
+func (p *parser) textArg(name string) (string, error) {
+	t, ok := p.peek()
+	if !ok || t.kind != tokText {
+		return "", fmt.Errorf(`\%s needs an argument`, name)
+	}
+	p.pos++
+	return t.val, nil
+}
+
+func (p *parser) command(name string) (string, bool, error) {
+	if s, ok := symbols[name]; ok {
+		text := html.EscapeString(s.text)
+		switch s.kind {
+		case symIdent:
+			return "<mi>" + text + "</mi>", false, nil
+		case symOperator:
+			return "<mo>" + text + "</mo>", false, nil
+		case symLargeOp:
+			return "<mo>" + text + "</mo>", true, nil
+		case symIntegral:
+			return "<mo>" + text + "</mo>", false, nil
+		case symFunction, symLimitFn:
+			node := "<mi>" + text + "</mi>"
+			if utf8.RuneCountInString(s.text) == 1 {
+				node = `<mi mathvariant="normal">` + text + "</mi>"
+			}
+			return node, s.kind == symLimitFn, nil
+		}
+	}
+	if width, ok := spaces[name]; ok {
+		return `<mspace width="` + width + `"/>`, false, nil
+	}
+	if mark, ok := accents[name]; ok {
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		stretchy := "false"
+		if strings.HasPrefix(name, "wide") || strings.HasPrefix(name, "over") {
+			stretchy = "true"
+		}
+		return `<mover accent="true">` + arg + `<mo stretchy="` + stretchy + `">` + html.EscapeString(mark) + "</mo></mover>", false, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac", "binom", "dbinom", "tbinom":
+		num, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		den, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		node := "<mfrac>" + num + den + "</mfrac>"
+		if strings.HasSuffix(name, "binom") {
+			node = `<mrow><mo>(</mo><mfrac linethickness="0">` + num + den + "</mfrac><mo>)</mo></mrow>"
+		}
+		switch name[0] {
+		case 'd', 'c':
+			node = `<mstyle displaystyle="true">` + node + "</mstyle>"
+		case 't':
+			node = `<mstyle displaystyle="false">` + node + "</mstyle>"
+		}
+		return node, false, nil
+
+	case "sqrt":
+		var index string
+		if t, ok := p.peek(); ok && t.kind == tokChar && t.val == "[" {
+			p.pos++
+			nodes, err := p.parseRow(func(t token) bool { return t.kind == tokChar && t.val == "]" })
+			if err != nil {
+				return "", false, err
+			}
+			if _, ok := p.peek(); !ok {
+				return "", false, errors.New(`missing ] in \sqrt`)
+			}
+			p.pos++
+			index = row(nodes)
+		}
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		if index != "" {
+			return "<mroot>" + arg + index + "</mroot>", false, nil
+		}
+		return "<msqrt>" + arg + "</msqrt>", false, nil
+
+	case "underline":
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		return `<munder accentunder="true">` + arg + `<mo stretchy="true">_</mo></munder>`, false, nil
+
+	case "text", "textrm", "textit", "textbf", "textsf", "texttt", "mbox":
+		text, err := p.textArg(name)
+		if err != nil {
+			return "", false, err
+		}
+		return "<mtext>" + html.EscapeString(text) + "</mtext>", false, nil
+
+	case "operatorname", "mathrm":
+		text, err := p.textArg(name)
+		if err != nil {
+			return "", false, err
+		}
+		return uprightNode(text), false, nil
+
+	case "mathit", "mathbf", "mathbb", "mathcal", "mathsf":
+		text, err := p.textArg(name)
+		if err != nil {
+			return "", false, err
+		}
+		return styledNode(text, name), false, nil
+
+	case "not":
+		t, ok := p.peek()
+		if !ok {
+			return "", false, errors.New(`\not needs an argument`)
+		}
+		p.pos++
+		var base string
+		switch {
+		case t.kind == tokCommand && symbols[t.val].text != "":
+			base = symbols[t.val].text
+		case t.kind == tokChar:
+			base = t.val
+		default:
+			return "", false, fmt.Errorf(`cannot negate %q`, t.val)
+		}
+		return "<mo>" + html.EscapeString(base) + "̸</mo>", false, nil
+
+	case "bmod", "mod":
+		return `<mo lspace="0.2222em" rspace="0.2222em">mod</mo>`, false, nil
+
+	case "pmod":
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		return `<mrow><mspace width="1em"/><mo stretchy="false">(</mo><mi>mod</mi><mspace width="0.3333em"/>` + arg + `<mo stretchy="false">)</mo></mrow>`, false, nil
+
+	case "left":
+		open, err := p.fence()
+		if err != nil {
+			return "", false, err
+		}
+		nodes, err := p.parseRow(func(t token) bool { return t.kind == tokCommand && t.val == "right" })
+		if err != nil {
+			return "", false, err
+		}
+		if !p.isCommand("right") {
+			return "", false, errors.New(`\left without \right`)
+		}
+		p.pos++
+		closing, err := p.fence()
+		if err != nil {
+			return "", false, err
+		}
+		node := "<mrow>"
+		if open != "" {
+			node += `<mo fence="true" form="prefix">` + html.EscapeString(open) + "</mo>"
+		}
+		node += strings.Join(nodes, "")
+		if closing != "" {
+			node += `<mo fence="true" form="postfix">` + html.EscapeString(closing) + "</mo>"
+		}
+		return node + "</mrow>", false, nil
+
+	case "middle":
+		delim, err := p.fence()
+		if err != nil {
+			return "", false, err
+		}
+		return `<mo fence="true" form="infix">` + html.EscapeString(delim) + "</mo>", false, nil
+
+	case "right":
+		return "", false, errors.New(`\right without \left`)
+
+	case "big", "bigl", "bigr", "Big", "Bigl", "Bigr", "bigg", "biggl", "biggr", "Bigg", "Biggl", "Biggr":
+		delim, err := p.fence()
+		if err != nil {
+			return "", false, err
+		}
+		size := map[string]string{"big": "1.2em", "Big": "1.8em", "bigg": "2.4em", "Bigg": "3em"}[strings.TrimRight(name, "lr")]
+		return `<mo fence="false" stretchy="true" minsize="` + size + `" maxsize="` + size + `">` + html.EscapeString(delim) + "</mo>", false, nil
+
+	case "begin":
+		env, err := p.textArg(name)
+		if err != nil {
+			return "", false, err
+		}
+		node, err := p.environment(env)
+		return node, false, err
+
+	case "end":
+		return "", false, errors.New(`\end without \begin`)
+
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return "", false, nil
+	}
+
+	// Unknown commands are shown, flagged, rather than failing the formula
+	return `<merror><mtext>\` + html.EscapeString(name) + `</mtext></merror>`, false, nil
+}
+
+// fence reads the delimiter after \left, \right, \middle or \big.
+func (p *parser) fence() (string, error) {
+	t, ok := p.peek()
+	if !ok {
+		return "", errors.New("missing delimiter")
+	}
+	key := t.val
+	if t.kind == tokCommand {
+		key = `\` + t.val
+	}
+	delim, ok := fences[key]
+	if !ok || (t.kind != tokChar && t.kind != tokCommand) {
+		return "", fmt.Errorf("invalid delimiter %q", key)
+	}
+	p.pos++
+	return delim, nil
+}
+
+// environments maps supported environments to their fences and column
+// alignment.
+var environments = map[string]struct {
+	open, close string
+	align       string // repeated per column pair
+}{
+	"matrix":      {"", "", "center"},
+	"smallmatrix": {"", "", "center"},
+	"pmatrix":     {"(", ")", "center"},
+	"bmatrix":     {"[", "]", "center"},
+	"Bmatrix":     {"{", "}", "center"},
+	"vmatrix":     {"|", "|", "center"},
+	"Vmatrix":     {"‖", "‖", "center"},
+	"cases":       {"{", "", "left"},
+	"array":       {"", "", "center"},
+	"aligned":     {"", "", "right left"},
+	"align":       {"", "", "right left"},
+	"align*":      {"", "", "right left"},
+	"gathered":    {"", "", "center"},
+	"gather":      {"", "", "center"},
+	"gather*":     {"", "", "center"},
+	"split":       {"", "", "right left"},
+}
+
+func (p *parser) environment(name string) (string, error) {
+	if name == "equation" || name == "equation*" {
+		nodes, err := p.parseRow(func(t token) bool { return t.kind == tokCommand && t.val == "end" })
+		if err != nil {
+			return "", err
+		}
+		if err := p.endEnvironment(name); err != nil {
+			return "", err
+		}
+		return row(nodes), nil
+	}
+
+	env, ok := environments[name]
+	if !ok {
+		return "", fmt.Errorf("unsupported environment %q", name)
+	}
+	if name == "array" {
+		// Skip the column specification
+		if t, ok := p.peek(); ok && t.kind == tokOpen {
+			for t, ok := p.peek(); ok && t.kind != tokClose; t, ok = p.peek() {
+				p.pos++
+			}
+			p.pos++
+		}
+	}
+
+	stop := func(t token) bool {
+		return t.kind == tokAmp || t.kind == tokNewline || (t.kind == tokCommand && t.val == "end")
+	}
+	var rows [][]string
+	var cells []string
+	columns := 0
+	for {
+		nodes, err := p.parseRow(stop)
+		if err != nil {
+			return "", err
+		}
+		cells = append(cells, row(nodes))
+
+		t, ok := p.peek()
+		if !ok {
+			return "", fmt.Errorf(`missing \end{%s}`, name)
+		}
+		if t.kind == tokAmp {
+			p.pos++
+			continue
+		}
+		// End of a row; a trailing \\ leaves an empty last row, which is dropped
+		last := t.kind == tokCommand
+		if !last || len(cells) > 1 || len(nodes) > 0 {
+			rows = append(rows, cells)
+			columns = max(columns, len(cells))
+		}
+		cells = nil
+		if last {
+			break
+		}
+		p.pos++ // \\
+	}
+	if err := p.endEnvironment(name); err != nil {
+		return "", err
+	}
+
+	aligns := strings.Fields(env.align)
+	var columnAlign []string
+	for i := 0; i < columns; i++ {
+		columnAlign = append(columnAlign, aligns[i%len(aligns)])
+	}
+
+	var b strings.Builder
+	b.WriteString(`<mtable columnalign="` + strings.Join(columnAlign, " ") + `">`)
+	for _, cells := range rows {
+		b.WriteString("<mtr>")
+		for _, cell := range cells {
+			b.WriteString("<mtd>" + cell + "</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+
+	if env.open == "" && env.close == "" {
+		return b.String(), nil
+	}
+	node := "<mrow>"
+	if env.open != "" {
+		node += `<mo fence="true" form="prefix">` + html.EscapeString(env.open) + "</mo>"
+	}
+	node += b.String()
+	if env.close != "" {
+		node += `<mo fence="true" form="postfix">` + html.EscapeString(env.close) + "</mo>"
+	}
+	return node + "</mrow>", nil
+}
+
+func (p *parser) endEnvironment(name string) error {
+	if !p.isCommand("end") {
+		return fmt.Errorf(`missing \end{%s}`, name)
+	}
+	p.pos++
+	end, err := p.textArg("end")
+	if err != nil {
+		return err
+	}
+	if end != name {
+		return fmt.Errorf(`\begin{%s} ended by \end{%s}`, name, end)
+	}
+	return nil
+}
+
+// uprightNode renders \mathrm or \operatorname text as upright identifiers.
+func uprightNode(text string) string {
+	var nodes []string
+	for _, word := range strings.Fields(text) {
+		node := "<mi>" + html.EscapeString(word) + "</mi>"
+		if utf8.RuneCountInString(word) == 1 {
+			node = `<mi mathvariant="normal">` + html.EscapeString(word) + "</mi>"
+		}
+		nodes = append(nodes, node)
+	}
+	return row(nodes)
+}
+
+// styledNode renders \mathbf, \mathbb and similar with the Unicode
+// mathematical alphanumeric symbols, which every font-capable browser shows.
+func styledNode(text, style string) string {
+	var nodes []string
+	for _, r := range text {
+		switch {
+		case r == ' ':
+		case unicode.IsDigit(r):
+			nodes = append(nodes, "<mn>"+string(styleRune(r, style))+"</mn>")
+		case unicode.IsLetter(r):
+			nodes = append(nodes, "<mi>"+string(styleRune(r, style))+"</mi>")
+		default:
+			nodes = append(nodes, "<mo>"+html.EscapeString(string(r))+"</mo>")
+		}
+	}
+	if len(nodes) == 0 {
+		return "<mrow></mrow>"
+	}
+	return row(nodes)
+}
+
+// Letters missing from the mathematical alphanumeric block, which live in
+// Letterlike Symbols instead.
+var (
+	doubleStruck = map[rune]rune{'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ'}
+	script       = map[rune]rune{'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ'}
+)
+
+func styleRune(r rune, style string) rune {
+	var upper, lower, digit rune
+	switch style {
+	case "mathbf":
+		upper, lower, digit = 0x1D400, 0x1D41A, 0x1D7CE
+	case "mathbb":
+		if s, ok := doubleStruck[r]; ok {
+			return s
+		}
+		upper, lower, digit = 0x1D538, 0x1D552, 0x1D7D8
+	case "mathcal":
+		if s, ok := script[r]; ok {
+			return s
+		}
+		upper, lower = 0x1D49C, 0x1D4B6
+	case "mathsf":
+		upper, lower, digit = 0x1D5A0, 0x1D5BA, 0x1D7E2
+	case "mathit":
+		if r == 'h' {
+			return 'ℎ'
+		}
+		upper, lower = 0x1D434, 0x1D44E
+	}
+	switch {
+	case r >= 'A' && r <= 'Z' && upper != 0:
+		return upper + r - 'A'
+	case r >= 'a' && r <= 'z' && lower != 0:
+		return lower + r - 'a'
+	case r >= '0' && r <= '9' && digit != 0:
+		return digit + r - '0'
+	}
+	return r
+}
+
+// row wraps several nodes in an mrow.
+func row(nodes []string) string {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return "<mrow>" + strings.Join(nodes, "") + "</mrow>"
+}
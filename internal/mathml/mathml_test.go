@@ -0,0 +1,89 @@
+package mathml
+
+import (
+	"strings"
+	"testing"
+)
+
+// body strips the <math> wrapper and annotation from Convert's output.
+func body(t *testing.T, tex string, display bool) string {
+	t.Helper()
+	out, err := Convert(tex, display)
+	if err != nil {
+		t.Fatalf("Convert(%q): %v", tex, err)
+	}
+	out = out[strings.Index(out, "<semantics>")+len("<semantics>"):]
+	return out[:strings.Index(out, "<annotation")]
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		tex     string
+		display bool
+		want    string
+	}{
+		{`x^2`, false, `<msup><mi>x</mi><mn>2</mn></msup>`},
+		{`x^23`, false, `<mrow><msup><mi>x</mi><mn>2</mn></msup><mn>3</mn></mrow>`},
+		{`a_{ij} - 1.5`, false, `<mrow><msub><mi>a</mi><mrow><mi>i</mi><mi>j</mi></mrow></msub><mo>−</mo><mn>1.5</mn></mrow>`},
+		{`\frac{1}{2}`, false, `<mfrac><mn>1</mn><mn>2</mn></mfrac>`},
+		{`\sqrt[3]{x}`, false, `<mroot><mi>x</mi><mn>3</mn></mroot>`},
+		{`\sum_{i}^{n}`, false, `<msubsup><mo>∑</mo><mi>i</mi><mi>n</mi></msubsup>`},
+		{`\sum_{i}^{n}`, true, `<munderover><mo>∑</mo><mi>i</mi><mi>n</mi></munderover>`},
+		{`\int_0^1`, true, `<msubsup><mo>∫</mo><mn>0</mn><mn>1</mn></msubsup>`},
+		{`\lim_{x \to 0}`, true, `<munder><mi>lim</mi><mrow><mi>x</mi><mo>→</mo><mn>0</mn></mrow></munder>`},
+		{`f'(x)`, false, `<mrow><msup><mi>f</mi><mo>′</mo></msup><mo stretchy="false">(</mo><mi>x</mi><mo stretchy="false">)</mo></mrow>`},
+		{`\alpha \Gamma \infty`, false, `<mrow><mi>α</mi><mi mathvariant="normal">Γ</mi><mi>∞</mi></mrow>`},
+		{`a < b`, false, `<mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow>`},
+		{`\left( x \right.`, false, `<mrow><mo fence="true" form="prefix">(</mo><mi>x</mi></mrow>`},
+		{`\hat{x}`, false, `<mover accent="true"><mi>x</mi><mo stretchy="false">^</mo></mover>`},
+		{`\mathbb{R}\mathbf{v}`, false, `<mrow><mi>ℝ</mi><mi>𝐯</mi></mrow>`},
+		{`\text{if } x`, false, `<mrow><mtext>if </mtext><mi>x</mi></mrow>`},
+		{`\mathrm{d}x`, false, `<mrow><mi mathvariant="normal">d</mi><mi>x</mi></mrow>`},
+		{`a\,b`, false, `<mrow><mi>a</mi><mspace width="0.1667em"/><mi>b</mi></mrow>`},
+		{`\foo`, false, `<merror><mtext>\foo</mtext></merror>`},
+		{
+			`\begin{pmatrix} a & b \\ c & d \\ \end{pmatrix}`, true,
+			`<mrow><mo fence="true" form="prefix">(</mo><mtable columnalign="center center"><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable><mo fence="true" form="postfix">)</mo></mrow>`,
+		},
+		{
+			`\begin{aligned} x &= 1 \end{aligned}`, true,
+			`<mtable columnalign="right left"><mtr><mtd><mi>x</mi></mtd><mtd><mrow><mo>=</mo><mn>1</mn></mrow></mtd></mtr></mtable>`,
+		},
+	}
+	for _, tt := range tests {
+		if got := body(t, tt.tex, tt.display); got != tt.want {
+			t.Errorf("Convert(%q, %v)\n got: %s\nwant: %s", tt.tex, tt.display, got, tt.want)
+		}
+	}
+}
+
+func TestConvertWrapper(t *testing.T) {
+	out, err := Convert(`x<y`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><mrow><mi>x</mi><mo>&lt;</mo><mi>y</mi></mrow><annotation encoding="application/x-tex">x&lt;y</annotation></semantics></math>`
+	if out != want {
+		t.Errorf("got  %s\nwant %s", out, want)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	for _, tex := range []string{
+		`{x`,
+		`x}`,
+		`\frac{1}`,
+		`\left( x`,
+		`\right)`,
+		`\text x`,
+		`\begin{matrix} a`,
+		`\begin{matrix} a \end{pmatrix}`,
+		`\begin{tikzpicture}\end{tikzpicture}`,
+		`a & b`,
+		`x\`,
+	} {
+		if _, err := Convert(tex, false); err == nil {
+			t.Errorf("Convert(%q): expected an error", tex)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package mathml
+
+// symbolKind says which MathML element a TeX symbol becomes.
+type symbolKind int
+
+const (
+	symIdent    symbolKind = iota // <mi>
+	symOperator                   // <mo>
+	symLargeOp                    // <mo>, takes limits above and below in display math
+	symIntegral                   // <mo>, scripts always to the side
+	symFunction                   // <mi> with an upright multi-letter name
+	symLimitFn                    // function name taking limits below in display math
+)
+
+type symbol struct {
+	text string
+	kind symbolKind
+}
+
+var symbols = map[string]symbol{
+	// Lowercase Greek
+	"alpha": {"α", symIdent}, "beta": {"β", symIdent}, "gamma": {"γ", symIdent},
+	"delta": {"δ", symIdent}, "epsilon": {"ϵ", symIdent}, "varepsilon": {"ε", symIdent},
+	"zeta": {"ζ", symIdent}, "eta": {"η", symIdent}, "theta": {"θ", symIdent},
+	"vartheta": {"ϑ", symIdent}, "iota": {"ι", symIdent}, "kappa": {"κ", symIdent},
+	"lambda": {"λ", symIdent}, "mu": {"μ", symIdent}, "nu": {"ν", symIdent},
+	"xi": {"ξ", symIdent}, "pi": {"π", symIdent}, "varpi": {"ϖ", symIdent},
+	"rho": {"ρ", symIdent}, "varrho": {"ϱ", symIdent}, "sigma": {"σ", symIdent},
+	"varsigma": {"ς", symIdent}, "tau": {"τ", symIdent}, "upsilon": {"υ", symIdent},
+	"phi": {"ϕ", symIdent}, "varphi": {"φ", symIdent}, "chi": {"χ", symIdent},
+	"psi": {"ψ", symIdent}, "omega": {"ω", symIdent},
+
+	// Uppercase Greek, upright as in TeX
+	"Gamma": {"Γ", symFunction}, "Delta": {"Δ", symFunction}, "Theta": {"Θ", symFunction},
+	"Lambda": {"Λ", symFunction}, "Xi": {"Ξ", symFunction}, "Pi": {"Π", symFunction},
+	"Sigma": {"Σ", symFunction}, "Upsilon": {"Υ", symFunction}, "Phi": {"Φ", symFunction},
+	"Psi": {"Ψ", symFunction}, "Omega": {"Ω", symFunction},
+
+	// Letter-like symbols
+	"infty": {"∞", symIdent}, "partial": {"∂", symIdent}, "nabla": {"∇", symIdent},
+	"emptyset": {"∅", symIdent}, "varnothing": {"∅", symIdent}, "hbar": {"ℏ", symIdent},
+	"ell": {"ℓ", symIdent}, "Re": {"ℜ", symIdent}, "Im": {"ℑ", symIdent},
+	"aleph": {"ℵ", symIdent}, "forall": {"∀", symOperator}, "exists": {"∃", symOperator},
+	"neg": {"¬", symOperator}, "lnot": {"¬", symOperator}, "top": {"⊤", symIdent},
+	"bot": {"⊥", symIdent}, "angle": {"∠", symIdent}, "triangle": {"△", symIdent},
+
+	// Binary operators
+	"pm": {"±", symOperator}, "mp": {"∓", symOperator}, "times": {"×", symOperator},
+	"div": {"÷", symOperator}, "cdot": {"⋅", symOperator}, "ast": {"∗", symOperator},
+	"star": {"⋆", symOperator}, "circ": {"∘", symOperator}, "bullet": {"∙", symOperator},
+	"oplus": {"⊕", symOperator}, "ominus": {"⊖", symOperator}, "otimes": {"⊗", symOperator},
+	"cup": {"∪", symOperator}, "cap": {"∩", symOperator}, "setminus": {"∖", symOperator},
+	"land": {"∧", symOperator}, "wedge": {"∧", symOperator}, "lor": {"∨", symOperator},
+	"vee": {"∨", symOperator},
+
+	// Relations
+	"leq": {"≤", symOperator}, "le": {"≤", symOperator}, "geq": {"≥", symOperator},
+	"ge": {"≥", symOperator}, "neq": {"≠", symOperator}, "ne": {"≠", symOperator},
+	"approx": {"≈", symOperator}, "equiv": {"≡", symOperator}, "sim": {"∼", symOperator},
+	"simeq": {"≃", symOperator}, "cong": {"≅", symOperator}, "propto": {"∝", symOperator},
+	"ll": {"≪", symOperator}, "gg": {"≫", symOperator}, "in": {"∈", symOperator},
+	"notin": {"∉", symOperator}, "ni": {"∋", symOperator}, "subset": {"⊂", symOperator},
+	"subseteq": {"⊆", symOperator}, "supset": {"⊃", symOperator}, "supseteq": {"⊇", symOperator},
+	"mid": {"∣", symOperator}, "parallel": {"∥", symOperator}, "perp": {"⊥", symOperator},
+	"prec": {"≺", symOperator}, "succ": {"≻", symOperator}, "colon": {":", symOperator},
+
+	// Arrows
+	"to": {"→", symOperator}, "rightarrow": {"→", symOperator}, "leftarrow": {"←", symOperator},
+	"gets": {"←", symOperator}, "leftrightarrow": {"↔", symOperator}, "Rightarrow": {"⇒", symOperator},
+	"Leftarrow": {"⇐", symOperator}, "Leftrightarrow": {"⇔", symOperator}, "implies": {"⟹", symOperator},
+	"impliedby": {"⟸", symOperator}, "iff": {"⟺", symOperator}, "mapsto": {"↦", symOperator},
+	"uparrow": {"↑", symOperator}, "downarrow": {"↓", symOperator}, "longrightarrow": {"⟶", symOperator},
+	"longleftarrow": {"⟵", symOperator},
+
+	// Dots and punctuation
+	"ldots": {"…", symOperator}, "dots": {"…", symOperator}, "cdots": {"⋯", symOperator},
+	"vdots": {"⋮", symOperator}, "ddots": {"⋱", symOperator}, "prime": {"′", symOperator},
+	"{": {"{", symOperator}, "}": {"}", symOperator}, "|": {"‖", symOperator},
+	"_": {"_", symIdent}, "%": {"%", symIdent}, "$": {"$", symIdent},
+	"&": {"&", symOperator}, "#": {"#", symIdent},
+
+	// Delimiters
+	"langle": {"⟨", symOperator}, "rangle": {"⟩", symOperator}, "lfloor": {"⌊", symOperator},
+	"rfloor": {"⌋", symOperator}, "lceil": {"⌈", symOperator}, "rceil": {"⌉", symOperator},
+	"vert": {"|", symOperator}, "Vert": {"‖", symOperator}, "lbrace": {"{", symOperator},
+	"rbrace": {"}", symOperator},
+
+	// Large operators
+	"sum": {"∑", symLargeOp}, "prod": {"∏", symLargeOp}, "coprod": {"∐", symLargeOp},
+	"bigcup": {"⋃", symLargeOp}, "bigcap": {"⋂", symLargeOp}, "bigoplus": {"⨁", symLargeOp},
+	"bigotimes": {"⨂", symLargeOp}, "bigvee": {"⋁", symLargeOp}, "bigwedge": {"⋀", symLargeOp},
+	"int": {"∫", symIntegral}, "iint": {"∬", symIntegral}, "iiint": {"∭", symIntegral},
+	"oint": {"∮", symIntegral},
+
+	// Named functions
+	"sin": {"sin", symFunction}, "cos": {"cos", symFunction}, "tan": {"tan", symFunction},
+	"sec": {"sec", symFunction}, "csc": {"csc", symFunction}, "cot": {"cot", symFunction},
+	"arcsin": {"arcsin", symFunction}, "arccos": {"arccos", symFunction}, "arctan": {"arctan", symFunction},
+	"sinh": {"sinh", symFunction}, "cosh": {"cosh", symFunction}, "tanh": {"tanh", symFunction},
+	"log": {"log", symFunction}, "ln": {"ln", symFunction}, "lg": {"lg", symFunction},
+	"exp": {"exp", symFunction}, "deg": {"deg", symFunction}, "dim": {"dim", symFunction},
+	"ker": {"ker", symFunction}, "arg": {"arg", symFunction}, "hom": {"hom", symFunction},
+	"lim": {"lim", symLimitFn}, "liminf": {"lim inf", symLimitFn}, "limsup": {"lim sup", symLimitFn},
+	"max": {"max", symLimitFn}, "min": {"min", symLimitFn}, "sup": {"sup", symLimitFn},
+	"inf": {"inf", symLimitFn}, "det": {"det", symLimitFn}, "gcd": {"gcd", symLimitFn},
+	"Pr": {"Pr", symLimitFn}, "argmax": {"arg max", symLimitFn}, "argmin": {"arg min", symLimitFn},
+}
+
+// spaces are TeX spacing commands and their widths.
+var spaces = map[string]string{
+	",": "0.1667em", "thinspace": "0.1667em",
+	":": "0.2222em", "medspace": "0.2222em", ">": "0.2222em",
+	";": "0.2778em", "thickspace": "0.2778em",
+	" ": "0.25em", "quad": "1em", "qquad": "2em",
+	"!": "-0.1667em", "negthinspace": "-0.1667em",
+}
+
+// accents are commands placing a mark over their argument.
+var accents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "‾", "vec": "→",
+	"dot": "˙", "ddot": "¨", "tilde": "~", "widetilde": "~", "check": "ˇ",
+	"breve": "˘", "acute": "´", "grave": "`", "overrightarrow": "→",
+}
+
+// fences are the delimiters accepted after \left and \right.
+var fences = map[string]string{
+	"(": "(", ")": ")", "[": "[", "]": "]", "|": "|", "/": "/",
+	`\{`: "{", `\}`: "}", `\|`: "‖", `\langle`: "⟨", `\rangle`: "⟩",
+	`\lfloor`: "⌊", `\rfloor`: "⌋", `\lceil`: "⌈", `\rceil`: "⌉",
+	`\vert`: "|", `\Vert`: "‖", `\lbrace`: "{", `\rbrace`: "}", ".": "",
+}
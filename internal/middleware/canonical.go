@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/alextreichler/personal-website/internal/siteurl"
+)
+
+// CanonicalHostMiddleware redirects requests that arrive on a non-canonical
+// scheme or host to the configured base URL. Health checks and metrics are
+// left alone, since probes and scrapers address the pod directly.
+func CanonicalHostMiddleware(urls *siteurl.Builder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" || r.URL.Path == "/metrics" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if target, ok := urls.Redirect(r); ok {
+				status := http.StatusMovedPermanently
+				if r.Method != http.MethodGet && r.Method != http.MethodHead {
+					// Keep the method and body on form submissions
+					status = http.StatusPermanentRedirect
+				}
+				http.Redirect(w, r, target, status)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
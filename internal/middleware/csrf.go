@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 )
 
@@ -42,12 +46,16 @@ func CSRFMiddleware(isProd bool) func(http.Handler) http.Handler {
 
 			// 2. If State Changing, Verify Token
 			if r.Method == "POST" || r.Method == "PUT" || r.Method == "DELETE" || r.Method == "PATCH" {
-				// ParseMultipartForm might be necessary for upload forms,
-				// but FormValue usually handles it if the content-type is set correctly.
-				// We'll try to retrieve it from FormValue or Header.
-				sentToken := r.FormValue("csrf_token")
+				// Check the header before falling back to the form. Upload forms
+				// are not parsed here, before the handler can apply its size
+				// limit; only their first field, the token, is read.
+				sentToken := r.Header.Get("X-CSRF-Token")
 				if sentToken == "" {
-					sentToken = r.Header.Get("X-CSRF-Token")
+					if isMultipart(r) {
+						sentToken = multipartToken(r)
+					} else {
+						sentToken = r.PostFormValue("csrf_token")
+					}
 				}
 
 				if sentToken == "" || sentToken != token {
@@ -63,6 +71,44 @@ func CSRFMiddleware(isProd bool) func(http.Handler) http.Handler {
 	}
 }
 
+// maxTokenPart bounds how much of a multipart body is read looking for the
+// token.
+const maxTokenPart = 8 << 10
+
+func isMultipart(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "multipart/form-data"
+}
+
+// multipartToken returns the csrf_token field of a multipart form, which
+// must be its first field. The bytes read are put back in front of the
+// body, so the handler still sees the whole request.
+func multipartToken(r *http.Request) string {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return ""
+	}
+	body := r.Body
+	var read bytes.Buffer
+	defer func() {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&read, body), body}
+	}()
+
+	mr := multipart.NewReader(io.TeeReader(io.LimitReader(body, maxTokenPart), &read), params["boundary"])
+	part, err := mr.NextPart()
+	if err != nil || part.FormName() != "csrf_token" {
+		return ""
+	}
+	token, err := io.ReadAll(part)
+	if err != nil {
+		return ""
+	}
+	return string(token)
+}
+
 func GetCSRFToken(r *http.Request) string {
 	if val, ok := r.Context().Value(csrfTokenKey).(string); ok {
 		return val
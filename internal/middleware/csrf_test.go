@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFTokenSources(t *testing.T) {
+	var body string
+	h := CSRFMiddleware(false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	post := func(target, contentType, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(payload))
+		req.Header.Set("Content-Type", contentType)
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "secret"})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	form := url.Values{"csrf_token": {"secret"}}.Encode()
+	if w := post("/", "application/x-www-form-urlencoded", form); w.Code != http.StatusOK {
+		t.Errorf("token in form: %d", w.Code)
+	}
+	if w := post("/?csrf_token=secret", "application/x-www-form-urlencoded", ""); w.Code != http.StatusForbidden {
+		t.Errorf("token in query string: %d, want 403", w.Code)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("csrf_token", "secret")
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("file content"))
+	mw.Close()
+	upload := buf.String()
+	if w := post("/", mw.FormDataContentType(), upload); w.Code != http.StatusOK {
+		t.Errorf("token first in multipart form: %d", w.Code)
+	}
+	if body != upload {
+		t.Error("multipart body was not passed on whole")
+	}
+
+	buf.Reset()
+	mw = multipart.NewWriter(&buf)
+	mw.WriteField("title", "x")
+	mw.WriteField("csrf_token", "secret")
+	mw.Close()
+	if w := post("/", mw.FormDataContentType(), buf.String()); w.Code != http.StatusForbidden {
+		t.Errorf("token after another multipart field: %d, want 403", w.Code)
+	}
+}
@@ -10,22 +10,43 @@ import (
 
 type bufferedResponseWriter struct {
 	http.ResponseWriter
-	buf        bytes.Buffer
-	statusCode int
+	buf         bytes.Buffer
+	statusCode  int
+	decided     bool
+	passthrough bool
+}
+
+// decide switches to streaming when the handler set its own ETag before
+// writing, e.g. media served with http.ServeContent and Range support.
+func (w *bufferedResponseWriter) decide() {
+	if !w.decided {
+		w.decided = true
+		w.passthrough = w.Header().Get("ETag") != ""
+	}
 }
 
 func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	w.decide()
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
 	return w.buf.Write(b)
 }
 
 func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	w.decide()
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
 	w.statusCode = statusCode
 }
 
 // ETagMiddleware calculates the MD5 hash of the response body and sets the ETag header.
 // If the client sends a matching If-None-Match header, it returns 304 Not Modified.
 // Note: This middleware buffers the entire response in memory, so it's best for
-// small to medium-sized responses (like HTML pages), not large file downloads.
+// small to medium-sized responses (like HTML pages). Handlers serving large files
+// opt out by setting their own ETag header before writing.
 func ETagMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip for non-GET/HEAD methods
@@ -40,6 +61,9 @@ func ETagMiddleware(next http.Handler) http.Handler {
 		}
 
 		next.ServeHTTP(bw, r)
+		if bw.passthrough {
+			return
+		}
 
 		// Calculate ETag
 		hash := md5.Sum(bw.buf.Bytes())
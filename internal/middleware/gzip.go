@@ -42,6 +42,12 @@ func GzipMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Don't compress partial content; byte ranges refer to the uncompressed file
+		if r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Don't compress WebSocket upgrades
 		if r.Header.Get("Sec-WebSocket-Key") != "" {
 			next.ServeHTTP(w, r)
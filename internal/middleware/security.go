@@ -16,8 +16,9 @@ func SecurityHeadersMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("X-XSS-Protection", "1; mode=block")
 
 		// Content Security Policy (CSP)
-		// Allows necessary CDNs for Fonts, Icons (FontAwesome), and Editor (EasyMDE)
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com https://cdnjs.cloudflare.com https://cdn.jsdelivr.net; img-src 'self' data:; font-src 'self' https://fonts.gstatic.com https://cdnjs.cloudflare.com; connect-src 'self';")
+		// Allows necessary CDNs for Fonts, Icons (FontAwesome), and Editor (EasyMDE),
+		// and privacy-enhanced YouTube players from the youtube shortcode
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com https://cdnjs.cloudflare.com https://cdn.jsdelivr.net; img-src 'self' data:; font-src 'self' https://fonts.gstatic.com https://cdnjs.cloudflare.com; connect-src 'self'; frame-src https://www.youtube-nocookie.com;")
 
 		// Strict Transport Security (HSTS)
 		// Tells browsers to cache the fact that this site should only be accessed via HTTPS for the next 2 years
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ArchiveMonth is the number of posts published in one calendar month.
+type ArchiveMonth struct {
+	Year  int
+	Month int
+	Count int
+}
+
+// Name returns the month as shown to readers, e.g. "May 2024".
+func (m ArchiveMonth) Name() string {
+	return fmt.Sprintf("%s %d", time.Month(m.Month), m.Year)
+}
+
+// URL returns the path of the month's archive page.
+func (m ArchiveMonth) URL() string {
+	return fmt.Sprintf("/%04d/%02d/", m.Year, m.Month)
+}
+
+// ArchiveYear groups the months of one year.
+type ArchiveYear struct {
+	Year   int
+	Count  int
+	Months []ArchiveMonth
+}
+
+// URL returns the path of the year's archive page.
+func (y ArchiveYear) URL() string {
+	return fmt.Sprintf("/%04d/", y.Year)
+}
+
+// GroupArchive groups months, sorted newest first, into years.
+func GroupArchive(months []ArchiveMonth) []ArchiveYear {
+	var years []ArchiveYear
+	for _, m := range months {
+		if len(years) == 0 || years[len(years)-1].Year != m.Year {
+			years = append(years, ArchiveYear{Year: m.Year})
+		}
+		y := &years[len(years)-1]
+		y.Count += m.Count
+		y.Months = append(y.Months, m)
+	}
+	return years
+}
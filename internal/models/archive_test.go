@@ -0,0 +1,20 @@
+package models
+
+import "testing"
+
+func TestGroupArchive(t *testing.T) {
+	years := GroupArchive([]ArchiveMonth{
+		{Year: 2024, Month: 5, Count: 2},
+		{Year: 2024, Month: 1, Count: 1},
+		{Year: 2023, Month: 12, Count: 4},
+	})
+	if len(years) != 2 || years[0].Count != 3 || len(years[0].Months) != 2 || years[1].Count != 4 {
+		t.Fatalf("unexpected grouping: %+v", years)
+	}
+	if got := years[0].Months[0].URL(); got != "/2024/05/" {
+		t.Errorf("URL = %q", got)
+	}
+	if got := years[0].Months[0].Name(); got != "May 2024" {
+		t.Errorf("Name = %q", got)
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Autosave is the latest unsaved editor content of a post, kept so it can
+// be recovered after a crash or a conflicting save. A post that hasn't been
+// saved yet has no PostID; its autosave belongs to the author instead.
+type Autosave struct {
+	PostID  int
+	Title   string
+	Content string
+	SavedAt time.Time
+}
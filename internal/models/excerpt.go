@@ -0,0 +1,157 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Lengths, in runes, of derived summaries.
+const (
+	ExcerptLength         = 240
+	MetaDescriptionLength = 160
+)
+
+// Summary returns the post's excerpt, deriving one from the rendered
+// content when none was written.
+func (p *Post) Summary() string {
+	if s := strings.TrimSpace(p.Excerpt); s != "" {
+		return s
+	}
+	return p.derived(ExcerptLength)
+}
+
+// Description returns the text used for meta tags: the custom meta
+// description, then the excerpt, then a summary derived from the content.
+func (p *Post) Description() string {
+	if s := strings.TrimSpace(p.MetaDescription); s != "" {
+		return s
+	}
+	if s := strings.TrimSpace(p.Excerpt); s != "" {
+		return Truncate(s, MetaDescriptionLength)
+	}
+	return p.derived(MetaDescriptionLength)
+}
+
+func (p *Post) derived(max int) string {
+	text := PlainText(p.HTMLContent)
+	if text == "" {
+		text = strings.Join(strings.Fields(p.Content), " ")
+	}
+	return Truncate(text, max)
+}
+
+// PlainText extracts the readable text of an HTML fragment with whitespace
+// collapsed. Headings, code blocks, scripts and styles are dropped.
+func PlainText(fragment string) string {
+	return extractText(fragment, summarySkipped, false)
+}
+
+// ReadableText extracts all the text a reader sees in rendered content,
+// headings and code included, with whitespace collapsed. Scripts, styles,
+// the TeX source kept with formulas and the heading and footnote links the
+// renderer adds are dropped.
+func ReadableText(fragment string) string {
+	return extractText(fragment, readableSkipped, true)
+}
+
+var (
+	summarySkipped  = map[string]bool{"pre": true, "script": true, "style": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true}
+	readableSkipped = map[string]bool{"script": true, "style": true, "annotation": true}
+	addedLinks      = map[string]bool{"heading-anchor": true, "footnote-backref": true}
+)
+
+// blockElements separate the text around them. Inline elements such as a,
+// em or code don't, so "un<em>believ</em>able" stays one word.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true, "dd": true,
+	"details": true, "div": true, "dl": true, "dt": true, "figcaption": true, "figure": true,
+	"footer": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "math": true, "ol": true, "p": true, "pre": true,
+	"section": true, "summary": true, "svg": true, "table": true, "td": true, "text": true,
+	"th": true, "tr": true, "ul": true,
+}
+
+// extractText collects the text outside the skipped elements, and outside
+// the links the renderer adds if skipLinks is set.
+func extractText(fragment string, skipped map[string]bool, skipLinks bool) string {
+	var b strings.Builder
+	skip := 0
+	inLink := false
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.StartTagToken:
+			tok := z.Token()
+			if skipped[tok.Data] {
+				skip++
+			}
+			if skipLinks && tok.Data == "a" {
+				for _, a := range tok.Attr {
+					if a.Key == "class" && addedLinks[a.Val] {
+						inLink = true
+					}
+				}
+			}
+			if blockElements[tok.Data] {
+				b.WriteByte(' ')
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if skipped[string(name)] && skip > 0 {
+				skip--
+			}
+			if string(name) == "a" {
+				inLink = false
+			}
+			if blockElements[string(name)] {
+				b.WriteByte(' ')
+			}
+		case html.SelfClosingTagToken:
+			if name, _ := z.TagName(); blockElements[string(name)] {
+				b.WriteByte(' ')
+			}
+		case html.TextToken:
+			if skip == 0 && !inLink {
+				b.Write(z.Text())
+			}
+		}
+	}
+}
+
+// Truncate shortens text to at most max runes. It prefers to cut at the end
+// of a sentence, falls back to a word boundary, and marks a cut with "…".
+func Truncate(text string, max int) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+
+	// Byte offset of the rune that no longer fits, leaving room for the ellipsis
+	limit, n := len(text), 0
+	for i := range text {
+		if n == max-1 {
+			limit = i
+			break
+		}
+		n++
+	}
+	cut := text[:limit]
+
+	// End of the last complete sentence, if it keeps at least half the text
+	for i := len(cut) - 1; i >= len(cut)/2; i-- {
+		if strings.ContainsRune(".!?", rune(cut[i])) && text[i+1] == ' ' {
+			return cut[:i+1]
+		}
+	}
+
+	// Otherwise the last word boundary
+	if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-–—") + "…"
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPlainText(t *testing.T) {
+	in := `<h1>Title</h1><p>Some <strong>bold</strong>&amp;text.</p><pre><code>skip()</code></pre><p>End</p>`
+	if got, want := PlainText(in), "Some bold&text. End"; got != want {
+		t.Errorf("PlainText = %q, want %q", got, want)
+	}
+}
+
+func TestPlainTextKeepsInlineMarkupJoined(t *testing.T) {
+	in := `<p>I love <a href="/go">Go</a>. It is un<em>believ</em>able, <strong>really</strong>!</p><ul><li>one</li><li>two<br>three</li></ul><p>x<sup>2</sup></p>`
+	want := "I love Go. It is unbelievable, really! one two three x2"
+	if got := PlainText(in); got != want {
+		t.Errorf("PlainText = %q, want %q", got, want)
+	}
+	if got := len(strings.Fields(ReadableText(in))); got != 11 {
+		t.Errorf("ReadableText has %d words, want 11", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name, in string
+		max      int
+		want     string
+	}{
+		{"short", "Fits fine.", 50, "Fits fine."},
+		{"sentence", "First sentence here. Second sentence is much longer than the limit.", 40, "First sentence here."},
+		{"word", "one two three four five six seven", 20, "one two three four…"},
+		{"runes", strings.Repeat("ü", 30), 10, strings.Repeat("ü", 9) + "…"},
+		{"no early sentence", "A. then a long run of words without any stop", 30, "A. then a long run of words…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.in, tt.max)
+			if got != tt.want {
+				t.Errorf("Truncate = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) || utf8.RuneCountInString(got) > tt.max {
+				t.Errorf("result %q is invalid or longer than %d runes", got, tt.max)
+			}
+		})
+	}
+}
+
+func TestDescriptionFallbacks(t *testing.T) {
+	p := &Post{Content: "**raw** markdown", HTMLContent: "<p><strong>raw</strong> markdown</p>"}
+	if got := p.Description(); got != "raw markdown" {
+		t.Errorf("derived Description = %q", got)
+	}
+	p.Excerpt = "Hand written."
+	if p.Summary() != "Hand written." || p.Description() != "Hand written." {
+		t.Errorf("excerpt not used: %q / %q", p.Summary(), p.Description())
+	}
+	p.MetaDescription = "For search engines."
+	if p.Description() != "For search engines." || p.Summary() != "Hand written." {
+		t.Errorf("meta description not used: %q / %q", p.Summary(), p.Description())
+	}
+}
+
+func TestWordCount(t *testing.T) {
+	post := &Post{
+		Content: "## Setup [#](/x)\n\nRead [the docs](https://example.com/a/very/long/url) ![alt](/media/a.jpg)\n\n```\ngo build\n```\n",
+		HTMLContent: `<h2 id="setup">Setup <a class="heading-anchor" href="#setup">#</a></h2>` +
+			`<p>Read <a href="https://example.com/a/very/long/url">the docs</a> <img src="/media/a.jpg" alt="alt"></p>` +
+			`<pre><code>go build</code></pre>` +
+			`<math><semantics><mi>x</mi><annotation encoding="application/x-tex">x</annotation></semantics></math>`,
+	}
+	// Setup, Read, the, docs, go, build, x
+	if got := post.WordCount(); got != 7 {
+		t.Errorf("WordCount = %d, want 7", got)
+	}
+	if got := (&Post{Content: "two words"}).WordCount(); got != 2 {
+		t.Errorf("WordCount of an unrendered post = %d, want 2", got)
+	}
+}
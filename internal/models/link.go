@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// LinkCheck is the last result of checking an external URL.
+type LinkCheck struct {
+	URL       string
+	Status    int    // HTTP status, 0 if the request failed
+	Error     string // Network error, if any
+	CheckedAt time.Time
+}
+
+// PostLink is a link found in a post, with its last check if any.
+type PostLink struct {
+	PostID    int
+	PostTitle string
+	PostSlug  string
+	URL       string
+	Check     *LinkCheck // nil until the URL has been checked
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Media is an uploaded file tracked in the media library.
+type Media struct {
+	ID           int
+	StorageKey   string
+	PreviewKey   string // Optimized image variant or generated poster, if any
+	OriginalName string
+	ContentType  string
+	Size         int64 // Bytes of the original upload
+	VariantsSize int64 // Bytes of generated variants (resized copies, posters)
+	Width        int
+	Height       int
+	UploadedBy   string
+	CreatedAt    time.Time
+}
+
+func (m *Media) IsImage() bool {
+	return strings.HasPrefix(m.ContentType, "image/")
+}
+
+func (m *Media) IsAudio() bool {
+	return strings.HasPrefix(m.ContentType, "audio/")
+}
+
+func (m *Media) IsVideo() bool {
+	return strings.HasPrefix(m.ContentType, "video/")
+}
+
+func (m *Media) IsPDF() bool {
+	return m.ContentType == "application/pdf"
+}
+
+// IsAttachment reports whether the file gets a download page rather than
+// being embedded as an image.
+func (m *Media) IsAttachment() bool {
+	return !m.IsImage()
+}
+
+// DisplayName is the uploaded file name, falling back to the storage key.
+func (m *Media) DisplayName() string {
+	if m.OriginalName != "" {
+		return m.OriginalName
+	}
+	return path.Base(m.StorageKey)
+}
+
+// Kind is a short label for the admin UI.
+func (m *Media) Kind() string {
+	switch {
+	case m.IsImage():
+		return "Image"
+	case m.IsAudio():
+		return "Audio"
+	case m.IsVideo():
+		return "Video"
+	case m.IsPDF():
+		return "PDF"
+	}
+	return "File"
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Page is a standalone page such as About, Now or Uses, served at /{slug}.
+type Page struct {
+	ID              int
+	Title           string
+	Slug            string
+	Content         string // Markdown
+	HTMLContent     string
+	MetaDescription string
+	Status          string // "draft" or "published"
+	ShowInNav       bool
+	NavOrder        int
+	RenderVersion   int // Version of the render pipeline that produced HTMLContent
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// URL returns the public path of the page.
+func (p *Page) URL() string {
+	return "/" + p.Slug
+}
+
+// Description returns the text used for meta tags: the custom meta
+// description or a summary derived from the content.
+func (p *Page) Description() string {
+	if p.MetaDescription != "" {
+		return p.MetaDescription
+	}
+	return Truncate(PlainText(p.HTMLContent), MetaDescriptionLength)
+}
@@ -26,6 +26,30 @@ type Post struct {
 	Views       int
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// Optional audio attachment, published as a podcast enclosure
+	AudioMediaID int
+	Audio        *Media
+
+	// Optional cover image from the media library, used for social cards
+	CoverMediaID int
+	Cover        *Media
+
+	// Optional hand-written summaries; see Summary and Description
+	Excerpt         string
+	MetaDescription string
+
+	// Optional series membership; SeriesPosition orders the parts
+	SeriesID       int
+	SeriesPosition int
+	Series         *Series
+
+	// Version of the render pipeline that produced HTMLContent
+	RenderVersion int
+
+	// Version counts saves, so an editor opened on an older version can
+	// detect that someone else saved in between
+	Version int
 }
 
 
@@ -33,9 +57,18 @@ func (p *Post) ShowUpdated() bool {
 	return p.UpdatedAt.Sub(p.CreatedAt) > 5*time.Minute
 }
 
+// WordCount counts the words readers see in the rendered post, so markup,
+// link targets and image sources don't count. Posts not rendered yet fall
+// back to their markdown source.
+func (p *Post) WordCount() int {
+	if p.HTMLContent == "" {
+		return len(strings.Fields(p.Content))
+	}
+	return len(strings.Fields(ReadableText(p.HTMLContent)))
+}
+
 func (p *Post) ReadingTime() string {
-	wordCount := len(strings.Fields(p.Content))
-	minutes := float64(wordCount) / 200.0
+	minutes := float64(p.WordCount()) / 200.0
 	if minutes < 1 {
 		return "1 min read"
 	}
@@ -0,0 +1,23 @@
+package models
+
+// RelatedPost is one precomputed entry in a post's related posts list.
+type RelatedPost struct {
+	RelatedID int
+	Score     float64
+	Pinned    bool
+}
+
+// Related override actions.
+const (
+	RelatedPin     = "pin"
+	RelatedExclude = "exclude"
+)
+
+// RelatedOverride is an admin's choice to always show (pin) or never show
+// (exclude) a post in another post's related list.
+type RelatedOverride struct {
+	PostID    int
+	RelatedID int
+	Action    string
+	Title     string // Title of the related post, for display
+}
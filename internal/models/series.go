@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Series is an ordered collection of posts, such as a multi-part tutorial.
+type Series struct {
+	ID          int
+	Title       string
+	Slug        string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	PostCount int     // Published members, when loaded for listings
+	Posts     []*Post // Published members in reading order, when loaded
+}
+
+// URL returns the path of the series landing page.
+func (s *Series) URL() string {
+	return "/series/" + s.Slug
+}
+
+// Part returns the 1-based position of the post in the series' loaded
+// posts, or 0 when it is not among them.
+func (s *Series) Part(postID int) int {
+	for i, p := range s.Posts {
+		if p.ID == postID {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Tag summarises a tag across the posts that use it.
+type Tag struct {
+	Name         string
+	PostCount    int
+	LastModified time.Time
+
+	// Optional presentation for the public tag page
+	DisplayName string
+	Description string
+
+	// Drafts using the tag, in admin listings
+	DraftCount int
+}
+
+// Title is the name shown on the tag page.
+func (t *Tag) Title() string {
+	if t.DisplayName != "" {
+		return t.DisplayName
+	}
+	return "#" + t.Name
+}
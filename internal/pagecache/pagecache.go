@@ -0,0 +1,317 @@
+// Package pagecache keeps rendered public responses in memory, so repeat
+// visits skip the database and templates until the content they show
+// changes.
+//
+// Caching is opt-in: a handler calls Depends with the topics its response
+// is built from, and Invalidate drops every response depending on a topic.
+package pagecache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "page_cache_hits_total",
+		Help: "Responses served from the page cache",
+	})
+	cacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "page_cache_misses_total",
+		Help: "Cacheable requests that had to be rendered",
+	})
+	cacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "page_cache_evictions_total",
+		Help: "Responses dropped from the page cache to stay under its memory cap",
+	})
+	cacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "page_cache_bytes",
+		Help: "Approximate memory held by cached responses",
+	})
+)
+
+// Cache is an LRU cache of whole responses, bounded by their total size.
+type Cache struct {
+	maxBytes int64
+	params   []string
+
+	// Bypass, if set, reports requests that must neither be served from
+	// nor stored in the cache, e.g. those of logged in users.
+	Bypass func(*http.Request) bool
+	// Origin, if set, returns the scheme and host a response was rendered
+	// for, when pages contain absolute URLs. The default is the Host header.
+	Origin func(*http.Request) string
+
+	mu      sync.Mutex
+	lru     *list.List // Of *entry, most recently used first
+	entries map[string]*list.Element
+	size    int64
+	gen     uint64 // Incremented by every Invalidate
+}
+
+type entry struct {
+	key    string
+	header http.Header
+	body   []byte
+	topics []string
+	onHit  []func()
+	size   int64
+}
+
+// New returns a cache holding up to maxBytes of responses; 0 disables it.
+// params are the query parameters that select different content, such as
+// a page number. Others are left out of cache keys, so tracking parameters
+// don't multiply entries.
+func New(maxBytes int64, params ...string) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		params:   params,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+type contextKey struct{}
+
+// pending collects what a handler says about the response it is rendering.
+type pending struct {
+	topics []string
+	onHit  []func()
+}
+
+// Depends marks the response to r as cacheable until one of topics is
+// invalidated. It does nothing if r is not going through a cache.
+func Depends(r *http.Request, topics ...string) {
+	if p, ok := r.Context().Value(contextKey{}).(*pending); ok {
+		p.topics = append(p.topics, topics...)
+	}
+}
+
+// OnHit registers fn to run whenever the response to r is served from the
+// cache, for work the handler does on every request such as counting views.
+func OnHit(r *http.Request, fn func()) {
+	if p, ok := r.Context().Value(contextKey{}).(*pending); ok {
+		p.onHit = append(p.onHit, fn)
+	}
+}
+
+// Invalidate drops every response that depends on topic, including those
+// being rendered right now.
+func (c *Cache) Invalidate(topic string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if slices.Contains(el.Value.(*entry).topics, topic) {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// Len returns the number of cached responses.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Middleware serves GET and HEAD requests from the cache, and caches
+// successful responses whose handler called Depends.
+func (c *Cache) Middleware(next http.Handler) http.Handler {
+	if c.maxBytes <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || (c.Bypass != nil && c.Bypass(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := c.key(r)
+		if e, ok := c.get(key); ok {
+			cacheHits.Inc()
+			for _, fn := range e.onHit {
+				fn()
+			}
+			serve(w, r, e)
+			return
+		}
+
+		c.mu.Lock()
+		gen := c.gen
+		c.mu.Unlock()
+
+		p := &pending{}
+		rec := &recorder{ResponseWriter: w, header: make(http.Header), status: http.StatusOK, limit: c.maxBytes / 4}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), contextKey{}, p)))
+		if !rec.wroteHeader {
+			rec.WriteHeader(rec.status)
+		}
+		if len(p.topics) == 0 {
+			return
+		}
+		cacheMisses.Inc()
+
+		if r.Method != http.MethodGet || !rec.cacheable() {
+			return
+		}
+		e := &entry{
+			key:    key,
+			header: rec.header,
+			body:   rec.body.Bytes(),
+			topics: p.topics,
+			onHit:  p.onHit,
+		}
+		e.size = int64(len(key) + len(e.body))
+		for k, vs := range e.header {
+			for _, v := range vs {
+				e.size += int64(len(k) + len(v))
+			}
+		}
+		c.put(e, gen)
+	})
+}
+
+// key identifies a response by origin, path and the query parameters that
+// matter.
+func (c *Cache) key(r *http.Request) string {
+	origin := r.Host
+	if c.Origin != nil {
+		origin = c.Origin(r)
+	}
+	q := r.URL.Query()
+	kept := url.Values{}
+	for _, p := range c.params {
+		if v, ok := q[p]; ok {
+			kept[p] = v
+		}
+	}
+	if len(kept) == 0 {
+		return origin + r.URL.Path
+	}
+	return origin + r.URL.Path + "?" + kept.Encode()
+}
+
+func (c *Cache) get(key string) (*entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*entry), true
+}
+
+// put stores e unless something was invalidated since generation gen, when
+// e may already be out of date.
+func (c *Cache) put(e *entry, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gen != gen {
+		return
+	}
+	if el, ok := c.entries[e.key]; ok {
+		c.remove(el)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += e.size
+	for c.size > c.maxBytes && c.lru.Len() > 1 {
+		c.remove(c.lru.Back())
+		cacheEvictions.Inc()
+	}
+	cacheBytes.Set(float64(c.size))
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	cacheBytes.Set(float64(c.size))
+}
+
+// serve writes a cached response, or 304 Not Modified if the client already
+// has it.
+func serve(w http.ResponseWriter, r *http.Request, e *entry) {
+	h := w.Header()
+	for k, vs := range e.header {
+		h[k] = slices.Clone(vs)
+	}
+	if etag := e.header.Get("ETag"); etag != "" && strings.Contains(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(e.body)
+}
+
+// recorder passes a response through while keeping a copy of it, up to
+// limit bytes of body.
+type recorder struct {
+	http.ResponseWriter
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int64
+	overflow    bool
+}
+
+func (w *recorder) Header() http.Header {
+	return w.header
+}
+
+func (w *recorder) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = statusCode
+	h := w.ResponseWriter.Header()
+	for k, vs := range w.header {
+		h[k] = slices.Clone(vs)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflow {
+		if int64(w.body.Len()+len(b)) > w.limit {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// cacheable reports whether the recorded response can be shared between
+// visitors.
+func (w *recorder) cacheable() bool {
+	if w.status != http.StatusOK || w.overflow {
+		return false
+	}
+	if len(w.header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	cc := w.header.Get("Cache-Control")
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
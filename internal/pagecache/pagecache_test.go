@@ -0,0 +1,146 @@
+package pagecache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// counter is a handler that counts its calls and echoes the request URI.
+type counter struct {
+	calls  int
+	topics []string
+	status int
+}
+
+func (h *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	Depends(r, h.topics...)
+	w.Header().Set("ETag", `"v1"`)
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+	}
+	fmt.Fprintf(w, "page %s", r.URL.RequestURI())
+}
+
+func get(h http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCacheHitsAndInvalidation(t *testing.T) {
+	c := New(1<<20, "page")
+	h := &counter{topics: []string{"posts"}}
+	srv := c.Middleware(h)
+
+	get(srv, "/?page=2&utm_source=feed")
+	w := get(srv, "/?page=2")
+	if h.calls != 1 {
+		t.Fatalf("handler ran %d times, want the second request served from cache", h.calls)
+	}
+	if w.Body.String() != "page /?page=2&utm_source=feed" || w.Header().Get("ETag") != `"v1"` {
+		t.Errorf("cached response = %q %v", w.Body.String(), w.Header())
+	}
+
+	if get(srv, "/?page=3"); h.calls != 2 {
+		t.Error("a different page number was served from cache")
+	}
+
+	if w := get(srv, "/?page=2", "If-None-Match", `"v1"`); w.Code != http.StatusNotModified {
+		t.Errorf("conditional hit returned %d, want 304", w.Code)
+	}
+
+	c.Invalidate("settings")
+	if get(srv, "/?page=2"); h.calls != 2 {
+		t.Error("an unrelated topic invalidated the page")
+	}
+	c.Invalidate("posts")
+	if c.Len() != 0 {
+		t.Errorf("%d entries left after invalidating their topic", c.Len())
+	}
+	if get(srv, "/?page=2"); h.calls != 3 {
+		t.Error("an invalidated page was served from cache")
+	}
+}
+
+func TestCacheSkips(t *testing.T) {
+	c := New(1 << 20)
+	c.Bypass = func(r *http.Request) bool { return r.Header.Get("Cookie") != "" }
+
+	optOut := &counter{}
+	get(c.Middleware(optOut), "/admin")
+	notFound := &counter{topics: []string{"posts"}, status: http.StatusNotFound}
+	get(c.Middleware(notFound), "/post/missing")
+	if c.Len() != 0 {
+		t.Errorf("%d entries cached, want responses without Depends and errors skipped", c.Len())
+	}
+
+	h := &counter{topics: []string{"posts"}}
+	srv := c.Middleware(h)
+	get(srv, "/", "Cookie", "session=1")
+	get(srv, "/", "Cookie", "session=1")
+	if h.calls != 2 || c.Len() != 0 {
+		t.Error("bypassed requests used the cache")
+	}
+}
+
+func TestCacheOnHit(t *testing.T) {
+	c := New(1 << 20)
+	views := 0
+	srv := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		views++
+		Depends(r, "posts")
+		OnHit(r, func() { views++ })
+		w.Write([]byte("post"))
+	}))
+	for i := 0; i < 3; i++ {
+		get(srv, "/post/hello")
+	}
+	if views != 3 {
+		t.Errorf("views = %d, want every request counted", views)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	body := strings.Repeat("x", 200)
+	c := New(1000)
+	srv := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Depends(r, "posts")
+		w.Write([]byte(body))
+	}))
+
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		get(srv, path)
+	}
+	get(srv, "/a") // Now the most recently used
+	get(srv, "/e")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size > 1000 {
+		t.Errorf("cache holds %d bytes, over its cap", c.size)
+	}
+	if _, ok := c.entries["example.com/a"]; !ok {
+		t.Error("recently used /a was evicted")
+	}
+	if _, ok := c.entries["example.com/b"]; ok {
+		t.Error("least recently used /b was kept")
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	h := &counter{topics: []string{"posts"}}
+	srv := New(0).Middleware(h)
+	get(srv, "/")
+	get(srv, "/")
+	if h.calls != 2 {
+		t.Error("a cache with no memory served from cache")
+	}
+}
@@ -0,0 +1,203 @@
+// Package related scores how closely posts are related.
+//
+// Two signals are combined: shared tags, weighted so that rare tags count
+// for more than ones used everywhere, and TF-IDF cosine similarity of the
+// words in each post. Scores are computed for the whole corpus at once so
+// the results can be stored and looked up cheaply when pages render.
+package related
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+// Weights of the two signals in the final score.
+const (
+	tagWeight  = 0.6
+	textWeight = 0.4
+)
+
+// MinScore is the lowest score still worth recommending.
+const MinScore = 0.05
+
+// Compute ranks, for every post, up to limit related posts. Overrides pin
+// posts to the top of a list or exclude them from it.
+func Compute(posts []*models.Post, overrides []models.RelatedOverride, limit int) map[int][]models.RelatedPost {
+	n := len(posts)
+	byID := make(map[int]bool, n)
+	for _, p := range posts {
+		byID[p.ID] = true
+	}
+
+	// Document frequencies of tags and terms
+	tagDF := map[string]int{}
+	termDF := map[string]int{}
+	termFreqs := make([]map[string]float64, n)
+	for i, p := range posts {
+		for _, t := range uniqueTags(p.Tags) {
+			tagDF[t]++
+		}
+		tf := termFrequencies(p.Title + " " + p.Content)
+		for t := range tf {
+			termDF[t]++
+		}
+		termFreqs[i] = tf
+	}
+
+	// TF-IDF vectors, normalised to unit length
+	vectors := make([]map[string]float64, n)
+	for i, tf := range termFreqs {
+		vec := make(map[string]float64, len(tf))
+		var norm float64
+		for t, f := range tf {
+			w := f * math.Log(float64(n)/float64(termDF[t]))
+			if w > 0 {
+				vec[t] = w
+				norm += w * w
+			}
+		}
+		norm = math.Sqrt(norm)
+		for t := range vec {
+			vec[t] /= norm
+		}
+		vectors[i] = vec
+	}
+
+	tagIDF := func(t string) float64 { return math.Log(1 + float64(n)/float64(tagDF[t])) }
+
+	pinned := map[int][]int{}
+	excluded := map[[2]int]bool{}
+	for _, o := range overrides {
+		switch o.Action {
+		case models.RelatedPin:
+			if byID[o.RelatedID] && o.RelatedID != o.PostID {
+				pinned[o.PostID] = append(pinned[o.PostID], o.RelatedID)
+			}
+		case models.RelatedExclude:
+			excluded[[2]int{o.PostID, o.RelatedID}] = true
+		}
+	}
+
+	result := make(map[int][]models.RelatedPost, n)
+	for i, p := range posts {
+		var list []models.RelatedPost
+		seen := map[int]bool{p.ID: true}
+		for _, id := range pinned[p.ID] {
+			if len(list) < limit && !seen[id] {
+				list = append(list, models.RelatedPost{RelatedID: id, Pinned: true})
+				seen[id] = true
+			}
+		}
+
+		var scored []models.RelatedPost
+		tags := uniqueTags(p.Tags)
+		for j, q := range posts {
+			if seen[q.ID] || excluded[[2]int{p.ID, q.ID}] {
+				continue
+			}
+			score := tagWeight*tagSimilarity(tags, uniqueTags(q.Tags), tagIDF) +
+				textWeight*dot(vectors[i], vectors[j])
+			if score >= MinScore {
+				scored = append(scored, models.RelatedPost{RelatedID: q.ID, Score: score})
+			}
+		}
+		sort.SliceStable(scored, func(a, b int) bool { return scored[a].Score > scored[b].Score })
+		for _, s := range scored {
+			if len(list) >= limit {
+				break
+			}
+			list = append(list, s)
+		}
+
+		if len(list) > 0 {
+			result[p.ID] = list
+		}
+	}
+	return result
+}
+
+// tagSimilarity is the IDF-weighted Jaccard similarity of two tag sets.
+func tagSimilarity(a, b []string, idf func(string) float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inB := make(map[string]bool, len(b))
+	for _, t := range b {
+		inB[t] = true
+	}
+	var shared, union float64
+	for _, t := range a {
+		if inB[t] {
+			shared += idf(t)
+			delete(inB, t)
+		}
+		union += idf(t)
+	}
+	for t := range inB {
+		union += idf(t)
+	}
+	return shared / union
+}
+
+func dot(a, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var sum float64
+	for t, w := range a {
+		sum += w * b[t]
+	}
+	return sum
+}
+
+func uniqueTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// termFrequencies splits text into lower-case words, drops stop words and
+// very short tokens, and returns each term's share of the total.
+func termFrequencies(text string) map[string]float64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	counts := map[string]float64{}
+	var total float64
+	for _, w := range words {
+		if len([]rune(w)) < 3 || stopWords[w] {
+			continue
+		}
+		counts[w]++
+		total++
+	}
+	for t := range counts {
+		counts[t] /= total
+	}
+	return counts
+}
+
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		about after again all also and any are because been before being between both but
+		can could did does doing down during each few for from further had has have having
+		her here hers him his how http https into its itself just more most not now off once
+		only other our ours out over own same she should some such than that the their theirs
+		them then there these they this those through too under until very was were what when
+		where which while who whom why will with would www you your yours yourself`) {
+		stopWords[w] = true
+	}
+}
@@ -0,0 +1,69 @@
+package related
+
+import (
+	"testing"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+func corpus() []*models.Post {
+	return []*models.Post{
+		{ID: 1, Title: "Tuning Go garbage collection", Content: "The Go garbage collector pauses and heap tuning with GOGC.", Tags: []string{"go", "performance"}},
+		{ID: 2, Title: "Profiling Go services", Content: "Use pprof to find heap allocations and reduce garbage collector pressure.", Tags: []string{"go", "performance"}},
+		{ID: 3, Title: "Sourdough starter", Content: "Feeding a sourdough starter with flour and water every day.", Tags: []string{"baking"}},
+		{ID: 4, Title: "Go generics", Content: "Type parameters and constraints in generic functions.", Tags: []string{"go"}},
+		{ID: 5, Title: "Baguettes", Content: "Shaping baguettes from a sourdough starter dough.", Tags: []string{"baking"}},
+	}
+}
+
+func ids(list []models.RelatedPost) []int {
+	var out []int
+	for _, r := range list {
+		out = append(out, r.RelatedID)
+	}
+	return out
+}
+
+func TestComputeRanksBySimilarity(t *testing.T) {
+	got := Compute(corpus(), nil, 3)
+
+	if r := ids(got[1]); len(r) == 0 || r[0] != 2 {
+		t.Errorf("post 1 related = %v, want 2 first", r)
+	}
+	if r := ids(got[3]); len(r) == 0 || r[0] != 5 {
+		t.Errorf("post 3 related = %v, want 5 first", r)
+	}
+	for _, id := range ids(got[3]) {
+		if id == 1 || id == 2 || id == 4 {
+			t.Errorf("baking post related to Go post %d", id)
+		}
+	}
+	for id, list := range got {
+		if len(list) > 3 {
+			t.Errorf("post %d has %d related posts, limit is 3", id, len(list))
+		}
+		for i := 1; i < len(list); i++ {
+			if list[i].Score > list[i-1].Score {
+				t.Errorf("post %d list not sorted: %+v", id, list)
+			}
+		}
+	}
+}
+
+func TestComputeOverrides(t *testing.T) {
+	overrides := []models.RelatedOverride{
+		{PostID: 1, RelatedID: 5, Action: models.RelatedPin},
+		{PostID: 1, RelatedID: 2, Action: models.RelatedExclude},
+		{PostID: 1, RelatedID: 99, Action: models.RelatedPin}, // not published
+	}
+	got := Compute(corpus(), overrides, 3)[1]
+
+	if len(got) == 0 || got[0].RelatedID != 5 || !got[0].Pinned {
+		t.Fatalf("pinned post not first: %+v", got)
+	}
+	for _, r := range got {
+		if r.RelatedID == 2 || r.RelatedID == 99 {
+			t.Errorf("override ignored, got %d in %v", r.RelatedID, ids(got))
+		}
+	}
+}
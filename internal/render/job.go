@@ -0,0 +1,61 @@
+package render
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Progress describes a background rebuild.
+type Progress struct {
+	Running  bool
+	Done     int
+	Failed   int
+	Total    int
+	Started  time.Time
+	Finished time.Time
+}
+
+// Job runs one rebuild at a time in the background and tracks its progress.
+type Job struct {
+	mu       sync.Mutex
+	progress Progress
+}
+
+// Start rebuilds items 0..total-1 by calling step for each one, unless a
+// rebuild is already running. It returns immediately.
+func (j *Job) Start(total int, step func(i int) error) bool {
+	j.mu.Lock()
+	if j.progress.Running {
+		j.mu.Unlock()
+		return false
+	}
+	j.progress = Progress{Running: true, Total: total, Started: time.Now()}
+	j.mu.Unlock()
+
+	go func() {
+		for i := 0; i < total; i++ {
+			err := step(i)
+			j.mu.Lock()
+			j.progress.Done++
+			if err != nil {
+				j.progress.Failed++
+			}
+			j.mu.Unlock()
+		}
+		j.mu.Lock()
+		j.progress.Running = false
+		j.progress.Finished = time.Now()
+		p := j.progress
+		j.mu.Unlock()
+		slog.Info("Re-render finished", "items", p.Total, "failed", p.Failed, "took", p.Finished.Sub(p.Started))
+	}()
+	return true
+}
+
+// Progress returns a snapshot of the current or last rebuild.
+func (j *Job) Progress() Progress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
@@ -0,0 +1,133 @@
+// Package render owns the pipeline that turns post and page Markdown into
+// the HTML stored next to it, and the version that says which pipeline
+// produced stored HTML.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/alextreichler/personal-website/internal/markdown"
+	"golang.org/x/net/html"
+)
+
+// Version identifies the output of the pipeline. Bump it whenever a change
+// alters the HTML for existing content, so stored copies are rebuilt.
+const Version = 1
+
+// Pipeline renders Markdown to sanitized HTML with lazy loading, responsive
+// images.
+type Pipeline struct {
+	// PostTitle resolves post-link shortcodes to the title of a published
+	// post. Links are left unchecked when it is nil.
+	PostTitle func(slug string) (title string, ok bool)
+	// Snippets holds the files snippet shortcodes include. Snippets are
+	// unavailable when it is nil.
+	Snippets fs.FS
+}
+
+// HTML renders Markdown source.
+func (p *Pipeline) HTML(source string) (string, error) {
+	var opts []markdown.Option
+	if p.PostTitle != nil {
+		opts = append(opts, markdown.WithPosts(p.PostTitle))
+	}
+	if p.Snippets != nil {
+		opts = append(opts, markdown.WithSnippets(p.Snippets))
+	}
+	safeHTML, err := markdown.Render(source, opts...)
+	if err != nil {
+		return "", err
+	}
+	safeHTML = strings.ReplaceAll(safeHTML, "<img ", "<img loading=\"lazy\" ")
+	return injectSrcset(safeHTML), nil
+}
+
+// Stale reports whether stored HTML is missing or was produced by an older
+// pipeline.
+func Stale(storedHTML string, version int) bool {
+	return storedHTML == "" || version < Version
+}
+
+// injectSrcset parses the HTML and adds srcset attributes to our optimized images
+func injectSrcset(htmlContent string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return htmlContent // Fallback to original if parsing fails
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "img" {
+			var src string
+			for _, a := range n.Attr {
+				if a.Key == "src" {
+					src = a.Val
+					break
+				}
+			}
+
+			// Check if this is one of our optimized images
+			// Expected format: .../optimized/optimized_UUID.jpg (older uploads used .webp)
+			ext := path.Ext(src)
+			if strings.Contains(src, "/optimized/optimized_") && (ext == ".jpg" || ext == ".webp") {
+				base := strings.TrimSuffix(src, ext)
+
+				// Construct srcset
+				// We have: base.jpg (1200w), base_800w.jpg, base_400w.jpg
+				srcset := fmt.Sprintf("%s_400w%s 400w, %s_800w%s 800w, %s%s 1200w", base, ext, base, ext, base, ext)
+
+				// Add srcset attribute
+				n.Attr = append(n.Attr, html.Attribute{Key: "srcset", Val: srcset})
+
+				// Add sizes attribute
+				sizes := "(max-width: 600px) 400px, (max-width: 900px) 800px, 1200px"
+				n.Attr = append(n.Attr, html.Attribute{Key: "sizes", Val: sizes})
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return htmlContent
+	}
+
+	// html.Render wraps content in <html><head></head><body>...</body></html> if it's a full doc,
+	// or just nodes. Since we parsed a fragment (likely), html.Parse might add html/body tags.
+	// Let's check. html.Parse usually expects a full doc.
+	// For fragments, we should traverse the body's children.
+	// However, simple hack: render and strip the tags if they were added, or just return the body content.
+	// Actually, for post content, it's a fragment. html.Parse will put it in <html><body>...
+
+	// Correct approach for fragment:
+	// Find <body> and render its children
+	var body *html.Node
+	var findBody func(*html.Node)
+	findBody = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "body" {
+			body = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			findBody(c)
+		}
+	}
+	findBody(doc)
+
+	if body != nil {
+		var bodyBuf bytes.Buffer
+		for c := body.FirstChild; c != nil; c = c.NextSibling {
+			html.Render(&bodyBuf, c)
+		}
+		return bodyBuf.String()
+	}
+
+	return buf.String()
+}
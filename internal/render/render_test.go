@@ -0,0 +1,64 @@
+package render
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	p := &Pipeline{}
+	out, err := p.HTML("![cat](/media/optimized/optimized_abc.jpg)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`loading="lazy"`,
+		`srcset="/media/optimized/optimized_abc_400w.jpg 400w, /media/optimized/optimized_abc_800w.jpg 800w, /media/optimized/optimized_abc.jpg 1200w"`,
+		`sizes=`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q lacks %s", out, want)
+		}
+	}
+}
+
+func TestStale(t *testing.T) {
+	if !Stale("", Version) {
+		t.Error("missing HTML should be stale")
+	}
+	if !Stale("<p>x</p>", Version-1) {
+		t.Error("HTML from an older pipeline should be stale")
+	}
+	if Stale("<p>x</p>", Version) {
+		t.Error("current HTML should not be stale")
+	}
+}
+
+func TestJob(t *testing.T) {
+	var j Job
+	release := make(chan struct{})
+	if !j.Start(3, func(i int) error {
+		<-release
+		if i == 1 {
+			return errors.New("boom")
+		}
+		return nil
+	}) {
+		t.Fatal("first Start should run")
+	}
+	if j.Start(1, func(int) error { return nil }) {
+		t.Error("second Start should refuse while running")
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for j.Progress().Running && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	p := j.Progress()
+	if p.Running || p.Done != 3 || p.Failed != 1 || p.Total != 3 {
+		t.Errorf("progress = %+v", p)
+	}
+}
@@ -1,6 +1,15 @@
 package repository
 
+import "database/sql"
+
 func (d *Database) CreateAuditLog(action, details string) error {
 	_, err := d.Conn.Exec("INSERT INTO audit_logs (action, details) VALUES (?, ?)", action, details)
 	return err
 }
+
+// auditTx records an audit log entry as part of tx, so it is only kept if
+// the change it describes is committed.
+func auditTx(tx *sql.Tx, action, details string) error {
+	_, err := tx.Exec("INSERT INTO audit_logs (action, details) VALUES (?, ?)", action, details)
+	return err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import "github.com/alextreichler/personal-website/internal/models"
+
+// SaveAutosave stores the editor content of a post, replacing any earlier
+// autosave.
+func (d *Database) SaveAutosave(a *models.Autosave) error {
+	_, err := d.Conn.Exec(`
+		INSERT INTO post_autosaves (post_id, title, content, saved_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(post_id) DO UPDATE SET title = excluded.title, content = excluded.content, saved_at = excluded.saved_at
+	`, a.PostID, a.Title, a.Content, a.SavedAt)
+	return err
+}
+
+// GetAutosave returns the autosave of a post, or sql.ErrNoRows.
+func (d *Database) GetAutosave(postID int) (*models.Autosave, error) {
+	a := &models.Autosave{}
+	err := d.Conn.QueryRow(`SELECT post_id, title, content, saved_at FROM post_autosaves WHERE post_id = ?`, postID).
+		Scan(&a.PostID, &a.Title, &a.Content, &a.SavedAt)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteAutosave drops the autosave of a post, once it is saved or discarded.
+func (d *Database) DeleteAutosave(postID int) error {
+	_, err := d.Conn.Exec(`DELETE FROM post_autosaves WHERE post_id = ?`, postID)
+	return err
+}
+
+// SaveNewPostAutosave stores the editor content of a post the user hasn't
+// saved yet, replacing any earlier one.
+func (d *Database) SaveNewPostAutosave(username string, a *models.Autosave) error {
+	_, err := d.Conn.Exec(`
+		INSERT INTO new_post_autosaves (username, title, content, saved_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(username) DO UPDATE SET title = excluded.title, content = excluded.content, saved_at = excluded.saved_at
+	`, username, a.Title, a.Content, a.SavedAt)
+	return err
+}
+
+// GetNewPostAutosave returns the autosave of the user's unsaved post, or
+// sql.ErrNoRows.
+func (d *Database) GetNewPostAutosave(username string) (*models.Autosave, error) {
+	a := &models.Autosave{}
+	err := d.Conn.QueryRow(`SELECT title, content, saved_at FROM new_post_autosaves WHERE username = ?`, username).
+		Scan(&a.Title, &a.Content, &a.SavedAt)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteNewPostAutosave drops the autosave of the user's unsaved post, once
+// it is created or discarded.
+func (d *Database) DeleteNewPostAutosave(username string) error {
+	_, err := d.Conn.Exec(`DELETE FROM new_post_autosaves WHERE username = ?`, username)
+	return err
+}
@@ -4,12 +4,20 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	_ "modernc.org/sqlite"
 )
 
 type Database struct {
 	Conn *sql.DB
+
+	mu      sync.Mutex
+	stmts   map[string]*sql.Stmt // Prepared statements by query text
+	queries atomic.Int64         // Queries run through stmts, for tests
+
+	listeners []func(Change) // Called after writes, see OnChange
 }
 
 func NewDatabase(dbPath string) (*Database, error) {
@@ -83,6 +91,100 @@ func (d *Database) Migrate() error {
 		details TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
+
+	CREATE TABLE IF NOT EXISTS media (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		storage_key TEXT NOT NULL UNIQUE,
+		preview_key TEXT,
+		original_name TEXT,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		variants_size INTEGER NOT NULL DEFAULT 0,
+		width INTEGER DEFAULT 0,
+		height INTEGER DEFAULT 0,
+		uploaded_by TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_media_uploaded_by ON media(uploaded_by);
+
+	CREATE TABLE IF NOT EXISTS related_posts (
+		post_id INTEGER NOT NULL,
+		related_id INTEGER NOT NULL,
+		rank INTEGER NOT NULL,
+		score REAL NOT NULL DEFAULT 0,
+		pinned INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (post_id, related_id),
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+		FOREIGN KEY (related_id) REFERENCES posts(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_related_posts_rank ON related_posts(post_id, rank);
+
+	CREATE TABLE IF NOT EXISTS series (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		slug TEXT NOT NULL UNIQUE,
+		description TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS related_overrides (
+		post_id INTEGER NOT NULL,
+		related_id INTEGER NOT NULL,
+		action TEXT NOT NULL CHECK (action IN ('pin', 'exclude')),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (post_id, related_id),
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+		FOREIGN KEY (related_id) REFERENCES posts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS pages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		slug TEXT NOT NULL UNIQUE,
+		content TEXT NOT NULL,
+		html_content TEXT,
+		meta_description TEXT,
+		status TEXT NOT NULL DEFAULT 'draft',
+		show_in_nav INTEGER NOT NULL DEFAULT 0,
+		nav_order INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS post_links (
+		post_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		PRIMARY KEY (post_id, url),
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS link_checks (
+		url TEXT PRIMARY KEY,
+		status INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		checked_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS post_autosaves (
+		post_id INTEGER PRIMARY KEY,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		saved_at DATETIME NOT NULL,
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS new_post_autosaves (
+		username TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		saved_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS tag_redirects (
+		old_name TEXT PRIMARY KEY,
+		new_name TEXT NOT NULL
+	);
 	`
 
 	_, err := d.Conn.Exec(query)
@@ -95,6 +197,19 @@ func (d *Database) Migrate() error {
 		`ALTER TABLE posts ADD COLUMN status TEXT DEFAULT 'published'`,
 		`ALTER TABLE posts ADD COLUMN views INTEGER DEFAULT 0`,
 		`ALTER TABLE posts ADD COLUMN html_content TEXT`,
+		`ALTER TABLE posts ADD COLUMN audio_media_id INTEGER REFERENCES media(id) ON DELETE SET NULL`,
+		`ALTER TABLE media ADD COLUMN preview_key TEXT`,
+		`ALTER TABLE posts ADD COLUMN cover_media_id INTEGER REFERENCES media(id) ON DELETE SET NULL`,
+		`ALTER TABLE posts ADD COLUMN excerpt TEXT`,
+		`ALTER TABLE posts ADD COLUMN meta_description TEXT`,
+		`ALTER TABLE posts ADD COLUMN series_id INTEGER REFERENCES series(id) ON DELETE SET NULL`,
+		`ALTER TABLE posts ADD COLUMN series_position INTEGER NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_series ON posts(series_id, series_position)`,
+		`ALTER TABLE posts ADD COLUMN render_version INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE pages ADD COLUMN render_version INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE posts ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE tags ADD COLUMN display_name TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE tags ADD COLUMN description TEXT NOT NULL DEFAULT ''`,
 	}
 
 	// ... existing migration loop ...
@@ -0,0 +1,35 @@
+package repository
+
+// Change names what kind of public content a write touched. Listeners such
+// as the page cache use it to drop what depends on that content.
+type Change string
+
+const (
+	ChangePosts    Change = "posts"    // Post content, status, tags, related posts or series membership
+	ChangeTags     Change = "tags"     // Tag names, display names and descriptions
+	ChangeSeries   Change = "series"   // Series titles, descriptions and order
+	ChangePages    Change = "pages"    // Static pages and the navigation menu
+	ChangeSettings Change = "settings" // Site settings such as the about text
+)
+
+// OnChange registers fn to be called after each committed write, with the
+// kinds of content it changed. View counts, autosaves, link checks and the
+// audit log are not reported.
+func (d *Database) OnChange(fn func(Change)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, fn)
+}
+
+// changed reports a committed write to the listeners.
+func (d *Database) changed(changes ...Change) {
+	d.mu.Lock()
+	listeners := d.listeners
+	d.mu.Unlock()
+
+	for _, c := range changes {
+		for _, fn := range listeners {
+			fn(c)
+		}
+	}
+}
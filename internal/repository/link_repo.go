@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+// ReplacePostLinks records the links found in a post's content.
+func (d *Database) ReplacePostLinks(postID int, urls []string) error {
+	tx, err := d.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM post_links WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	for _, u := range urls {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO post_links (post_id, url) VALUES (?, ?)`, postID, u); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetLinksDue lists the linked URLs that have not been checked since before.
+func (d *Database) GetLinksDue(before time.Time) ([]string, error) {
+	rows, err := d.Conn.Query(`
+		SELECT DISTINCT pl.url FROM post_links pl
+		JOIN posts p ON p.id = pl.post_id AND p.deleted_at IS NULL
+		LEFT JOIN link_checks lc ON lc.url = pl.url
+		WHERE lc.url IS NULL OR lc.checked_at < ?
+		ORDER BY pl.url`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, rows.Err()
+}
+
+// SaveLinkCheck stores the latest result for a URL.
+func (d *Database) SaveLinkCheck(c models.LinkCheck) error {
+	_, err := d.Conn.Exec(`INSERT INTO link_checks (url, status, error, checked_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(url) DO UPDATE SET status = excluded.status, error = excluded.error, checked_at = excluded.checked_at`,
+		c.URL, c.Status, c.Error, c.CheckedAt)
+	return err
+}
+
+// GetPostLinks lists the links of one post, or of every post when postID
+// is 0, with their last check.
+func (d *Database) GetPostLinks(postID int) ([]*models.PostLink, error) {
+	rows, err := d.Conn.Query(`
+		SELECT p.id, p.title, p.slug, pl.url, lc.status, lc.error, lc.checked_at
+		FROM post_links pl
+		JOIN posts p ON p.id = pl.post_id AND p.deleted_at IS NULL
+		LEFT JOIN link_checks lc ON lc.url = pl.url
+		WHERE ? = 0 OR pl.post_id = ?
+		ORDER BY p.title COLLATE NOCASE, pl.url`, postID, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*models.PostLink
+	for rows.Next() {
+		l := &models.PostLink{}
+		var status sql.NullInt64
+		var checkErr sql.NullString
+		var checkedAt sql.NullTime
+		if err := rows.Scan(&l.PostID, &l.PostTitle, &l.PostSlug, &l.URL, &status, &checkErr, &checkedAt); err != nil {
+			return nil, err
+		}
+		if checkedAt.Valid {
+			l.Check = &models.LinkCheck{URL: l.URL, Status: int(status.Int64), Error: checkErr.String, CheckedAt: checkedAt.Time}
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
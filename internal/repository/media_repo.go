@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+const mediaColumns = `id, storage_key, preview_key, original_name, content_type, size, variants_size, width, height, uploaded_by, created_at`
+
+func scanMedia(row interface{ Scan(...any) error }) (*models.Media, error) {
+	m := &models.Media{}
+	var previewKey, originalName, uploadedBy sql.NullString
+	err := row.Scan(&m.ID, &m.StorageKey, &previewKey, &originalName, &m.ContentType, &m.Size, &m.VariantsSize, &m.Width, &m.Height, &uploadedBy, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	m.PreviewKey = previewKey.String
+	m.OriginalName = originalName.String
+	m.UploadedBy = uploadedBy.String
+	return m, nil
+}
+
+func (d *Database) CreateMedia(m *models.Media) error {
+	query := `INSERT INTO media (storage_key, preview_key, original_name, content_type, size, variants_size, width, height, uploaded_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := d.Conn.Exec(query, m.StorageKey, m.PreviewKey, m.OriginalName, m.ContentType, m.Size, m.VariantsSize, m.Width, m.Height, m.UploadedBy, m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err == nil {
+		m.ID = int(id)
+	}
+	return nil
+}
+
+func (d *Database) GetMediaByID(id int) (*models.Media, error) {
+	return scanMedia(d.queryRow(`SELECT `+mediaColumns+` FROM media WHERE id = ?`, id))
+}
+
+// GetMediaByIDs loads several media items in one query, by ID. Zero and
+// unknown IDs are left out.
+func (d *Database) GetMediaByIDs(ids []int) (map[int]*models.Media, error) {
+	media := make(map[int]*models.Media)
+	if len(ids) == 0 {
+		return media, nil
+	}
+	idList, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.query(`SELECT `+mediaColumns+` FROM media WHERE id IN (SELECT value FROM json_each(?))`, string(idList))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		media[m.ID] = m
+	}
+	return media, rows.Err()
+}
+
+func (d *Database) GetMediaByKey(key string) (*models.Media, error) {
+	return scanMedia(d.queryRow(`SELECT `+mediaColumns+` FROM media WHERE storage_key = ?`, key))
+}
+
+// GetAllMedia returns the library newest first. A non-empty typePrefix such as
+// "audio/" restricts the result to matching content types.
+func (d *Database) GetAllMedia(typePrefix string) ([]*models.Media, error) {
+	rows, err := d.Conn.Query(`SELECT `+mediaColumns+` FROM media WHERE content_type LIKE ? ORDER BY created_at DESC, id DESC`, typePrefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var media []*models.Media
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
+
+// GetMediaUsage returns the total bytes stored by a user, including variants.
+func (d *Database) GetMediaUsage(username string) (int64, error) {
+	var total int64
+	err := d.Conn.QueryRow("SELECT COALESCE(SUM(size + variants_size), 0) FROM media WHERE uploaded_by = ?", username).Scan(&total)
+	return total, err
+}
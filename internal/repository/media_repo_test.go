@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+func TestGetMediaByIDs(t *testing.T) {
+	db := newTestDB(t)
+	var ids []int
+	for i := 0; i < 3; i++ {
+		m := &models.Media{StorageKey: fmt.Sprintf("file-%d.mp3", i), ContentType: "audio/mpeg", CreatedAt: time.Now()}
+		if err := db.CreateMedia(m); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, m.ID)
+	}
+
+	var media map[int]*models.Media
+	n := countQueries(db, func() {
+		var err error
+		if media, err = db.GetMediaByIDs([]int{ids[0], ids[2], 0, 999}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if n != 1 {
+		t.Errorf("ran %d queries, want 1", n)
+	}
+	if len(media) != 2 || media[ids[0]].StorageKey != "file-0.mp3" || media[ids[2]].StorageKey != "file-2.mp3" {
+		t.Errorf("GetMediaByIDs = %v, want the first and last file", media)
+	}
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+const pageColumns = `id, title, slug, content, html_content, meta_description, status, show_in_nav, nav_order, render_version, created_at, updated_at`
+
+func (d *Database) CreatePage(p *models.Page) error {
+	res, err := d.Conn.Exec(`INSERT INTO pages (title, slug, content, html_content, meta_description, status, show_in_nav, nav_order, render_version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Title, p.Slug, p.Content, p.HTMLContent, p.MetaDescription, p.Status, p.ShowInNav, p.NavOrder, p.RenderVersion, p.CreatedAt, p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		p.ID = int(id)
+	}
+	d.changed(ChangePages)
+	return nil
+}
+
+func (d *Database) UpdatePage(p *models.Page) error {
+	_, err := d.Conn.Exec(`UPDATE pages SET title = ?, slug = ?, content = ?, html_content = ?, meta_description = ?, status = ?, show_in_nav = ?, nav_order = ?, render_version = ?, updated_at = ? WHERE id = ?`,
+		p.Title, p.Slug, p.Content, p.HTMLContent, p.MetaDescription, p.Status, p.ShowInNav, p.NavOrder, p.RenderVersion, p.UpdatedAt, p.ID)
+	if err != nil {
+		return err
+	}
+	d.changed(ChangePages)
+	return nil
+}
+
+// UpdatePageHTML replaces the cached HTML of a page without touching its
+// update time. content is the markdown the HTML was rendered from: if the
+// page was saved with different content in the meantime, the save's own
+// HTML is newer and is kept.
+func (d *Database) UpdatePageHTML(id int, content, htmlContent string, version int) error {
+	res, err := d.Conn.Exec(`UPDATE pages SET html_content = ?, render_version = ? WHERE id = ? AND content = ?`, htmlContent, version, id, content)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil
+	}
+	d.changed(ChangePages)
+	return nil
+}
+
+func (d *Database) DeletePage(id int) error {
+	if _, err := d.Conn.Exec(`DELETE FROM pages WHERE id = ?`, id); err != nil {
+		return err
+	}
+	d.changed(ChangePages)
+	return nil
+}
+
+func scanPage(row interface{ Scan(...any) error }) (*models.Page, error) {
+	p := &models.Page{}
+	var htmlContent, meta sql.NullString
+	if err := row.Scan(&p.ID, &p.Title, &p.Slug, &p.Content, &htmlContent, &meta, &p.Status, &p.ShowInNav, &p.NavOrder, &p.RenderVersion, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	p.HTMLContent = htmlContent.String
+	p.MetaDescription = meta.String
+	return p, nil
+}
+
+func (d *Database) queryPages(query string, args ...any) ([]*models.Page, error) {
+	rows, err := d.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pages []*models.Page
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+func (d *Database) GetPageByID(id int) (*models.Page, error) {
+	return scanPage(d.Conn.QueryRow(`SELECT `+pageColumns+` FROM pages WHERE id = ?`, id))
+}
+
+// GetPublishedPageBySlug returns a page visible to visitors.
+func (d *Database) GetPublishedPageBySlug(slug string) (*models.Page, error) {
+	return scanPage(d.queryRow(`SELECT `+pageColumns+` FROM pages WHERE slug = ? AND status = 'published'`, slug))
+}
+
+// GetAllPages lists every page, drafts included, in menu order.
+func (d *Database) GetAllPages() ([]*models.Page, error) {
+	return d.queryPages(`SELECT ` + pageColumns + ` FROM pages ORDER BY nav_order, title COLLATE NOCASE`)
+}
+
+// GetPublishedPages lists the pages visible to visitors, in menu order.
+func (d *Database) GetPublishedPages() ([]*models.Page, error) {
+	return d.queryPages(`SELECT ` + pageColumns + ` FROM pages WHERE status = 'published' ORDER BY nav_order, title COLLATE NOCASE`)
+}
+
+// GetNavPages lists the published pages marked for the site menu.
+func (d *Database) GetNavPages() ([]*models.Page, error) {
+	return d.queryPages(`SELECT ` + pageColumns + ` FROM pages WHERE status = 'published' AND show_in_nav = 1 ORDER BY nav_order, title COLLATE NOCASE`)
+}
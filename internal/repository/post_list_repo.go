@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+// PostFilter selects and orders posts for the admin post list. Zero values
+// mean no filter.
+type PostFilter struct {
+	Status string    // "draft" or "published"
+	Tag    string    // Only posts with this tag
+	Query  string    // Substring of the title or content
+	From   time.Time // Created on or after this day
+	To     time.Time // Created before this day
+	Sort   string    // One of PostSortColumns, default created_at
+	Desc   bool
+	Limit  int
+	Offset int
+}
+
+// PostSortColumns are the columns the admin post list can be sorted by.
+var PostSortColumns = []string{"title", "status", "views", "created_at", "updated_at"}
+
+// ListPosts returns one page of the posts matching f, with their tags, and
+// the number of matching posts across all pages.
+func (d *Database) ListPosts(f PostFilter) ([]*models.Post, int, error) {
+	where := []string{"p.deleted_at IS NULL"}
+	var args []interface{}
+	if f.Status != "" {
+		where = append(where, "p.status = ?")
+		args = append(args, f.Status)
+	}
+	if f.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON pt.tag_id = t.id WHERE pt.post_id = p.id AND t.name = ?)")
+		args = append(args, strings.ToLower(f.Tag))
+	}
+	if f.Query != "" {
+		where = append(where, `(p.title LIKE ? ESCAPE '\' OR p.content LIKE ? ESCAPE '\')`)
+		term := "%" + likeEscaper.Replace(f.Query) + "%"
+		args = append(args, term, term)
+	}
+	// Dates are stored as text starting with YYYY-MM-DD
+	if !f.From.IsZero() {
+		where = append(where, "substr(p.created_at, 1, 10) >= ?")
+		args = append(args, f.From.Format("2006-01-02"))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "substr(p.created_at, 1, 10) < ?")
+		args = append(args, f.To.Format("2006-01-02"))
+	}
+	cond := strings.Join(where, " AND ")
+
+	var total int
+	if err := d.queryRow(`SELECT COUNT(*) FROM posts p WHERE `+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sortCol := "created_at"
+	for _, c := range PostSortColumns {
+		if f.Sort == c {
+			sortCol = c
+		}
+	}
+	dir := "ASC"
+	if f.Desc {
+		dir = "DESC"
+	}
+	query := `SELECT ` + postColumns + ` FROM posts p WHERE ` + cond +
+		` ORDER BY p.` + sortCol + ` ` + dir + `, p.id ` + dir + ` LIMIT ? OFFSET ?`
+	posts, err := d.queryPosts(query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return posts, total, nil
+}
+
+// likeEscaper makes LIKE wildcards in a search term match literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// placeholders returns n comma separated SQL parameters.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Bulk actions on posts.
+const (
+	BulkPublish   = "publish"
+	BulkUnpublish = "unpublish"
+	BulkDelete    = "delete"
+	BulkAddTag    = "add-tag"
+	BulkRemoveTag = "remove-tag"
+)
+
+// BulkUpdatePosts applies action to the posts with the given IDs in one
+// transaction, together with an audit log entry, and returns how many posts
+// it changed. tag is the tag to add or remove.
+func (d *Database) BulkUpdatePosts(ids []int, action, tag string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	tagAction := action == BulkAddTag || action == BulkRemoveTag
+	tag = NormalizeTag(tag)
+	if !tagAction {
+		tag = ""
+	} else if tag == "" {
+		return 0, fmt.Errorf("%s needs a tag", action)
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	in := `id IN (` + placeholders(len(ids)) + `) AND deleted_at IS NULL`
+	hasTag := `EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = posts.id AND t.name = ?)`
+	now := time.Now()
+
+	tx, err := d.Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var res sql.Result
+	switch action {
+	case BulkPublish:
+		// Publishing a draft dates it now, as when publishing from the editor
+		res, err = tx.Exec(`UPDATE posts SET created_at = ?, status = 'published', updated_at = ?, version = version + 1 WHERE status != 'published' AND `+in,
+			append([]interface{}{now, now}, args...)...)
+	case BulkUnpublish:
+		res, err = tx.Exec(`UPDATE posts SET status = 'draft', updated_at = ?, version = version + 1 WHERE status != 'draft' AND `+in,
+			append([]interface{}{now}, args...)...)
+	case BulkDelete:
+		res, err = tx.Exec(`UPDATE posts SET deleted_at = ? WHERE `+in, append([]interface{}{now}, args...)...)
+	case BulkAddTag:
+		if _, err = tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+			return 0, err
+		}
+		// Tag changes count as edits, so open editors notice them; only
+		// posts that don't have the tag yet change
+		if _, err = tx.Exec(`UPDATE posts SET version = version + 1 WHERE NOT `+hasTag+` AND `+in,
+			append([]interface{}{tag}, args...)...); err != nil {
+			return 0, err
+		}
+		res, err = tx.Exec(`INSERT OR IGNORE INTO post_tags (post_id, tag_id) SELECT id, (SELECT id FROM tags WHERE name = ?) FROM posts WHERE `+in,
+			append([]interface{}{tag}, args...)...)
+	case BulkRemoveTag:
+		if _, err = tx.Exec(`UPDATE posts SET version = version + 1 WHERE `+hasTag+` AND `+in,
+			append([]interface{}{tag}, args...)...); err != nil {
+			return 0, err
+		}
+		res, err = tx.Exec(`DELETE FROM post_tags WHERE tag_id = (SELECT id FROM tags WHERE name = ?) AND post_id IN (SELECT id FROM posts WHERE `+in+`)`,
+			append([]interface{}{tag}, args...)...)
+	default:
+		return 0, fmt.Errorf("unknown bulk action %q", action)
+	}
+	if err != nil {
+		return 0, err
+	}
+	changed, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	strIDs := make([]string, len(ids))
+	for i, id := range ids {
+		strIDs[i] = strconv.Itoa(id)
+	}
+	details := fmt.Sprintf("%d of %d posts changed (ids %s)", changed, len(ids), strings.Join(strIDs, ", "))
+	if tag != "" {
+		details += fmt.Sprintf(", tag %q", tag)
+	}
+	if err := auditTx(tx, "posts.bulk-"+action, details); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if changed > 0 {
+		d.changed(ChangePosts, ChangeTags)
+	}
+	return changed, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+func TestPostSearchQueryIsLiteral(t *testing.T) {
+	db := newTestDB(t)
+	for _, title := range []string{"100% coverage", "1000 posts", "snake_case", "snakeXcase", `C:\path`} {
+		post := &models.Post{Title: title, Slug: title, Status: "published", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := db.CreatePost(post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for query, want := range map[string]string{
+		"100%":  "100% coverage",
+		"e_c":   "snake_case",
+		`:\pat`: `C:\path`,
+	} {
+		posts, total, err := db.ListPosts(PostFilter{Query: query, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(posts) != 1 || posts[0].Title != want {
+			var got []string
+			for _, p := range posts {
+				got = append(got, p.Title)
+			}
+			t.Errorf("ListPosts(%q) = %q, want only %q", query, got, want)
+		}
+
+		// The public search escapes its query the same way
+		posts, err = db.SearchPosts(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 1 || posts[0].Title != want {
+			var got []string
+			for _, p := range posts {
+				got = append(got, p.Title)
+			}
+			t.Errorf("SearchPosts(%q) = %q, want only %q", query, got, want)
+		}
+	}
+}
+
+func TestBulkTagBumpsOnlyChangedPosts(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 2) // Both tagged "all", post-0 also "post-0"
+
+	version := func(slug string) int {
+		t.Helper()
+		post, err := db.GetPostBySlug(slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return post.Version
+	}
+	v0, v1 := version("post-0"), version("post-1")
+
+	changed, err := db.BulkUpdatePosts([]int{1, 2}, BulkAddTag, "post-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 1 || version("post-0") != v0 || version("post-1") != v1+1 {
+		t.Errorf("adding a tag one post already has: changed %d, versions %d->%d and %d->%d",
+			changed, v0, version("post-0"), v1, version("post-1"))
+	}
+
+	v0, v1 = version("post-0"), version("post-1")
+	if _, err := db.BulkUpdatePosts([]int{1, 2}, BulkRemoveTag, "post-1"); err != nil {
+		t.Fatal(err)
+	}
+	if version("post-0") != v0 || version("post-1") != v1+1 {
+		t.Error("removing a tag bumped a post that didn't have it")
+	}
+}
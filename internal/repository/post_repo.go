@@ -2,14 +2,17 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"strings"
+	"time"
 
 	"github.com/alextreichler/personal-website/internal/models"
 )
 
 func (d *Database) CreatePost(post *models.Post) error {
-	query := `INSERT INTO posts (title, slug, content, html_content, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	res, err := d.Conn.Exec(query, post.Title, post.Slug, post.Content, post.HTMLContent, post.Status, post.CreatedAt, post.UpdatedAt)
+	query := `INSERT INTO posts (title, slug, content, html_content, render_version, excerpt, meta_description, status, audio_media_id, cover_media_id, series_id, series_position, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := d.Conn.Exec(query, post.Title, post.Slug, post.Content, post.HTMLContent, post.RenderVersion, post.Excerpt, post.MetaDescription, post.Status, nullableID(post.AudioMediaID), nullableID(post.CoverMediaID), nullableID(post.SeriesID), post.SeriesPosition, post.CreatedAt, post.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -17,62 +20,92 @@ func (d *Database) CreatePost(post *models.Post) error {
 	if err == nil {
 		post.ID = int(id)
 	}
+	post.Version = 1
+	d.changed(ChangePosts)
 	return nil
 }
 
+// ErrConflict is returned by UpdatePost when the post was saved by someone
+// else since post.Version was read.
+var ErrConflict = errors.New("post was modified by another save")
+
+// UpdatePost saves post if it is still at post.Version, then increments the
+// version.
 func (d *Database) UpdatePost(post *models.Post) error {
-	query := `UPDATE posts SET title = ?, slug = ?, content = ?, html_content = ?, status = ?, created_at = ?, updated_at = ? WHERE id = ?`
-	_, err := d.Conn.Exec(query, post.Title, post.Slug, post.Content, post.HTMLContent, post.Status, post.CreatedAt, post.UpdatedAt, post.ID)
-	return err
+	query := `UPDATE posts SET title = ?, slug = ?, content = ?, html_content = ?, render_version = ?, excerpt = ?, meta_description = ?, status = ?, audio_media_id = ?, cover_media_id = ?, series_id = ?, series_position = ?, created_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND version = ?`
+	res, err := d.Conn.Exec(query, post.Title, post.Slug, post.Content, post.HTMLContent, post.RenderVersion, post.Excerpt, post.MetaDescription, post.Status, nullableID(post.AudioMediaID), nullableID(post.CoverMediaID), nullableID(post.SeriesID), post.SeriesPosition, post.CreatedAt, post.UpdatedAt, post.ID, post.Version)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrConflict
+	}
+	post.Version++
+	d.changed(ChangePosts)
+	return nil
 }
 
-func (d *Database) GetPostBySlug(slug string) (*models.Post, error) {
-	// Increment view count
-	_, _ = d.Conn.Exec(`UPDATE posts SET views = views + 1 WHERE slug = ?`, slug)
-
-	query := `SELECT id, title, slug, content, html_content, status, views, created_at, updated_at FROM posts WHERE slug = ? AND deleted_at IS NULL AND status = 'published'`
-	row := d.Conn.QueryRow(query, slug)
-
-	post := &models.Post{}
-	// Handle potential NULL html_content
-	var htmlContent sql.NullString
-	err := row.Scan(&post.ID, &post.Title, &post.Slug, &post.Content, &htmlContent, &post.Status, &post.Views, &post.CreatedAt, &post.UpdatedAt)
+// UpdatePostHTML replaces the cached HTML of a post without touching its
+// update time. content is the markdown the HTML was rendered from: if the
+// post was saved with different content in the meantime, the save's own
+// HTML is newer and is kept.
+func (d *Database) UpdatePostHTML(id int, content, htmlContent string, version int) error {
+	res, err := d.Conn.Exec(`UPDATE posts SET html_content = ?, render_version = ? WHERE id = ? AND content = ?`, htmlContent, version, id, content)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	post.HTMLContent = htmlContent.String
-
-	tags, err := d.GetTagsForPost(post.ID)
-	if err == nil {
-		post.Tags = tags
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil
 	}
+	d.changed(ChangePosts)
+	return nil
+}
 
-	return post, nil
+// nullableID stores a zero foreign key as NULL.
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
 }
 
-func (d *Database) GetPostByID(id int) (*models.Post, error) {
-	query := `SELECT id, title, slug, content, html_content, status, created_at, updated_at FROM posts WHERE id = ? AND deleted_at IS NULL`
-	row := d.Conn.QueryRow(query, id)
+// postColumns are the columns read by scanPost, from posts aliased as p.
+const postColumns = `p.id, p.title, p.slug, p.content, p.html_content, p.render_version, p.excerpt, p.meta_description, p.status, p.views, p.audio_media_id, p.cover_media_id, p.series_id, p.series_position, p.created_at, p.updated_at, p.version`
 
+func scanPost(row interface{ Scan(...any) error }) (*models.Post, error) {
 	post := &models.Post{}
-	var htmlContent sql.NullString
-	err := row.Scan(&post.ID, &post.Title, &post.Slug, &post.Content, &htmlContent, &post.Status, &post.CreatedAt, &post.UpdatedAt)
-	if err != nil {
+	// Columns added by migrations may be NULL in older rows
+	var htmlContent, excerpt, metaDesc sql.NullString
+	var views, audioID, coverID, seriesID sql.NullInt64
+	if err := row.Scan(&post.ID, &post.Title, &post.Slug, &post.Content, &htmlContent, &post.RenderVersion, &excerpt, &metaDesc, &post.Status, &views, &audioID, &coverID, &seriesID, &post.SeriesPosition, &post.CreatedAt, &post.UpdatedAt, &post.Version); err != nil {
 		return nil, err
 	}
 	post.HTMLContent = htmlContent.String
+	post.Excerpt = excerpt.String
+	post.MetaDescription = metaDesc.String
+	post.Views = int(views.Int64)
+	post.AudioMediaID = int(audioID.Int64)
+	post.CoverMediaID = int(coverID.Int64)
+	post.SeriesID = int(seriesID.Int64)
+	return post, nil
+}
 
-	tags, err := d.GetTagsForPost(post.ID)
-	if err == nil {
-		post.Tags = tags
+// getPost runs a query selecting postColumns of one post and loads its tags.
+func (d *Database) getPost(query string, args ...any) (*models.Post, error) {
+	post, err := scanPost(d.queryRow(query, args...))
+	if err != nil {
+		return nil, err
+	}
+	if err := d.loadTags([]*models.Post{post}); err != nil {
+		return nil, err
 	}
-
 	return post, nil
 }
 
-func (d *Database) GetAllPosts() ([]*models.Post, error) {
-	query := `SELECT id, title, slug, content, html_content, status, views, created_at, updated_at FROM posts WHERE deleted_at IS NULL ORDER BY created_at DESC`
-	rows, err := d.Conn.Query(query)
+// queryPosts runs a query selecting postColumns and loads the tags of all
+// the posts with one more query, however many there are.
+func (d *Database) queryPosts(query string, args ...any) ([]*models.Post, error) {
+	rows, err := d.query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -80,22 +113,81 @@ func (d *Database) GetAllPosts() ([]*models.Post, error) {
 
 	var posts []*models.Post
 	for rows.Next() {
-		post := &models.Post{}
-		var htmlContent sql.NullString
-		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.Content, &htmlContent, &post.Status, &post.Views, &post.CreatedAt, &post.UpdatedAt); err != nil {
+		post, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-		post.HTMLContent = htmlContent.String
-		// Populate tags
-		tags, err := d.GetTagsForPost(post.ID)
-		if err == nil {
-			post.Tags = tags
-		}
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := d.loadTags(posts); err != nil {
+		return nil, err
+	}
 	return posts, nil
 }
 
+// loadTags fills in the tags of posts with a single query. The IDs are
+// passed as one JSON array so the statement is the same for any number of
+// posts.
+func (d *Database) loadTags(posts []*models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.Post, len(posts))
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		byID[p.ID] = p
+		ids[i] = p.ID
+	}
+	idList, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT pt.post_id, t.name
+		FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.post_id IN (SELECT value FROM json_each(?))
+		ORDER BY t.name ASC
+	`
+	rows, err := d.query(query, string(idList))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, name)
+	}
+	return rows.Err()
+}
+
+// IncrementViews bumps the view counter of a published post.
+func (d *Database) IncrementViews(slug string) error {
+	_, err := d.exec(`UPDATE posts SET views = views + 1 WHERE slug = ? AND deleted_at IS NULL AND status = 'published'`, slug)
+	return err
+}
+
+func (d *Database) GetPostBySlug(slug string) (*models.Post, error) {
+	return d.getPost(`SELECT `+postColumns+` FROM posts p WHERE p.slug = ? AND p.deleted_at IS NULL AND p.status = 'published'`, slug)
+}
+
+func (d *Database) GetPostByID(id int) (*models.Post, error) {
+	return d.getPost(`SELECT `+postColumns+` FROM posts p WHERE p.id = ? AND p.deleted_at IS NULL`, id)
+}
+
+func (d *Database) GetAllPosts() ([]*models.Post, error) {
+	return d.queryPosts(`SELECT ` + postColumns + ` FROM posts p WHERE p.deleted_at IS NULL ORDER BY p.created_at DESC`)
+}
+
 func (d *Database) GetDashboardStats() (*models.DashboardStats, error) {
 	stats := &models.DashboardStats{}
 
@@ -143,29 +235,7 @@ func (d *Database) GetDashboardStats() (*models.DashboardStats, error) {
 }
 
 func (d *Database) GetPublishedPosts(limit, offset int) ([]*models.Post, error) {
-	query := `SELECT id, title, slug, content, html_content, status, created_at, updated_at FROM posts WHERE deleted_at IS NULL AND status = 'published' ORDER BY created_at DESC LIMIT ? OFFSET ?`
-	rows, err := d.Conn.Query(query, limit, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var posts []*models.Post
-	for rows.Next() {
-		post := &models.Post{}
-		var htmlContent sql.NullString
-		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.Content, &htmlContent, &post.Status, &post.CreatedAt, &post.UpdatedAt); err != nil {
-			return nil, err
-		}
-		post.HTMLContent = htmlContent.String
-		// Populate tags
-		tags, err := d.GetTagsForPost(post.ID)
-		if err == nil {
-			post.Tags = tags
-		}
-		posts = append(posts, post)
-	}
-	return posts, nil
+	return d.queryPosts(`SELECT `+postColumns+` FROM posts p WHERE p.deleted_at IS NULL AND p.status = 'published' ORDER BY p.created_at DESC LIMIT ? OFFSET ?`, limit, offset)
 }
 
 func (d *Database) CountPublishedPosts() (int, error) {
@@ -177,93 +247,39 @@ func (d *Database) CountPublishedPosts() (int, error) {
 
 func (d *Database) SearchPosts(query string) ([]*models.Post, error) {
 	sqlQuery := `
-		SELECT id, title, slug, content, html_content, status, created_at, updated_at 
-		FROM posts 
-		WHERE (title LIKE ? OR content LIKE ?) 
-		AND deleted_at IS NULL AND status = 'published' 
-		ORDER BY created_at DESC
+		SELECT ` + postColumns + `
+		FROM posts p
+		WHERE (p.title LIKE ? ESCAPE '\' OR p.content LIKE ? ESCAPE '\')
+		AND p.deleted_at IS NULL AND p.status = 'published'
+		ORDER BY p.created_at DESC
 	`
-	searchTerm := "%" + query + "%"
-	rows, err := d.Conn.Query(sqlQuery, searchTerm, searchTerm)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var posts []*models.Post
-	for rows.Next() {
-		post := &models.Post{}
-		var htmlContent sql.NullString
-		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.Content, &htmlContent, &post.Status, &post.CreatedAt, &post.UpdatedAt); err != nil {
-			return nil, err
-		}
-		post.HTMLContent = htmlContent.String
-		posts = append(posts, post)
-	}
-	return posts, nil
+	searchTerm := "%" + likeEscaper.Replace(query) + "%"
+	return d.queryPosts(sqlQuery, searchTerm, searchTerm)
 }
 
 func (d *Database) DeletePost(id int) error {
 	query := `UPDATE posts SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err := d.Conn.Exec(query, id)
-	return err
+	if _, err := d.Conn.Exec(query, id); err != nil {
+		return err
+	}
+	d.changed(ChangePosts)
+	return nil
 }
 
 func (d *Database) GetPostsByTag(tagName string) ([]*models.Post, error) {
 	query := `
-		SELECT p.id, p.title, p.slug, p.content, p.html_content, p.status, p.created_at, p.updated_at 
+		SELECT ` + postColumns + `
 		FROM posts p
 		JOIN post_tags pt ON p.id = pt.post_id
 		JOIN tags t ON pt.tag_id = t.id
 		WHERE t.name = ? AND p.deleted_at IS NULL AND p.status = 'published'
 		ORDER BY p.created_at DESC
 	`
-	rows, err := d.Conn.Query(query, tagName)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var posts []*models.Post
-	for rows.Next() {
-		post := &models.Post{}
-		var htmlContent sql.NullString
-		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.Content, &htmlContent, &post.Status, &post.CreatedAt, &post.UpdatedAt); err != nil {
-			return nil, err
-		}
-		post.HTMLContent = htmlContent.String
-		posts = append(posts, post)
-	}
-	return posts, nil
+	return d.queryPosts(query, tagName)
 }
 
 // Tag Management
 
-func (d *Database) GetTagsForPost(postID int) ([]string, error) {
-	query := `
-		SELECT t.name 
-		FROM tags t
-		JOIN post_tags pt ON t.id = pt.tag_id
-		WHERE pt.post_id = ?
-		ORDER BY t.name ASC
-	`
-	rows, err := d.Conn.Query(query, postID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var tags []string
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return nil, err
-		}
-		tags = append(tags, name)
-	}
-	return tags, nil
-}
-
 func (d *Database) SetPostTags(postID int, tags []string) error {
 	tx, err := d.Conn.Begin()
 	if err != nil {
@@ -304,5 +320,146 @@ func (d *Database) SetPostTags(postID int, tags []string) error {
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.changed(ChangePosts, ChangeTags)
+	return nil
+}
+
+// GetSitemapPosts returns every published post with just the fields the
+// sitemap needs, newest first.
+func (d *Database) GetSitemapPosts() ([]*models.Post, error) {
+	query := `SELECT id, slug, html_content, cover_media_id, series_id, created_at, updated_at FROM posts WHERE deleted_at IS NULL AND status = 'published' ORDER BY created_at DESC`
+	rows, err := d.query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.Post
+	for rows.Next() {
+		post := &models.Post{}
+		var htmlContent sql.NullString
+		var coverID, seriesID sql.NullInt64
+		if err := rows.Scan(&post.ID, &post.Slug, &htmlContent, &coverID, &seriesID, &post.CreatedAt, &post.UpdatedAt); err != nil {
+			return nil, err
+		}
+		post.HTMLContent = htmlContent.String
+		post.CoverMediaID = int(coverID.Int64)
+		post.SeriesID = int(seriesID.Int64)
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// GetPublishedTags returns the tags used by published posts, with how many
+// posts use each and when the newest of them was last updated.
+func (d *Database) GetPublishedTags() ([]*models.Tag, error) {
+	query := `
+		SELECT t.name, p.updated_at
+		FROM tags t
+		JOIN post_tags pt ON t.id = pt.tag_id
+		JOIN posts p ON pt.post_id = p.id
+		WHERE p.deleted_at IS NULL AND p.status = 'published'
+		ORDER BY t.name ASC
+	`
+	rows, err := d.query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		var name string
+		var updated time.Time
+		if err := rows.Scan(&name, &updated); err != nil {
+			return nil, err
+		}
+		if len(tags) == 0 || tags[len(tags)-1].Name != name {
+			tags = append(tags, &models.Tag{Name: name})
+		}
+		tag := tags[len(tags)-1]
+		tag.PostCount++
+		if updated.After(tag.LastModified) {
+			tag.LastModified = updated
+		}
+	}
+	return tags, rows.Err()
+}
+
+// GetArchiveMonths counts published posts per month, newest month first.
+func (d *Database) GetArchiveMonths() ([]models.ArchiveMonth, error) {
+	query := `
+		SELECT CAST(substr(created_at, 1, 4) AS INTEGER), CAST(substr(created_at, 6, 2) AS INTEGER), COUNT(*)
+		FROM posts
+		WHERE deleted_at IS NULL AND status = 'published'
+		GROUP BY 1, 2
+		ORDER BY 1 DESC, 2 DESC
+	`
+	rows, err := d.query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var months []models.ArchiveMonth
+	for rows.Next() {
+		var m models.ArchiveMonth
+		if err := rows.Scan(&m.Year, &m.Month, &m.Count); err != nil {
+			return nil, err
+		}
+		months = append(months, m)
+	}
+	return months, rows.Err()
+}
+
+// GetArchivePosts lists published posts whose publish date starts with
+// prefix ("2024" or "2024-05"; empty for all), newest first. Only the
+// fields needed for listings are loaded.
+func (d *Database) GetArchivePosts(prefix string) ([]*models.Post, error) {
+	query := `SELECT id, title, slug, created_at FROM posts WHERE deleted_at IS NULL AND status = 'published' AND substr(created_at, 1, ?) = ? ORDER BY created_at DESC, id DESC`
+	rows, err := d.query(query, len(prefix), prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.Post
+	for rows.Next() {
+		post := &models.Post{}
+		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// GetAdjacentPosts returns the published posts immediately before and after
+// the given one by publish date. Either may be nil.
+func (d *Database) GetAdjacentPosts(id int) (prev, next *models.Post, err error) {
+	neighbour := func(cmp, order string) (*models.Post, error) {
+		query := `
+			SELECT p.title, p.slug FROM posts p, (SELECT created_at AS c FROM posts WHERE id = ?) cur
+			WHERE p.deleted_at IS NULL AND p.status = 'published' AND p.id != ?
+			AND (p.created_at ` + cmp + ` cur.c OR (p.created_at = cur.c AND p.id ` + cmp + ` ?))
+			ORDER BY p.created_at ` + order + `, p.id ` + order + ` LIMIT 1
+		`
+		post := &models.Post{}
+		err := d.queryRow(query, id, id, id).Scan(&post.Title, &post.Slug)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return post, err
+	}
+
+	if prev, err = neighbour("<", "DESC"); err != nil {
+		return nil, nil, err
+	}
+	if next, err = neighbour(">", "ASC"); err != nil {
+		return nil, nil, err
+	}
+	return prev, next, nil
 }
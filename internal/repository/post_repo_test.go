@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+func newTestDB(tb testing.TB) *Database {
+	tb.Helper()
+	db, err := NewDatabase(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+// seedPosts creates n published posts, each tagged "all" and "post-<i>".
+func seedPosts(tb testing.TB, db *Database, n int) {
+	tb.Helper()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		created := start.Add(time.Duration(i) * time.Hour)
+		post := &models.Post{
+			Title:     fmt.Sprintf("Post %d", i),
+			Slug:      fmt.Sprintf("post-%d", i),
+			Content:   "Some searchable content",
+			Status:    "published",
+			CreatedAt: created,
+			UpdatedAt: created,
+		}
+		if err := db.CreatePost(post); err != nil {
+			tb.Fatal(err)
+		}
+		if err := db.SetPostTags(post.ID, []string{"all", post.Slug}); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// countQueries returns how many queries fn ran.
+func countQueries(db *Database, fn func()) int64 {
+	before := db.queries.Load()
+	fn()
+	return db.queries.Load() - before
+}
+
+func TestPostListsLoadTags(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 3)
+
+	lists := map[string]func() ([]*models.Post, error){
+		"GetAllPosts":       db.GetAllPosts,
+		"GetPublishedPosts": func() ([]*models.Post, error) { return db.GetPublishedPosts(10, 0) },
+		"SearchPosts":       func() ([]*models.Post, error) { return db.SearchPosts("searchable") },
+		"GetPostsByTag":     func() ([]*models.Post, error) { return db.GetPostsByTag("all") },
+	}
+	for name, list := range lists {
+		posts, err := list()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(posts) != 3 {
+			t.Fatalf("%s returned %d posts, want 3", name, len(posts))
+		}
+		for _, p := range posts {
+			if len(p.Tags) != 2 || p.Tags[0] != "all" || p.Tags[1] != p.Slug {
+				t.Errorf("%s: post %s has tags %v, want [all %s]", name, p.Slug, p.Tags, p.Slug)
+			}
+		}
+	}
+}
+
+func TestPostListQueryCountIsConstant(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 40)
+
+	for _, limit := range []int{1, 5, 40} {
+		n := countQueries(db, func() {
+			if _, err := db.GetPublishedPosts(limit, 0); err != nil {
+				t.Fatal(err)
+			}
+		})
+		// One for the posts, one for all their tags
+		if n != 2 {
+			t.Errorf("GetPublishedPosts(%d) ran %d queries, want 2", limit, n)
+		}
+
+		n = countQueries(db, func() {
+			if _, _, err := db.ListPosts(PostFilter{Tag: "all", Limit: limit}); err != nil {
+				t.Fatal(err)
+			}
+		})
+		// Plus the total count
+		if n != 3 {
+			t.Errorf("ListPosts(limit %d) ran %d queries, want 3", limit, n)
+		}
+	}
+
+	// Statements are prepared once and reused
+	if got := len(db.stmts); got > 4 {
+		t.Errorf("%d statements cached, want one per distinct query", got)
+	}
+}
+
+func TestPublicReadsUseStatementCache(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 2)
+	if err := db.UpdateSetting("about", "Hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	view := func() {
+		db.GetPostBySlug("post-1")
+		db.IncrementViews("post-1")
+		db.GetAdjacentPosts(1)
+		db.GetRelatedPosts(1)
+		db.GetSetting("about")
+		db.GetNavPages()
+		db.GetPublishedPageBySlug("about")
+		db.GetTag("all")
+		db.GetArchiveMonths()
+	}
+	view()
+	prepared := len(db.stmts)
+	if n := countQueries(db, view); n < 10 {
+		t.Errorf("%d queries went through the statement cache, want all of them", n)
+	}
+	if len(db.stmts) != prepared {
+		t.Errorf("viewing again prepared %d more statements", len(db.stmts)-prepared)
+	}
+}
+
+func TestChangeEvents(t *testing.T) {
+	db := newTestDB(t)
+	var got []Change
+	db.OnChange(func(c Change) { got = append(got, c) })
+
+	seedPosts(t, db, 1)
+	if want := []Change{ChangePosts, ChangePosts, ChangeTags}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("creating and tagging a post reported %v, want %v", got, want)
+	}
+
+	got = nil
+	if err := db.IncrementViews("post-0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveAutosave(&models.Autosave{PostID: 1, Title: "Draft", SavedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("views and autosaves reported %v, want nothing", got)
+	}
+
+	if err := db.UpdateSetting("about", "Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != ChangeSettings {
+		t.Errorf("updating a setting reported %v, want [settings]", got)
+	}
+}
+
+func TestUpdatePostHTMLKeepsNewerSave(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 1)
+	post, err := db.GetPostBySlug("post-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A re-render read the post, then an edit was saved before it finished
+	rendered := post.Content
+	post.Content, post.HTMLContent, post.RenderVersion = "Edited", "<p>Edited</p>", 2
+	if err := db.UpdatePost(post); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdatePostHTML(post.ID, rendered, "<p>Some searchable content</p>", 2); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetPostBySlug("post-0"); got.HTMLContent != "<p>Edited</p>" {
+		t.Errorf("HTML = %q, stale render overwrote the edit", got.HTMLContent)
+	}
+
+	if err := db.UpdatePostHTML(post.ID, "Edited", "<p>Edited again</p>", 3); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.GetPostBySlug("post-0"); got.HTMLContent != "<p>Edited again</p>" || got.RenderVersion != 3 {
+		t.Errorf("HTML = %q version %d, want the current render stored", got.HTMLContent, got.RenderVersion)
+	}
+}
+
+func benchmarkPostList(b *testing.B, perPage int) {
+	db := newTestDB(b)
+	seedPosts(b, db, 100)
+
+	b.ResetTimer()
+	before := db.queries.Load()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.GetPublishedPosts(perPage, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(db.queries.Load()-before)/float64(b.N), "queries/op")
+}
+
+func BenchmarkGetPublishedPosts5(b *testing.B)   { benchmarkPostList(b, 5) }
+func BenchmarkGetPublishedPosts20(b *testing.B)  { benchmarkPostList(b, 20) }
+func BenchmarkGetPublishedPosts100(b *testing.B) { benchmarkPostList(b, 100) }
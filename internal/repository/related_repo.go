@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+// GetRelatedCorpus loads every published post with its title, content and
+// tags, the input for computing related posts.
+func (d *Database) GetRelatedCorpus() ([]*models.Post, error) {
+	rows, err := d.Conn.Query(`SELECT id, title, content FROM posts WHERE deleted_at IS NULL AND status = 'published' ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.Post
+	byID := map[int]*models.Post{}
+	for rows.Next() {
+		post := &models.Post{}
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+		byID[post.ID] = post
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tagRows, err := d.Conn.Query(`SELECT pt.post_id, t.name FROM post_tags pt JOIN tags t ON pt.tag_id = t.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var id int
+		var name string
+		if err := tagRows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		if post, ok := byID[id]; ok {
+			post.Tags = append(post.Tags, name)
+		}
+	}
+	return posts, tagRows.Err()
+}
+
+// ReplaceRelatedPosts swaps the stored related posts for a fresh set.
+func (d *Database) ReplaceRelatedPosts(related map[int][]models.RelatedPost) error {
+	tx, err := d.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM related_posts`); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO related_posts (post_id, related_id, rank, score, pinned) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for postID, list := range related {
+		for rank, r := range list {
+			if _, err := stmt.Exec(postID, r.RelatedID, rank, r.Score, r.Pinned); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.changed(ChangePosts)
+	return nil
+}
+
+// GetRelatedPosts returns the precomputed related posts for a post, in order.
+func (d *Database) GetRelatedPosts(postID int) ([]*models.Post, error) {
+	query := `
+		SELECT p.id, p.title, p.slug, p.created_at
+		FROM related_posts r
+		JOIN posts p ON p.id = r.related_id
+		WHERE r.post_id = ? AND p.deleted_at IS NULL AND p.status = 'published'
+		ORDER BY r.rank
+	`
+	rows, err := d.query(query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.Post
+	for rows.Next() {
+		post := &models.Post{}
+		if err := rows.Scan(&post.ID, &post.Title, &post.Slug, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// GetRelatedOverrides returns admin overrides, for one post or (postID 0) all.
+func (d *Database) GetRelatedOverrides(postID int) ([]models.RelatedOverride, error) {
+	query := `
+		SELECT o.post_id, o.related_id, o.action, p.title
+		FROM related_overrides o
+		JOIN posts p ON p.id = o.related_id
+		WHERE (? = 0 OR o.post_id = ?)
+		ORDER BY o.created_at, o.rowid
+	`
+	rows, err := d.Conn.Query(query, postID, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []models.RelatedOverride
+	for rows.Next() {
+		var o models.RelatedOverride
+		if err := rows.Scan(&o.PostID, &o.RelatedID, &o.Action, &o.Title); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// SetRelatedOverride pins or excludes relatedID on postID's related list.
+// An empty action removes the override.
+func (d *Database) SetRelatedOverride(postID, relatedID int, action string) error {
+	if action == "" {
+		_, err := d.Conn.Exec(`DELETE FROM related_overrides WHERE post_id = ? AND related_id = ?`, postID, relatedID)
+		return err
+	}
+	_, err := d.Conn.Exec(`INSERT INTO related_overrides (post_id, related_id, action) VALUES (?, ?, ?)
+		ON CONFLICT (post_id, related_id) DO UPDATE SET action = excluded.action`, postID, relatedID, action)
+	return err
+}
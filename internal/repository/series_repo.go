@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+func (d *Database) CreateSeries(s *models.Series) error {
+	res, err := d.Conn.Exec(`INSERT INTO series (title, slug, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		s.Title, s.Slug, s.Description, s.CreatedAt, s.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		s.ID = int(id)
+	}
+	d.changed(ChangeSeries)
+	return nil
+}
+
+func (d *Database) UpdateSeries(s *models.Series) error {
+	_, err := d.Conn.Exec(`UPDATE series SET title = ?, slug = ?, description = ?, updated_at = ? WHERE id = ?`,
+		s.Title, s.Slug, s.Description, s.UpdatedAt, s.ID)
+	if err != nil {
+		return err
+	}
+	// Posts show the title of their series
+	d.changed(ChangeSeries, ChangePosts)
+	return nil
+}
+
+// DeleteSeries removes a series; its posts stay but leave the series.
+func (d *Database) DeleteSeries(id int) error {
+	tx, err := d.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE posts SET series_id = NULL, series_position = 0 WHERE series_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM series WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.changed(ChangeSeries, ChangePosts)
+	return nil
+}
+
+func scanSeries(row interface{ Scan(...any) error }) (*models.Series, error) {
+	s := &models.Series{}
+	var desc sql.NullString
+	if err := row.Scan(&s.ID, &s.Title, &s.Slug, &desc, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	s.Description = desc.String
+	return s, nil
+}
+
+func (d *Database) GetSeriesByID(id int) (*models.Series, error) {
+	return scanSeries(d.queryRow(`SELECT id, title, slug, description, created_at, updated_at FROM series WHERE id = ?`, id))
+}
+
+func (d *Database) GetSeriesBySlug(slug string) (*models.Series, error) {
+	return scanSeries(d.queryRow(`SELECT id, title, slug, description, created_at, updated_at FROM series WHERE slug = ?`, slug))
+}
+
+// GetAllSeries lists every series by title, with its published post count.
+func (d *Database) GetAllSeries() ([]*models.Series, error) {
+	query := `
+		SELECT s.id, s.title, s.slug, s.description, s.created_at, s.updated_at,
+			(SELECT COUNT(*) FROM posts p WHERE p.series_id = s.id AND p.deleted_at IS NULL AND p.status = 'published')
+		FROM series s
+		ORDER BY s.title COLLATE NOCASE
+	`
+	rows, err := d.Conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []*models.Series
+	for rows.Next() {
+		s := &models.Series{}
+		var desc sql.NullString
+		if err := rows.Scan(&s.ID, &s.Title, &s.Slug, &desc, &s.CreatedAt, &s.UpdatedAt, &s.PostCount); err != nil {
+			return nil, err
+		}
+		s.Description = desc.String
+		all = append(all, s)
+	}
+	return all, rows.Err()
+}
+
+// GetSeriesPosts returns the published posts of a series in reading order.
+func (d *Database) GetSeriesPosts(seriesID int) ([]*models.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts p
+		WHERE p.series_id = ? AND p.deleted_at IS NULL AND p.status = 'published'
+		ORDER BY p.series_position, p.created_at, p.id
+	`
+	return d.queryPosts(query, seriesID)
+}
+
+// NextSeriesPosition returns the position after the last post in a series,
+// used when a post joins without an explicit position.
+func (d *Database) NextSeriesPosition(seriesID int) (int, error) {
+	var pos sql.NullInt64
+	err := d.Conn.QueryRow(`SELECT MAX(series_position) FROM posts WHERE series_id = ? AND deleted_at IS NULL`, seriesID).Scan(&pos)
+	return int(pos.Int64) + 1, err
+}
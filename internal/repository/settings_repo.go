@@ -2,7 +2,7 @@ package repository
 
 func (d *Database) GetSetting(key string) (string, error) {
 	var value string
-	err := d.Conn.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	err := d.queryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
 	if err != nil {
 		return "", err
 	}
@@ -10,6 +10,9 @@ func (d *Database) GetSetting(key string) (string, error) {
 }
 
 func (d *Database) UpdateSetting(key, value string) error {
-	_, err := d.Conn.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
-	return err
+	if _, err := d.Conn.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+		return err
+	}
+	d.changed(ChangeSettings)
+	return nil
 }
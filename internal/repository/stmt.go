@@ -0,0 +1,64 @@
+package repository
+
+import "database/sql"
+
+// stmt returns a prepared statement for query, preparing it on first use.
+func (d *Database) stmt(query string) (*sql.Stmt, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s, ok := d.stmts[query]; ok {
+		return s, nil
+	}
+	s, err := d.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if d.stmts == nil {
+		d.stmts = make(map[string]*sql.Stmt)
+	}
+	d.stmts[query] = s
+	return s, nil
+}
+
+// query runs a query through the statement cache.
+func (d *Database) query(query string, args ...any) (*sql.Rows, error) {
+	d.queries.Add(1)
+	s, err := d.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.Query(args...)
+}
+
+// queryRow runs a single row query through the statement cache.
+func (d *Database) queryRow(query string, args ...any) *sql.Row {
+	d.queries.Add(1)
+	s, err := d.stmt(query)
+	if err != nil {
+		// Scan reports the same preparation error
+		return d.Conn.QueryRow(query, args...)
+	}
+	return s.QueryRow(args...)
+}
+
+// exec runs a statement through the statement cache.
+func (d *Database) exec(query string, args ...any) (sql.Result, error) {
+	d.queries.Add(1)
+	s, err := d.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.Exec(args...)
+}
+
+// Close closes the cached statements and the database.
+func (d *Database) Close() error {
+	d.mu.Lock()
+	for _, s := range d.stmts {
+		s.Close()
+	}
+	d.stmts = nil
+	d.mu.Unlock()
+	return d.Conn.Close()
+}
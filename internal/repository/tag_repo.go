@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alextreichler/personal-website/internal/models"
+)
+
+// ErrTagExists is returned when renaming a tag to a name already in use;
+// merging is the way to combine two tags.
+var ErrTagExists = errors.New("a tag with that name already exists")
+
+// NormalizeTag returns the stored form of a tag name, as SetPostTags does.
+func NormalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// GetAllTags lists every tag with its published and draft post counts,
+// by name.
+func (d *Database) GetAllTags() ([]*models.Tag, error) {
+	query := `
+		SELECT t.name, t.display_name, t.description,
+			COUNT(CASE WHEN p.status = 'published' THEN 1 END),
+			COUNT(CASE WHEN p.status = 'draft' THEN 1 END)
+		FROM tags t
+		LEFT JOIN post_tags pt ON pt.tag_id = t.id
+		LEFT JOIN posts p ON p.id = pt.post_id AND p.deleted_at IS NULL
+		GROUP BY t.id
+		ORDER BY t.name ASC
+	`
+	rows, err := d.Conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		tag := &models.Tag{}
+		if err := rows.Scan(&tag.Name, &tag.DisplayName, &tag.Description, &tag.PostCount, &tag.DraftCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetTag returns a tag by name, or sql.ErrNoRows.
+func (d *Database) GetTag(name string) (*models.Tag, error) {
+	tag := &models.Tag{}
+	err := d.queryRow(`SELECT name, display_name, description FROM tags WHERE name = ?`, name).
+		Scan(&tag.Name, &tag.DisplayName, &tag.Description)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// UpdateTag sets the display name and description of a tag.
+func (d *Database) UpdateTag(name, displayName, description string) error {
+	res, err := d.Conn.Exec(`UPDATE tags SET display_name = ?, description = ? WHERE name = ?`,
+		strings.TrimSpace(displayName), strings.TrimSpace(description), name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	d.changed(ChangeTags)
+	return nil
+}
+
+// GetTagRedirect returns the current name of a tag that was renamed or
+// merged away, or sql.ErrNoRows.
+func (d *Database) GetTagRedirect(oldName string) (string, error) {
+	var newName string
+	err := d.queryRow(`SELECT new_name FROM tag_redirects WHERE old_name = ?`, oldName).Scan(&newName)
+	return newName, err
+}
+
+// RenameTag renames a tag, keeping its posts and redirecting the old name.
+func (d *Database) RenameTag(oldName, newName string) error {
+	newName = NormalizeTag(newName)
+	if newName == "" || oldName == newName {
+		return nil
+	}
+
+	tx, err := d.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM tags WHERE name = ?`, newName).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrTagExists
+	}
+
+	if err := bumpTaggedPosts(tx, oldName); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`UPDATE tags SET name = ? WHERE name = ?`, newName, oldName)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := redirectTag(tx, oldName, newName); err != nil {
+		return err
+	}
+	if err := auditTx(tx, "tags.rename", fmt.Sprintf("%q renamed to %q", oldName, newName)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.changed(ChangeTags, ChangePosts)
+	return nil
+}
+
+// MergeTags moves the posts of every source tag to target, creating it if
+// needed, then deletes the sources and redirects their names to target.
+// Sources that aren't tags, such as ones deleted in the meantime, are
+// skipped rather than redirected.
+func (d *Database) MergeTags(sources []string, target string) error {
+	target = NormalizeTag(target)
+	if target == "" {
+		return errors.New("merge needs a target tag")
+	}
+
+	tx, err := d.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, target); err != nil {
+		return err
+	}
+	var merged []string
+	for _, source := range sources {
+		if source == target {
+			continue
+		}
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM tags WHERE name = ?`, source).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			continue
+		}
+		if err := bumpTaggedPosts(tx, source); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO post_tags (post_id, tag_id)
+			SELECT pt.post_id, (SELECT id FROM tags WHERE name = ?)
+			FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE t.name = ?
+		`, target, source); err != nil {
+			return err
+		}
+		if err := deleteTag(tx, source); err != nil {
+			return err
+		}
+		if err := redirectTag(tx, source, target); err != nil {
+			return err
+		}
+		merged = append(merged, fmt.Sprintf("%q", source))
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	if err := auditTx(tx, "tags.merge", fmt.Sprintf("%s merged into %q", strings.Join(merged, ", "), target)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.changed(ChangeTags, ChangePosts)
+	return nil
+}
+
+// DeleteTag removes a tag from every post and deletes it. Its URL stops
+// working; nothing redirects there any more either.
+func (d *Database) DeleteTag(name string) error {
+	tx, err := d.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := bumpTaggedPosts(tx, name); err != nil {
+		return err
+	}
+	if err := deleteTag(tx, name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM tag_redirects WHERE new_name = ?`, name); err != nil {
+		return err
+	}
+	if err := auditTx(tx, "tags.delete", fmt.Sprintf("%q deleted", name)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.changed(ChangeTags, ChangePosts)
+	return nil
+}
+
+func deleteTag(tx *sql.Tx, name string) error {
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE tag_id = (SELECT id FROM tags WHERE name = ?)`, name); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM tags WHERE name = ?`, name)
+	return err
+}
+
+// redirectTag points oldName, and any names already redirected to it, at
+// newName. A name that is a tag again no longer redirects.
+func redirectTag(tx *sql.Tx, oldName, newName string) error {
+	if _, err := tx.Exec(`UPDATE tag_redirects SET new_name = ? WHERE new_name = ?`, newName, oldName); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO tag_redirects (old_name, new_name) VALUES (?, ?)`, oldName, newName); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM tag_redirects WHERE old_name = ?`, newName)
+	return err
+}
+
+// bumpTaggedPosts counts a tag change as an edit of the posts using it, so
+// editors opened before it notice.
+func bumpTaggedPosts(tx *sql.Tx, name string) error {
+	_, err := tx.Exec(`UPDATE posts SET version = version + 1 WHERE id IN (SELECT pt.post_id FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE t.name = ?)`, name)
+	return err
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// postTags returns the tag names of the post with the given slug.
+func postTags(t *testing.T, db *Database, slug string) []string {
+	t.Helper()
+	post, err := db.GetPostBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return post.Tags
+}
+
+// redirect returns where a tag name redirects, or "" if it doesn't.
+func redirect(t *testing.T, db *Database, name string) string {
+	t.Helper()
+	target, err := db.GetTagRedirect(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestRenameTag(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 2)
+
+	if err := db.RenameTag("post-0", "all"); !errors.Is(err, ErrTagExists) {
+		t.Errorf("renaming onto an existing tag: err = %v, want ErrTagExists", err)
+	}
+	if err := db.RenameTag("missing", "new"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("renaming a missing tag: err = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := db.GetTag("new"); err == nil {
+		t.Error("renaming a missing tag created the new name")
+	}
+
+	if err := db.RenameTag("post-0", " First "); err != nil {
+		t.Fatal(err)
+	}
+	if got := postTags(t, db, "post-0"); len(got) != 2 || got[0] != "all" || got[1] != "first" {
+		t.Errorf("tags after rename = %v, want [all first]", got)
+	}
+	if got := redirect(t, db, "post-0"); got != "first" {
+		t.Errorf("old name redirects to %q, want first", got)
+	}
+}
+
+func TestTagRedirectChainsAndCycles(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 1)
+
+	// a -> b -> c: both old names lead straight to c
+	for _, step := range [][2]string{{"post-0", "b"}, {"b", "c"}} {
+		if err := db.RenameTag(step[0], step[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"post-0", "b"} {
+		if got := redirect(t, db, name); got != "c" {
+			t.Errorf("%s redirects to %q, want c", name, got)
+		}
+	}
+
+	// Back to the first name: it is a tag again and must not redirect
+	if err := db.RenameTag("c", "post-0"); err != nil {
+		t.Fatal(err)
+	}
+	if got := redirect(t, db, "post-0"); got != "" {
+		t.Errorf("current tag name redirects to %q", got)
+	}
+	for _, name := range []string{"b", "c"} {
+		if got := redirect(t, db, name); got != "post-0" {
+			t.Errorf("%s redirects to %q, want post-0", name, got)
+		}
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 3)
+	before, err := db.GetPostBySlug("post-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.MergeTags([]string{"post-0", "post-1", "missing"}, "merged"); err != nil {
+		t.Fatal(err)
+	}
+	for _, slug := range []string{"post-0", "post-1"} {
+		if got := postTags(t, db, slug); len(got) != 2 || got[1] != "merged" {
+			t.Errorf("%s tags = %v, want [all merged]", slug, got)
+		}
+		if got := redirect(t, db, slug); got != "merged" {
+			t.Errorf("%s redirects to %q, want merged", slug, got)
+		}
+		if _, err := db.GetTag(slug); err == nil {
+			t.Errorf("merged tag %s still exists", slug)
+		}
+	}
+	if got := redirect(t, db, "missing"); got != "" {
+		t.Errorf("a source that was never a tag redirects to %q", got)
+	}
+	if after, _ := db.GetPostBySlug("post-2"); after.Version != before.Version {
+		t.Error("merge bumped the version of a post it didn't touch")
+	}
+
+	// Nothing to merge: the target isn't created either
+	if err := db.MergeTags([]string{"missing"}, "unused"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetTag("unused"); err == nil {
+		t.Error("merging only missing tags created the target")
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	db := newTestDB(t)
+	seedPosts(t, db, 1)
+	if err := db.RenameTag("post-0", "gone"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := db.GetPostBySlug("post-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DeleteTag("gone"); err != nil {
+		t.Fatal(err)
+	}
+	after := postTags(t, db, "post-0")
+	if len(after) != 1 || after[0] != "all" {
+		t.Errorf("tags after delete = %v, want [all]", after)
+	}
+	if _, err := db.GetTag("gone"); err == nil {
+		t.Error("deleted tag still exists")
+	}
+	if got := redirect(t, db, "post-0"); got != "" {
+		t.Errorf("name redirecting to the deleted tag still redirects to %q", got)
+	}
+	if post, _ := db.GetPostBySlug("post-0"); post.Version <= before.Version {
+		t.Error("deleting a tag didn't bump the version of its posts")
+	}
+}
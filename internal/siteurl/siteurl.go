@@ -0,0 +1,167 @@
+// Package siteurl builds absolute URLs for the site.
+//
+// When BASE_URL is configured every absolute URL uses it, regardless of the
+// Host header the client sent. Otherwise the origin is taken from the
+// request, honouring X-Forwarded-Proto and X-Forwarded-Host only when the
+// request arrived from a trusted proxy.
+package siteurl
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Builder creates absolute URLs and decides whether a request reached the
+// canonical origin.
+type Builder struct {
+	base    *url.URL // nil when no base URL is configured
+	trusted []netip.Prefix
+}
+
+// New parses baseURL (e.g. "https://example.com", may be empty) and a
+// comma-separated list of trusted proxy IPs or CIDR ranges.
+func New(baseURL, trustedProxies string) (*Builder, error) {
+	b := &Builder{}
+
+	if baseURL != "" {
+		u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("base URL %q must be an absolute http(s) URL", baseURL)
+		}
+		if u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("base URL %q must not have a path, query or fragment", baseURL)
+		}
+		b.base = u
+	}
+
+	for _, s := range strings.Split(trustedProxies, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+			}
+			b.trusted = append(b.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		b.trusted = append(b.trusted, prefix.Masked())
+	}
+
+	return b, nil
+}
+
+// Host returns the host of the configured base URL, or "" when there is none.
+func (b *Builder) Host() string {
+	if b.base == nil {
+		return ""
+	}
+	return b.base.Host
+}
+
+// Origin returns the scheme and host the site is served from, without a
+// trailing slash.
+func (b *Builder) Origin(r *http.Request) string {
+	if b.base != nil {
+		return b.base.Scheme + "://" + b.base.Host
+	}
+	scheme, host, _ := b.requestOrigin(r)
+	return scheme + "://" + host
+}
+
+// Abs turns a site-relative path such as "/post/hello" into an absolute URL.
+func (b *Builder) Abs(r *http.Request, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return b.Origin(r) + path
+}
+
+// Canonical returns the canonical URL of the page being requested. Only the
+// "page" query parameter is kept, since it selects different content.
+func (b *Builder) Canonical(r *http.Request) string {
+	u := b.Abs(r, r.URL.EscapedPath())
+	if page := r.URL.Query().Get("page"); page != "" && page != "1" {
+		u += "?page=" + url.QueryEscape(page)
+	}
+	return u
+}
+
+// Redirect reports where a request should be sent when it reached the site
+// through a non-canonical scheme or host. It never redirects when no base URL
+// is configured. The scheme only counts when it is known, from TLS or a
+// trusted proxy: behind a proxy that terminates TLS but isn't trusted, every
+// request looks like plain HTTP, and redirecting those would loop forever.
+func (b *Builder) Redirect(r *http.Request) (string, bool) {
+	if b.base == nil {
+		return "", false
+	}
+	scheme, host, schemeKnown := b.requestOrigin(r)
+	if (!schemeKnown || scheme == b.base.Scheme) && strings.EqualFold(host, b.base.Host) {
+		return "", false
+	}
+	return b.base.Scheme + "://" + b.base.Host + r.URL.RequestURI(), true
+}
+
+// requestOrigin works out the scheme and host the client used. schemeKnown
+// is false when the scheme is only a guess of "http".
+func (b *Builder) requestOrigin(r *http.Request) (scheme, host string, schemeKnown bool) {
+	scheme, host = "http", r.Host
+	if r.TLS != nil {
+		scheme, schemeKnown = "https", true
+	}
+	if b.fromTrustedProxy(r) {
+		// A trusted proxy that sends no X-Forwarded-Proto serves plain HTTP
+		schemeKnown = true
+		if proto := firstValue(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwd := firstValue(r.Header.Get("X-Forwarded-Host")); fwd != "" {
+			host = fwd
+		}
+	}
+	return scheme, host, schemeKnown
+}
+
+// fromTrustedProxy reports whether the direct peer is a trusted proxy.
+func (b *Builder) fromTrustedProxy(r *http.Request) bool {
+	if len(b.trusted) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range b.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstValue returns the first entry of a comma-separated header.
+func firstValue(v string) string {
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
@@ -0,0 +1,119 @@
+package siteurl
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrigin(t *testing.T) {
+	tests := []struct {
+		name, base, trusted string
+		remote, host        string
+		tls                 bool
+		headers             map[string]string
+		want                string
+	}{
+		{name: "configured base ignores host", base: "https://example.com/", remote: "1.2.3.4:5", host: "evil.test", want: "https://example.com"},
+		{name: "plain request", remote: "1.2.3.4:5", host: "localhost:6060", want: "http://localhost:6060"},
+		{name: "tls request", remote: "1.2.3.4:5", host: "site.test", tls: true, want: "https://site.test"},
+		{
+			name: "untrusted forwarded headers", trusted: "10.0.0.0/8", remote: "1.2.3.4:5", host: "internal:6060",
+			headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.test"},
+			want:    "http://internal:6060",
+		},
+		{
+			name: "trusted forwarded headers", trusted: "10.0.0.0/8, 127.0.0.1", remote: "10.1.2.3:5", host: "internal:6060",
+			headers: map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "site.test"},
+			want:    "https://site.test",
+		},
+		{
+			name: "trusted single address", trusted: "127.0.0.1", remote: "127.0.0.1:5", host: "internal",
+			headers: map[string]string{"X-Forwarded-Proto": "https"},
+			want:    "https://internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New(tt.base, tt.trusted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/post/x", nil)
+			r.RemoteAddr, r.Host = tt.remote, tt.host
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := b.Origin(r); got != tt.want {
+				t.Errorf("Origin = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	b, err := New("https://example.com", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "http://www.example.com/post/x?page=2", nil)
+	r.RemoteAddr = "1.2.3.4:5"
+	if got, ok := b.Redirect(r); !ok || got != "https://example.com/post/x?page=2" {
+		t.Errorf("Redirect = %q, %v", got, ok)
+	}
+
+	r = httptest.NewRequest("GET", "http://internal/post/x", nil)
+	r.RemoteAddr = "10.0.0.1:5"
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Forwarded-Host", "EXAMPLE.com")
+	if got, ok := b.Redirect(r); ok {
+		t.Errorf("canonical request redirected to %q", got)
+	}
+
+	r = httptest.NewRequest("GET", "http://example.com/post/x", nil)
+	r.RemoteAddr = "10.0.0.1:5"
+	r.Header.Set("X-Forwarded-Proto", "http")
+	if got, ok := b.Redirect(r); !ok || got != "https://example.com/post/x" {
+		t.Errorf("plain HTTP through the trusted proxy: Redirect = %q, %v", got, ok)
+	}
+
+	if c := b.Canonical(httptest.NewRequest("GET", "/?page=3&utm=x", nil)); c != "https://example.com/?page=3" {
+		t.Errorf("Canonical = %q", c)
+	}
+}
+
+func TestRedirectBehindUntrustedTLSProxy(t *testing.T) {
+	// TLS ends at a proxy that isn't listed in TRUSTED_PROXIES, so every
+	// request arrives as plain HTTP whatever the client used
+	b, err := New("https://example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "http://example.com/post/x", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	if got, ok := b.Redirect(r); ok {
+		t.Errorf("request on the canonical host redirected to %q", got)
+	}
+
+	r = httptest.NewRequest("GET", "http://www.example.com/post/x", nil)
+	if got, ok := b.Redirect(r); !ok || got != "https://example.com/post/x" {
+		t.Errorf("Redirect of another host = %q, %v", got, ok)
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	for _, base := range []string{"example.com", "ftp://example.com", "https://example.com/blog"} {
+		if _, err := New(base, ""); err == nil {
+			t.Errorf("New(%q) accepted", base)
+		}
+	}
+	if _, err := New("", "not-an-ip"); err == nil {
+		t.Error("invalid proxy accepted")
+	}
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alextreichler/personal-website/internal/config"
+)
+
+// ErrNotFound is returned when a key does not exist in the backend.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage abstracts where uploaded media lives, so the app can keep files on
+// local disk in development and in a shared S3-compatible bucket when running
+// several replicas.
+type Storage interface {
+	// Put stores the content of r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object stored under key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns every object whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// URL returns the public path the object is served from.
+	URL(key string) string
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Object is an open, seekable handle to a stored object. Seeking lets
+// http.ServeContent answer Range requests regardless of the backend.
+type Object struct {
+	io.ReadSeekCloser
+	ObjectInfo
+}
+
+// URLPrefix is the route every backend's objects are served from.
+const URLPrefix = "/media/"
+
+// New builds the storage backend selected by cfg.StorageBackend.
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "", "local":
+		return NewLocal(cfg.UploadPath)
+	case "s3":
+		return NewS3(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", cfg.StorageBackend)
+	}
+}
+
+// cleanKey validates a key and normalizes it to a slash-separated relative path.
+func cleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return "", errors.New("storage: empty key")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("storage: invalid key %q", key)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,118 @@
+// Package textdiff computes line-based differences between two texts, for
+// showing what changed between two versions of a post.
+package textdiff
+
+import "strings"
+
+// Op says whether a line is common to both texts or only in one.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+func (o Op) String() string {
+	switch o {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	}
+	return "equal"
+}
+
+// Line is one line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the lines of a followed by those of b, marking lines only in
+// a as deleted and lines only in b as inserted. Deletions come before the
+// insertions that replace them.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// Common prefix and suffix are cheap and keep the table small for
+	// typical edits.
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	var diff []Line
+	for _, s := range x[:pre] {
+		diff = append(diff, Line{Equal, s})
+	}
+	diff = append(diff, lcs(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, s := range x[len(x)-suf:] {
+		diff = append(diff, Line{Equal, s})
+	}
+	return diff
+}
+
+// Changed reports whether a diff has any inserted or deleted lines.
+func Changed(diff []Line) bool {
+	for _, l := range diff {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lcs diffs x and y through their longest common subsequence.
+func lcs(x, y []string) []Line {
+	// n[i][j] is the LCS length of x[i:] and y[j:]
+	n := make([][]int, len(x)+1)
+	for i := range n {
+		n[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				n[i][j] = n[i+1][j+1] + 1
+			} else {
+				n[i][j] = max(n[i+1][j], n[i][j+1])
+			}
+		}
+	}
+
+	var diff []Line
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, Line{Equal, x[i]})
+			i++
+			j++
+		case n[i+1][j] >= n[i][j+1]:
+			diff = append(diff, Line{Delete, x[i]})
+			i++
+		default:
+			diff = append(diff, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, Line{Insert, y[j]})
+	}
+	return diff
+}
@@ -0,0 +1,125 @@
+// Package theme combines the built-in templates and static files with a
+// directory of replacements, so the look of the site can be changed
+// without rebuilding it.
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Overlay returns base with the files of dir on top: a file in dir
+// replaces the file of the same name in base, and new files are added.
+// An empty or missing dir leaves base as it is.
+func Overlay(base fs.FS, dir string) fs.FS {
+	if dir == "" {
+		return base
+	}
+	return &overlay{top: os.DirFS(dir), base: base}
+}
+
+type overlay struct {
+	top, base fs.FS
+}
+
+func (o *overlay) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
+
+// ReadDir lists a directory of either file system, merging the two.
+func (o *overlay) ReadDir(name string) ([]fs.DirEntry, error) {
+	top, topErr := fs.ReadDir(o.top, name)
+	if topErr != nil && !errors.Is(topErr, fs.ErrNotExist) {
+		return nil, topErr
+	}
+	base, baseErr := fs.ReadDir(o.base, name)
+	if baseErr != nil && !errors.Is(baseErr, fs.ErrNotExist) {
+		return nil, baseErr
+	}
+	if topErr != nil && baseErr != nil {
+		return nil, baseErr
+	}
+
+	entries := top
+	for _, e := range base {
+		if !slices.ContainsFunc(top, func(t fs.DirEntry) bool { return t.Name() == e.Name() }) {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+// Without returns fsys with the directory dir and everything below it left
+// out. An empty dir leaves fsys as it is.
+func Without(fsys fs.FS, dir string) fs.FS {
+	if dir == "" || dir == "." {
+		return fsys
+	}
+	return &without{fsys: fsys, dir: dir}
+}
+
+type without struct {
+	fsys fs.FS
+	dir  string
+}
+
+func (w *without) hidden(name string) bool {
+	return name == w.dir || strings.HasPrefix(name, w.dir+"/")
+}
+
+func (w *without) Open(name string) (fs.File, error) {
+	if w.hidden(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return w.fsys.Open(name)
+}
+
+// ReadDir lists a directory of fsys without the hidden one.
+func (w *without) ReadDir(name string) ([]fs.DirEntry, error) {
+	if w.hidden(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries, err := fs.ReadDir(w.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(entries, func(e fs.DirEntry) bool { return w.hidden(path.Join(name, e.Name())) }), nil
+}
+
+// Stamp summarizes the names, sizes and modification times of the files in
+// each fsys. It changes when a file is added, removed or edited, which is
+// how development mode notices edits.
+func Stamp(fsyss ...fs.FS) (string, error) {
+	h := fnv.New64a()
+	for i, fsys := range fsyss {
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%d %s %d %d\n", i, p, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64()), nil
+}
@@ -0,0 +1,97 @@
+package theme
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestOverlay(t *testing.T) {
+	base := fstest.MapFS{
+		"base.html":    {Data: []byte("built-in base")},
+		"home.html":    {Data: []byte("built-in home")},
+		"js/editor.js": {Data: []byte("editor")},
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "home.html"), []byte("themed home"), 0644)
+	os.Mkdir(filepath.Join(dir, "js"), 0755)
+	os.WriteFile(filepath.Join(dir, "js", "extra.js"), []byte("extra"), 0644)
+
+	fsys := Overlay(base, dir)
+	for name, want := range map[string]string{
+		"base.html":    "built-in base",
+		"home.html":    "themed home",
+		"js/editor.js": "editor",
+		"js/extra.js":  "extra",
+	} {
+		got, err := fs.ReadFile(fsys, name)
+		if err != nil || string(got) != want {
+			t.Errorf("ReadFile(%s) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	var names []string
+	fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			names = append(names, p)
+		}
+		return err
+	})
+	if len(names) != 4 {
+		t.Errorf("walked %v, want each file once", names)
+	}
+
+	if _, ok := Overlay(base, "").(fstest.MapFS); !ok {
+		t.Error("an empty theme directory wrapped the base files")
+	}
+	if got, err := fs.ReadFile(Overlay(base, filepath.Join(dir, "missing")), "home.html"); err != nil || string(got) != "built-in home" {
+		t.Errorf("missing theme directory: ReadFile = %q, %v", got, err)
+	}
+}
+
+func TestWithout(t *testing.T) {
+	fsys := Without(fstest.MapFS{
+		"style.css":           {Data: []byte("css")},
+		"uploads/photo.jpg":   {Data: []byte("jpg")},
+		"uploads/a/video.mp4": {Data: []byte("mp4")},
+		"uploadsheet.txt":     {Data: []byte("txt")},
+	}, "uploads")
+
+	var names []string
+	fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			names = append(names, p)
+		}
+		return err
+	})
+	if len(names) != 2 || names[0] != "style.css" || names[1] != "uploadsheet.txt" {
+		t.Errorf("walked %v, want style.css and uploadsheet.txt", names)
+	}
+	if _, err := fs.ReadFile(fsys, "uploads/photo.jpg"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile of a hidden file: %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestStamp(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "base.html")
+	os.WriteFile(file, []byte("one"), 0644)
+
+	before, err := Stamp(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Stamp(os.DirFS(dir)); again != before {
+		t.Error("stamp changed without any edit")
+	}
+
+	os.WriteFile(file, []byte("two"), 0644)
+	os.Chtimes(file, time.Now(), time.Now().Add(time.Second))
+	if after, _ := Stamp(os.DirFS(dir)); after == before {
+		t.Error("stamp did not change after an edit")
+	}
+}
@@ -0,0 +1,67 @@
+// Periodic autosave of the post being edited to /admin/api/autosave, and
+// the recovery prompt shown when the editor opens with a newer autosave.
+// A new post has an empty postID and version.
+function serverAutosave(easyMDE, csrfToken, postID, version, status) {
+    var title = document.getElementById('title');
+    var dirty = false;
+    var interval = 30000;
+
+    function post(url, fields) {
+        fields.id = postID;
+        return fetch(url, {
+            method: 'POST',
+            headers: { 'X-CSRF-Token': csrfToken },
+            body: new URLSearchParams(fields),
+        }).then(function (res) {
+            if (!res.ok) throw new Error('Autosave failed: ' + res.status);
+            return res;
+        });
+    }
+
+    function save() {
+        if (!dirty) return;
+        dirty = false;
+        post('/admin/api/autosave', { title: title.value, content: easyMDE.value(), version: version })
+            .then(function (res) { return res.json(); })
+            .then(function (data) {
+                var msg = 'Autosaved at ' + new Date(data.saved_at).toLocaleTimeString();
+                if (data.stale) msg += '. This post was saved elsewhere since you opened it; saving will show the differences.';
+                status.textContent = msg;
+            })
+            .catch(function (err) {
+                dirty = true;
+                status.textContent = err.message;
+            });
+    }
+
+    function markDirty() { dirty = true; }
+    easyMDE.codemirror.on('change', markDirty);
+    title.addEventListener('input', markDirty);
+    setInterval(save, interval);
+
+    // Save what's left when the tab is closed or hidden
+    document.addEventListener('visibilitychange', function () {
+        if (document.visibilityState !== 'hidden' || !dirty) return;
+        dirty = false;
+        // A beacon can't set headers, so the token goes in the form body
+        navigator.sendBeacon('/admin/api/autosave', new URLSearchParams({
+            csrf_token: csrfToken, id: postID, title: title.value, content: easyMDE.value(), version: version,
+        }));
+    });
+
+    // The form is being submitted, nothing left to autosave
+    title.form.addEventListener('submit', function () { dirty = false; });
+
+    var recovery = document.getElementById('autosave-recovery');
+    if (!recovery) return;
+    document.getElementById('autosave-restore').addEventListener('click', function () {
+        title.value = document.getElementById('autosave-title').value;
+        easyMDE.value(document.getElementById('autosave-content').value);
+        recovery.remove();
+    });
+    document.getElementById('autosave-discard').addEventListener('click', function () {
+        post('/admin/api/autosave/discard', {})
+            .then(function () { recovery.remove(); })
+            .catch(function (err) { status.textContent = err.message; });
+    });
+}
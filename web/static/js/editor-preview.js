@@ -0,0 +1,45 @@
+// Server-rendered previews for the EasyMDE editor. The Markdown is sent to
+// /admin/api/preview so the preview matches what gets published, instead of
+// EasyMDE's own client-side parser.
+function serverPreview(easyMDE, csrfToken, stats) {
+    var html = '';
+    var target = null;
+    var timer = null;
+    var sequence = 0;
+
+    function refresh() {
+        var current = ++sequence;
+        fetch('/admin/api/preview', {
+            method: 'POST',
+            headers: { 'X-CSRF-Token': csrfToken },
+            body: new URLSearchParams({ content: easyMDE.value() }),
+        })
+            .then(function (res) {
+                if (!res.ok) throw new Error('Preview failed: ' + res.status);
+                return res.json();
+            })
+            .then(function (data) {
+                // Ignore responses overtaken by a newer request
+                if (current !== sequence) return;
+                html = data.html;
+                if (target) target.innerHTML = html;
+                if (stats) stats.textContent = data.word_count + ' words, ' + data.reading_time;
+            })
+            .catch(function (err) {
+                if (stats) stats.textContent = err.message;
+            });
+    }
+
+    function schedule() {
+        clearTimeout(timer);
+        timer = setTimeout(refresh, 400);
+    }
+
+    easyMDE.options.previewRender = function (plainText, preview) {
+        target = preview;
+        schedule();
+        return html || '<p><em>Rendering preview…</em></p>';
+    };
+    easyMDE.codemirror.on('change', schedule);
+    refresh();
+}
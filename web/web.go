@@ -0,0 +1,34 @@
+// Package web holds the templates and static files of the site, embedded
+// into the binary so it runs from any directory.
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Static files are listed by type so uploads, which the default UPLOAD_PATH
+// keeps below static/, never end up in the binary. Add a pattern here when
+// adding a new kind of static file.
+//
+//go:embed template static/*.css static/*.svg static/js
+var files embed.FS
+
+// Templates returns the embedded HTML templates.
+func Templates() fs.FS {
+	return sub("template")
+}
+
+// Static returns the embedded static files, as served under /static/.
+func Static() fs.FS {
+	return sub("static")
+}
+
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(files, dir)
+	if err != nil {
+		// Only possible for an invalid path, which dir is not
+		panic(err)
+	}
+	return fsys
+}